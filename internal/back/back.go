@@ -23,6 +23,9 @@ type Back struct {
 	// notifications receives content from the Back and MUST be consumed externally.
 	notifications chan Notification
 
+	// live sends LiveEvent to the web front-end, see SubscribeLiveEvents.
+	live *liveBroker
+
 	// It is possible to fetch the same session twice to count it down, this
 	// cache avoid starting the same session twice.  This is only used in
 	// countdownAndStartMatchSession which is _not_ run concurrently.
@@ -45,6 +48,7 @@ func New(sqlDriver, sqlDSN, ootrAPIKey string) (*Back, error) {
 	return &Back{
 		db:               db,
 		notifications:    make(chan Notification, 32),
+		live:             &liveBroker{subscribers: map[chan LiveEvent]struct{}{}},
 		countingDown:     map[util.UUIDAsBlob]struct{}{},
 		generatorFactory: factory.New(ootrapi.New(ootrAPIKey)),
 	}, nil
@@ -100,3 +104,19 @@ func (b *Back) transaction(cb transactionCallback) error {
 
 	return tx.Commit()
 }
+
+// dryRunTransaction runs the given callback in a SQL transaction that is
+// always rolled back, for computing what-if scenarios.
+func (b *Back) dryRunTransaction(cb transactionCallback) error {
+	tx, err := b.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	err = cb(tx)
+	if err2 := tx.Rollback(); err2 != nil {
+		return fmt.Errorf("rollback error: %s\noriginal error: %v", err2, err)
+	}
+
+	return err
+}
@@ -0,0 +1,290 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/generator"
+	"kaepora/internal/generator/oot"
+	"kaepora/internal/util"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	shortCodeRegexp        = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,15}$`)
+	discordChannelIDRegexp = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func (b *Back) GetGames() (ret []Game, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret, err = getGames(tx)
+		return err
+	})
+}
+
+func (b *Back) CreateGame(name string) (ret Game, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return util.ErrPublic("you need to give the game a name")
+		}
+
+		ret = NewGame(name)
+		return ret.insert(tx)
+	})
+}
+
+func (b *Back) RenameGame(id util.UUIDAsBlob, name string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		game, err := getGameByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		game.Name = strings.TrimSpace(name)
+		if game.Name == "" {
+			return util.ErrPublic("you need to give the game a name")
+		}
+
+		return game.update(tx)
+	})
+}
+
+// CreateLeague validates and inserts a new league built by NewLeague.
+func (b *Back) CreateLeague(league League) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		if err := b.validateLeague(tx, league); err != nil {
+			return err
+		}
+
+		return league.insert(tx)
+	})
+}
+
+// UpdateLeague replaces the configuration of the league identified by the
+// given shortcode with the one of the given league: name, shortcode, game,
+// generator, settings, schedule, and announce channel. Other fields have
+// their own setters and are left untouched.
+func (b *Back) UpdateLeague(shortcode string, league League) error {
+	_, err := b.editLeague(shortcode, func(current *League) error {
+		current.Name = league.Name
+		current.ShortCode = league.ShortCode
+		current.GameID = league.GameID
+		current.Generator = league.Generator
+		current.Settings = league.Settings
+		current.Schedule = league.Schedule
+		current.AnnounceDiscordChannelID = league.AnnounceDiscordChannelID
+		return nil
+	})
+
+	return err
+}
+
+// RenameLeague changes the name and shortcode of a league.
+func (b *Back) RenameLeague(shortcode, newShortCode, name string) error {
+	_, err := b.editLeague(shortcode, func(league *League) error {
+		league.ShortCode = newShortCode
+		league.Name = name
+		return nil
+	})
+
+	return err
+}
+
+// SetLeagueGenerator changes the generator and settings used for the next
+// seeds of a league.
+func (b *Back) SetLeagueGenerator(shortcode, generatorID, settings string) error {
+	_, err := b.editLeague(shortcode, func(league *League) error {
+		league.Generator = generatorID
+		league.Settings = settings
+		return nil
+	})
+
+	return err
+}
+
+// AddLeagueSchedule adds an "HH:MM Area/City" entry to the given day ("Mon"
+// to "Sun", or "all"), or a "rule", "date", or "blackout" entry, to the
+// schedule of a league and returns the new schedule, see Schedule.
+func (b *Back) AddLeagueSchedule(shortcode, day, entry string) (Schedule, error) {
+	league, err := b.editLeague(shortcode, func(league *League) error {
+		return league.Schedule.Add(day, entry)
+	})
+
+	return league.Schedule, err
+}
+
+// RemoveLeagueSchedule removes an entry added by AddLeagueSchedule and
+// returns the new schedule.
+func (b *Back) RemoveLeagueSchedule(shortcode, day, entry string) (Schedule, error) {
+	league, err := b.editLeague(shortcode, func(league *League) error {
+		return league.Schedule.Remove(day, entry)
+	})
+
+	return league.Schedule, err
+}
+
+// editLeague runs the given callback on a league and saves it if it is still
+// valid afterwards.
+func (b *Back) editLeague(shortcode string, edit func(*League) error) (ret League, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if err := edit(&league); err != nil {
+			return err
+		}
+
+		if err := b.validateLeague(tx, league); err != nil {
+			return err
+		}
+
+		ret = league
+		return league.update(tx)
+	})
+}
+
+// DeleteLeague removes a league that never had any race along with its
+// seasons, divisions, and polls. Leagues with a history cannot be deleted.
+func (b *Back) DeleteLeague(shortcode string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		var count int
+		if err := tx.Get(&count, `
+            SELECT (SELECT COUNT(*) FROM MatchSession WHERE LeagueID = ?) +
+                   (SELECT COUNT(*) FROM PlayerRating WHERE LeagueID = ?)`,
+			league.ID, league.ID,
+		); err != nil {
+			return err
+		}
+		if count > 0 {
+			return util.ErrPublic(fmt.Sprintf(
+				"league `%s` already has races and cannot be deleted", shortcode,
+			))
+		}
+
+		for _, query := range []string{
+			`DELETE FROM PollSlot WHERE PollID IN(SELECT ID FROM Poll WHERE LeagueID = ?)`,
+			`DELETE FROM Poll WHERE LeagueID = ?`,
+			`DELETE FROM PlayerDivision WHERE LeagueID = ?`,
+			`DELETE FROM Division WHERE LeagueID = ?`,
+			`DELETE FROM Season WHERE LeagueID = ?`,
+			`DELETE FROM League WHERE ID = ?`,
+		} {
+			if _, err := tx.Exec(query, league.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// validateLeague returns an util.ErrPublic if the given league could not be
+// saved as is or would not be able to generate seeds.
+func (b *Back) validateLeague(tx *sqlx.Tx, league League) error {
+	if strings.TrimSpace(league.Name) == "" {
+		return util.ErrPublic("you need to give the league a name")
+	}
+
+	if !shortCodeRegexp.MatchString(league.ShortCode) {
+		return util.ErrPublic("the shortcode must be 1 to 16 lowercase letters, digits, dashes, or underscores")
+	}
+	other, err := getLeagueByShortCode(tx, league.ShortCode)
+	if err == nil && other.ID != league.ID {
+		return util.ErrPublic(fmt.Sprintf("the shortcode '%s' is already used by league '%s'", league.ShortCode, other.Name))
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if _, err := getGameByID(tx, league.GameID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return util.ErrPublic("unknown game")
+		}
+		return err
+	}
+
+	if err := b.validateGeneratorSettings(league.Generator, league.Settings); err != nil {
+		return err
+	}
+
+	if err := league.Schedule.Validate(); err != nil {
+		return err
+	}
+
+	if channel := league.AnnounceDiscordChannelID; channel.Valid &&
+		!discordChannelIDRegexp.MatchString(channel.String) {
+		return util.ErrPublic("the announce channel must be a Discord channel ID")
+	}
+
+	return nil
+}
+
+// validateGeneratorSettings ensures the generator exists and the settings
+// file can be found by the OoT generators.
+func (b *Back) validateGeneratorSettings(generatorID, settings string) error {
+	gen, err := b.generatorFactory.NewGenerator(generatorID)
+	if err != nil {
+		return util.ErrPublic(fmt.Sprintf("unknown generator '%s'", generatorID))
+	}
+
+	if _, ok := gen.(*generator.Test); ok {
+		return nil
+	}
+
+	if settings == "" || filepath.Base(settings) != settings {
+		return util.ErrPublic("the settings must be the name of a file in the randomizer resources directory")
+	}
+
+	dir, err := oot.GetBaseDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, settings)); err != nil {
+		if os.IsNotExist(err) {
+			return util.ErrPublic(fmt.Sprintf("settings file '%s' not found", settings))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetMatchSessionRecap returns the results table of a session as shown by
+// "!recap", with the matches still running if scope is RecapScopeAdmin.
+func (b *Back) GetMatchSessionRecap(id util.UUIDAsBlob, scope RecapScope) (ret string, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		matches, err := getMatchesBySessionID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var str strings.Builder
+		known, unknown := writeResultsTable(tx, &str, matches, scope, nil)
+		if known > 0 {
+			ret = str.String()
+		}
+		if unknown > 0 {
+			ret += fmt.Sprintf("There are still %d race(s) in progress.\n", unknown)
+		}
+
+		return nil
+	})
+}
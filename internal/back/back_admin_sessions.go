@@ -0,0 +1,393 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Admins cannot create or move a race closer than this from now so players
+// still have a chance to join before it starts its preparation.
+const adminMatchSessionMinNotice = -MatchSessionPreparationOffset + 5*time.Minute
+
+// GetLeagueOpenMatchSessions returns the sessions of a league that are not
+// closed yet, sorted by start date.
+func (b *Back) GetLeagueOpenMatchSessions(shortcode string) (
+	league League,
+	sessions []MatchSession,
+	_ error,
+) {
+	return league, sessions, b.transaction(func(tx *sqlx.Tx) (err error) {
+		league, err = getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		return tx.Select(&sessions, `
+            SELECT * FROM MatchSession
+            WHERE LeagueID = ? AND Status <> ?
+            ORDER BY StartDate ASC`,
+			league.ID, MatchSessionStatusClosed,
+		)
+	})
+}
+
+// CreateMatchSession creates an unscheduled race for a league at the given
+// date, one per division for leagues with divisions.
+func (b *Back) CreateMatchSession(shortcode string, start time.Time) (ret []MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if err := checkAdminMatchSessionStart(start); err != nil {
+			return err
+		}
+
+		divisions, err := getDivisionsByLeagueID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+		if len(divisions) == 0 {
+			divisions = []Division{{}} // single session without division
+		}
+
+		for _, division := range divisions {
+			if err := ensureNoMatchSessionAt(tx, league, division.ID, start); err != nil {
+				return err
+			}
+
+			session := NewMatchSession(league.ID, start)
+			session.DivisionID = util.NewNullUUIDAsBlob(division.ID)
+			if err := session.insert(tx); err != nil {
+				return err
+			}
+
+			if err := b.sendSessionStatusUpdateNotification(tx, session); err != nil {
+				return err
+			}
+
+			ret = append(ret, session)
+		}
+
+		return nil
+	})
+}
+
+// PostponeMatchSession moves a race that did not start its preparation to a
+// later date, its players and waitlist are kept.
+func (b *Back) PostponeMatchSession(id util.UUIDAsBlob, start time.Time) (ret MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, err := getAdminMatchSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := session.CanAdminEdit(); err != nil {
+			return err
+		}
+		if err := checkAdminMatchSessionStart(start); err != nil {
+			return err
+		}
+		previous := session.StartDate.Time()
+		if !start.After(previous) {
+			return util.ErrPublic("a race can only be postponed to a later date")
+		}
+
+		league, err := getLeagueByID(tx, session.LeagueID)
+		if err != nil {
+			return err
+		}
+		if err := ensureNoMatchSessionAt(tx, league, session.DivisionID.UUID, start); err != nil {
+			return err
+		}
+
+		// Leave a cancelled session in the original slot so the schedule does
+		// not create the race again.
+		placeholder := NewMatchSession(session.LeagueID, previous)
+		placeholder.DivisionID = session.DivisionID
+		placeholder.Status = MatchSessionStatusClosed
+		if err := placeholder.insert(tx); err != nil {
+			return err
+		}
+
+		session.StartDate = util.TimeAsDateTimeTZ(start)
+		if err := session.update(tx); err != nil {
+			return err
+		}
+
+		ret = session
+		return b.sendMatchSessionChangeNotification(
+			tx, session, session.allPlayerIDs(),
+			"The race for league %s planned for %s has been postponed to %s (in %s).",
+			sessionLeagueName(tx, league, session),
+			util.Datetime(previous),
+			util.Datetime(start),
+			time.Until(start).Round(time.Second),
+		)
+	})
+}
+
+// CancelMatchSession closes a race that did not start its preparation and
+// removes its players.
+func (b *Back) CancelMatchSession(id util.UUIDAsBlob) (ret MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, err := getAdminMatchSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := session.CanAdminEdit(); err != nil {
+			return err
+		}
+
+		if err := b.cancelMatchSession(tx, &session); err != nil {
+			return err
+		}
+
+		ret = session
+		return nil
+	})
+}
+
+// cancelMatchSession closes a session that was not prepared, removes its
+// players, and tells them and the league channel.
+func (b *Back) cancelMatchSession(tx *sqlx.Tx, session *MatchSession) error {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	players := session.allPlayerIDs()
+	session.Status = MatchSessionStatusClosed
+	session.PlayerIDs = util.UUIDArrayAsJSON{}
+	session.WaitlistPlayerIDs = util.UUIDArrayAsJSON{}
+	if err := session.update(tx); err != nil {
+		return err
+	}
+
+	return b.sendMatchSessionChangeNotification(
+		tx, *session, players,
+		"The race for league %s planned for %s has been cancelled.",
+		sessionLeagueName(tx, league, *session),
+		util.Datetime(session.StartDate),
+	)
+}
+
+// KickMatchSessionPlayer removes a player from a race that did not start its
+// preparation, or from its waitlist.
+func (b *Back) KickMatchSessionPlayer(id util.UUIDAsBlob, name string) (ret MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, player, err := getAdminMatchSessionAndPlayer(tx, id, name)
+		if err != nil {
+			return err
+		}
+
+		if !session.HasPlayerID(player.ID.UUID()) && session.WaitlistPosition(player.ID.UUID()) == 0 {
+			return util.ErrPublic(fmt.Sprintf("%s is not registered for this race", player.Name))
+		}
+
+		if err := b.removeSessionPlayer(tx, &session, player); err != nil {
+			return err
+		}
+		if err := session.update(tx); err != nil {
+			return err
+		}
+
+		ret = session
+		return b.sendMatchSessionRosterNotification(tx, session, player, false)
+	})
+}
+
+// AddMatchSessionPlayer registers a player for a race that did not start its
+// preparation, regardless of the league requirements and player limit.
+func (b *Back) AddMatchSessionPlayer(id util.UUIDAsBlob, name string) (ret MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, player, err := getAdminMatchSessionAndPlayer(tx, id, name)
+		if err != nil {
+			return err
+		}
+
+		if session.HasPlayerID(player.ID.UUID()) {
+			return util.ErrPublic(fmt.Sprintf("%s is already registered for this race", player.Name))
+		}
+
+		_, err = getPlayerActiveSession(tx, player.ID)
+		if err == nil {
+			return util.ErrPublic(fmt.Sprintf("%s already has a race in progress", player.Name))
+		} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		session.RemovePlayerID(player.ID.UUID()) // from the waitlist
+		session.AddPlayerID(player.ID.UUID())
+		if err := session.update(tx); err != nil {
+			return err
+		}
+
+		ret = session
+		return b.sendMatchSessionRosterNotification(tx, session, player, true)
+	})
+}
+
+// RegenerateMatchSessionSeeds generates and sends new seeds using the current
+// generator and settings of the league for all the matches of a session where
+// no player has finished yet. It returns the number of regenerated seeds.
+func (b *Back) RegenerateMatchSessionSeeds(id util.UUIDAsBlob) (ret int, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, err := getAdminMatchSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if session.Status != MatchSessionStatusPreparing &&
+			session.Status != MatchSessionStatusInProgress {
+			return util.ErrPublic("only the seeds of a race in preparation or in progress can be regenerated")
+		}
+
+		league, err := getLeagueByID(tx, session.LeagueID)
+		if err != nil {
+			return err
+		}
+		if err := b.validateGeneratorSettings(league.Generator, league.Settings); err != nil {
+			return err
+		}
+
+		all, err := getMatchesBySessionID(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		var (
+			matches []Match
+			players []uuid.UUID
+		)
+		for _, match := range all {
+			if match.Entries[0].HasEnded() || match.Entries[1].HasEnded() {
+				continue
+			}
+
+			// google/uuid.v4 are generated using a CSPRNG
+			match.Seed = uuid.New().String()
+			match.Generator = league.Generator
+			match.Settings = league.Settings
+			match.SpoilerLog = nil
+			match.GeneratorState = nil
+			match.SeedPatch = nil
+			if err := match.update(tx); err != nil {
+				return err
+			}
+
+			matches = append(matches, match)
+			players = append(players, match.Entries[0].PlayerID.UUID(), match.Entries[1].PlayerID.UUID())
+		}
+
+		if len(matches) == 0 {
+			return util.ErrPublic("there is no race left to regenerate a seed for")
+		}
+
+		if err := b.sendMatchSessionChangeNotification(
+			tx, session, players,
+			"The seeds of the race for league %s are being regenerated, "+
+				"the players still racing will soon receive a new seed.",
+			sessionLeagueName(tx, league, session),
+		); err != nil {
+			return err
+		}
+
+		ret = len(matches)
+		return b.generateAndSendMatchesSeeds(tx, session, matches)
+	})
+}
+
+// CanAdminEdit returns an util.ErrPublic if the roster or date of the session
+// can no longer be changed.
+func (s *MatchSession) CanAdminEdit() error {
+	if s.Status != MatchSessionStatusWaiting && s.Status != MatchSessionStatusJoinable {
+		return util.ErrPublic("this race has already started its preparation or is closed")
+	}
+
+	if time.Now().After(s.StartDate.Time().Add(MatchSessionPreparationOffset)) {
+		return util.ErrPublic("this race is about to start its preparation")
+	}
+
+	return nil
+}
+
+// allPlayerIDs returns the players registered for the session followed by
+// the ones on its waitlist.
+func (s *MatchSession) allPlayerIDs() []uuid.UUID {
+	ret := make([]uuid.UUID, 0, len(s.PlayerIDs)+len(s.WaitlistPlayerIDs))
+	ret = append(ret, s.PlayerIDs...)
+	return append(ret, s.WaitlistPlayerIDs...)
+}
+
+func checkAdminMatchSessionStart(start time.Time) error {
+	if start.Before(time.Now().Add(adminMatchSessionMinNotice)) {
+		return util.ErrPublic(fmt.Sprintf(
+			"the race must start at least %s from now", util.FormatDuration(adminMatchSessionMinNotice),
+		))
+	}
+
+	return nil
+}
+
+func ensureNoMatchSessionAt(tx *sqlx.Tx, league League, divisionID util.UUIDAsBlob, start time.Time) error {
+	if _, err := getMatchSessionByStartDate(tx, league.ID, divisionID, start); err != sql.ErrNoRows {
+		if err == nil {
+			return util.ErrPublic(fmt.Sprintf(
+				"league `%s` already has a race at %s", league.ShortCode, util.Datetime(start),
+			))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func getAdminMatchSession(tx *sqlx.Tx, id util.UUIDAsBlob) (MatchSession, error) {
+	session, err := getMatchSessionByID(tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MatchSession{}, util.ErrPublic(fmt.Sprintf("unknown race '%s'", id))
+		}
+		return MatchSession{}, err
+	}
+
+	return session, nil
+}
+
+func getAdminMatchSessionAndPlayer(tx *sqlx.Tx, id util.UUIDAsBlob, name string) (
+	MatchSession, Player, error,
+) {
+	session, err := getAdminMatchSession(tx, id)
+	if err != nil {
+		return MatchSession{}, Player{}, err
+	}
+
+	if err := session.CanAdminEdit(); err != nil {
+		return MatchSession{}, Player{}, err
+	}
+
+	player, err := getPlayerByName(tx, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MatchSession{}, Player{}, util.ErrPublic(fmt.Sprintf("unknown player '%s'", name))
+		}
+		return MatchSession{}, Player{}, err
+	}
+
+	return session, player, nil
+}
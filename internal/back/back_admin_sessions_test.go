@@ -0,0 +1,141 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/util"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// nolint:funlen
+func TestMatchSessionAdmin(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	start := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+	_, err := back.CreateMatchSession("testa", time.Now().Add(10*time.Minute))
+	expectPublicError(t, "too soon", err)
+	_, err = back.CreateMatchSession("nope", start)
+	expectPublicError(t, "unknown league", err)
+
+	sessions, err := back.CreateMatchSession("testa", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Status != MatchSessionStatusWaiting {
+		t.Fatalf("unexpected sessions: %#v", sessions)
+	}
+	id := sessions[0].ID
+	_, err = back.CreateMatchSession("testa", start)
+	expectPublicError(t, "duplicate", err)
+
+	if _, err := back.AddMatchSessionPlayer(id, "Darunia"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.AddMatchSessionPlayer(id, "Darunia")
+	expectPublicError(t, "added twice", err)
+	_, err = back.AddMatchSessionPlayer(id, "Ganon")
+	expectPublicError(t, "unknown player", err)
+	_, err = back.KickMatchSessionPlayer(id, "Nabooru")
+	expectPublicError(t, "kicked a player not in the race", err)
+
+	_, err = back.PostponeMatchSession(id, start.Add(-time.Hour))
+	expectPublicError(t, "postponed to an earlier date", err)
+	postponed, err := back.PostponeMatchSession(id, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !postponed.StartDate.Time().Equal(start.Add(time.Hour)) || len(postponed.PlayerIDs) != 1 {
+		t.Errorf("unexpected postponed session: %#v", postponed)
+	}
+
+	// The original slot is kept closed so the schedule won't fill it again.
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+		placeholder, err := getMatchSessionByStartDate(tx, league.ID, util.UUIDAsBlob{}, start)
+		if err != nil {
+			return err
+		}
+		if placeholder.Status != MatchSessionStatusClosed || len(placeholder.PlayerIDs) != 0 {
+			t.Errorf("unexpected placeholder session: %#v", placeholder)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	kicked, err := back.KickMatchSessionPlayer(id, "Darunia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kicked.PlayerIDs) != 0 {
+		t.Errorf("expected the player to be kicked, got %#v", kicked.PlayerIDs)
+	}
+
+	_, err = back.RegenerateMatchSessionSeeds(id)
+	expectPublicError(t, "reseeding a waiting race", err)
+
+	if _, err := back.CancelMatchSession(id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.CancelMatchSession(id)
+	expectPublicError(t, "cancelled twice", err)
+	_, open, err := back.GetLeagueOpenMatchSessions("testa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 0 {
+		t.Errorf("expected no open session, got %#v", open)
+	}
+
+	testRegenerateMatchSessionSeeds(t, back)
+}
+
+func testRegenerateMatchSessionSeeds(t *testing.T, back *Back) {
+	session, err := createSessionAndJoin(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared, err := prepareSession(back, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := back.doMatchMaking(prepared); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond) // HACK: wait for fake seed generation
+
+	seeds := func() map[string]struct{} {
+		ret := map[string]struct{}{}
+		if err := back.transaction(func(tx *sqlx.Tx) error {
+			matches, err := getMatchesBySessionID(tx, session.ID)
+			for _, v := range matches {
+				ret[v.Seed] = struct{}{}
+			}
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+
+	before := seeds()
+	count, err := back.RegenerateMatchSessionSeeds(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(before) {
+		t.Errorf("expected %d regenerated seeds, got %d", len(before), count)
+	}
+	time.Sleep(200 * time.Millisecond) // HACK: wait for fake seed generation
+
+	for seed := range seeds() {
+		if _, ok := before[seed]; ok {
+			t.Errorf("seed %s was not regenerated", seed)
+		}
+	}
+}
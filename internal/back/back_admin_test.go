@@ -0,0 +1,119 @@
+package back // nolint:testpackage
+
+import (
+	"database/sql"
+	"errors"
+	"kaepora/internal/util"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeagueAdmin(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+
+	games, err := back.GetGames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := back.RenameGame(games[0].ID, " "); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when renaming a game to nothing, got %v", err)
+	}
+
+	league := NewLeague("The C League", "testa", games[0].ID, "test:v0", "")
+	league.Schedule.Mon = []string{"21:00 America/New_York"}
+	if err := back.CreateLeague(league); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when reusing a shortcode, got %v", err)
+	}
+
+	league.ShortCode = "testc"
+	if err := back.CreateLeague(league); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]func(*League){
+		"bad schedule":  func(l *League) { l.Schedule.Tue = []string{"21:00"} },
+		"bad generator": func(l *League) { l.Generator = "nope" },
+		"bad shortcode": func(l *League) { l.ShortCode = "Test C" },
+		"bad channel":   func(l *League) { l.AnnounceDiscordChannelID = util.NullString("#general") },
+		"unknown game":  func(l *League) { l.GameID = util.NewUUIDAsBlob() },
+	}
+	for name, edit := range invalid {
+		edited := league
+		edit(&edited)
+		if err := back.UpdateLeague("testc", edited); !errors.Is(err, util.ErrPublic("")) {
+			t.Errorf("%s: expected a public error, got %v", name, err)
+		}
+	}
+
+	league.Name = "The D League"
+	league.ShortCode = "testd"
+	league.RatingSystem = "none"
+	if err := back.UpdateLeague("testc", league); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := back.GetLeagueByShortcode("testd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "The D League" || updated.RatingSystem == "none" ||
+		!reflect.DeepEqual(updated.Schedule.Mon, league.Schedule.Mon) {
+		t.Errorf("unexpected league after update: %#v", updated)
+	}
+
+	sessions, _, err := back.GetMatchSessions(
+		time.Time{}, time.Now().AddDate(1, 0, 0),
+		[]MatchSessionStatus{MatchSessionStatusClosed},
+		"StartDate DESC",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recap, err := back.GetMatchSessionRecap(sessions[0].ID, RecapScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recap, "Player 1") {
+		t.Errorf("unexpected recap: %q", recap)
+	}
+}
+
+func TestLeagueCommands(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+
+	games, err := back.GetGames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := back.CreateLeague(NewLeague("The D League", "testd", games[0].ID, "test:v0", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.SetLeagueGenerator("testd", "nope:1.0", "s3.json"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error for an unknown generator, got %v", err)
+	}
+	if err := back.RenameLeague("testd", "teste", "The E League"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.AddLeagueSchedule("teste", "Fri", "21:00 Mars/Olympus"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error for an unknown timezone, got %v", err)
+	}
+	schedule, err := back.AddLeagueSchedule("teste", "Fri", "21:00 Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schedule.Fri, []string{"21:00 Europe/Paris"}) {
+		t.Errorf("unexpected schedule: %#v", schedule)
+	}
+
+	if err := back.DeleteLeague("testa"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when deleting a league with races, got %v", err)
+	}
+	if err := back.DeleteLeague("teste"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.GetLeagueByShortcode("teste"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the league to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package back
+
+// This file contains functions specific to the JSON API of the webserver.
+// Please do not call them outside of the webserver.
+
+import (
+	"database/sql"
+	"errors"
+	"kaepora/internal/util"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetNextMatchSessions returns the next joinable or waiting session of every
+// league that has one, indexed by league ID.
+func (b *Back) GetNextMatchSessions() (ret map[util.UUIDAsBlob]MatchSession, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		leagues, err := getLeagues(tx)
+		if err != nil {
+			return err
+		}
+
+		ret = make(map[util.UUIDAsBlob]MatchSession, len(leagues))
+		for _, league := range leagues {
+			session, err := getNextMatchSessionForLeague(tx, league.ID)
+			switch {
+			case err == nil:
+				ret[league.ID] = session
+			case errors.Is(err, sql.ErrNoRows):
+				// NOP
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetMatchSessionsPage returns a page of sessions, latest first, and the
+// total number of sessions. An empty shortcode returns the sessions of every
+// league.
+func (b *Back) GetMatchSessionsPage(shortcode string, offset, limit int) (
+	ret []MatchSession,
+	total int,
+	_ error,
+) {
+	return ret, total, b.transaction(func(tx *sqlx.Tx) error {
+		where, args := `1`, []interface{}{}
+		if shortcode != "" {
+			league, err := getLeagueByShortCode(tx, shortcode)
+			if err != nil {
+				return err
+			}
+			where, args = `LeagueID = ?`, append(args, league.ID)
+		}
+
+		if err := tx.Get(&total, `SELECT COUNT(*) FROM MatchSession WHERE `+where, args...); err != nil {
+			return err
+		}
+
+		return tx.Select(&ret, `
+            SELECT * FROM MatchSession WHERE `+where+`
+            ORDER BY DATETIME(StartDate) DESC
+            LIMIT ? OFFSET ?`,
+			append(args, limit, offset)...,
+		)
+	})
+}
+
+// GetPlayersPage returns a page of players sorted by name and the total
+// number of players.
+func (b *Back) GetPlayersPage(offset, limit int) (ret []Player, total int, _ error) {
+	return ret, total, b.transaction(func(tx *sqlx.Tx) error {
+		if err := tx.Get(&total, `SELECT COUNT(*) FROM Player`); err != nil {
+			return err
+		}
+
+		return tx.Select(&ret, `
+            SELECT * FROM Player
+            ORDER BY Name COLLATE NOCASE ASC
+            LIMIT ? OFFSET ?`,
+			limit, offset,
+		)
+	})
+}
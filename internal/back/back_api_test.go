@@ -0,0 +1,39 @@
+package back // nolint:testpackage
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestAPIPages(t *testing.T) {
+	back := createRacedTestBack(t, 3)
+
+	players, total, err := back.GetPlayersPage(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 2 || total < 3 {
+		t.Errorf("expected 2 players out of at least 3, got %d out of %d", len(players), total)
+	}
+
+	sessions, total, err := back.GetMatchSessionsPage("testa", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 || total != 3 {
+		t.Errorf("expected 3 sessions, got %d out of %d", len(sessions), total)
+	}
+
+	if _, _, err := back.GetMatchSessionsPage("nope", 0, 10); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for an unknown league, got %v", err)
+	}
+
+	next, err := back.GetNextMatchSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 0 {
+		t.Errorf("expected no upcoming session, got %d", len(next))
+	}
+}
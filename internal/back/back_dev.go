@@ -3,6 +3,7 @@ package back
 import (
 	"fmt"
 	"kaepora/internal/generator/oot"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 
 	"github.com/jmoiron/sqlx"
@@ -44,6 +45,7 @@ func (b *Back) SendDevSeed(
 			MatchSession{},
 			gen.GetDownloadURL(out.State), out,
 			player, Player{},
+			rating.Forecast{}, rating.Forecast{},
 		)
 		b.sendSpoilerLogNotification(player, seed, zlibLog)
 
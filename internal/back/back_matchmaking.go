@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"log"
+	"math"
 	"math/big"
 	"runtime"
 	"sort"
@@ -48,6 +50,21 @@ func (b *Back) generateAndSendSeeds(tx *sqlx.Tx, session MatchSession) error {
 		return errors.New("attempted to generate seeds for 0 matches")
 	}
 
+	return b.generateAndSendMatchesSeeds(tx, session, matches)
+}
+
+// generateAndSendMatchesSeeds creates the seeds for the given matches of a
+// session.
+func (b *Back) generateAndSendMatchesSeeds(tx *sqlx.Tx, session MatchSession, matches []Match) error {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return err
+	}
+	system, err := getLeagueRatingSystem(league)
+	if err != nil {
+		return err
+	}
+
 	players := make(map[util.UUIDAsBlob]Player, len(matches)*2)
 	for k := range matches {
 		for i := 0; i <= 1; i++ {
@@ -55,21 +72,27 @@ func (b *Back) generateAndSendSeeds(tx *sqlx.Tx, session MatchSession) error {
 			if err != nil {
 				return err
 			}
+			p.Rating, err = getPlayerRating(tx, p.ID, league.ID)
+			if err != nil {
+				return err
+			}
 			players[p.ID] = p
 		}
 	}
 
 	// Run in a routine to release the transaction early and write the entries.
-	go b.doParallelSeedGeneration(session, matches, players)
+	go b.doParallelSeedGeneration(session, system, matches, players)
 
 	return nil
 }
 
 // doParallelSeedGeneration generates the seeds for the given matches of the
 // given MatchSession in parallel using one worker per available CPU core.
-// players must be a prefetched Player.ID->Player map for the given matches.
+// players must be a prefetched Player.ID->Player map for the given matches,
+// with their rating.
 func (b *Back) doParallelSeedGeneration(
 	session MatchSession,
+	system rating.System,
 	matches []Match,
 	players map[util.UUIDAsBlob]Player,
 ) {
@@ -85,7 +108,7 @@ func (b *Back) doParallelSeedGeneration(
 		for v := range ch {
 			curSeedStart := time.Now()
 			log.Printf("debug: generating seed %s for match %s", v.match.Seed, v.match.ID)
-			if err := b.generateAndSendMatchSeed(v.match, v.session, v.p1, v.p2); err != nil {
+			if err := b.generateAndSendMatchSeed(v.match, v.session, system, v.p1, v.p2); err != nil {
 				log.Printf("unable to generate and send seed: %s", err)
 			}
 			log.Printf("info: generated seed %s in %s (%s)", v.match.Seed, time.Since(curSeedStart), time.Since(start))
@@ -130,6 +153,7 @@ func (b *Back) doParallelSeedGeneration(
 func (b *Back) generateAndSendMatchSeed(
 	match Match,
 	session MatchSession,
+	system rating.System,
 	p1, p2 Player,
 ) error {
 	gen, err := b.generatorFactory.NewGenerator(match.Generator)
@@ -158,6 +182,8 @@ func (b *Back) generateAndSendMatchSeed(
 		session,
 		gen.GetDownloadURL(out.State), out,
 		p1, p2,
+		rating.Predict(system, p1.Rating.SystemRating(), p2.Rating.SystemRating()),
+		rating.Predict(system, p2.Rating.SystemRating(), p1.Rating.SystemRating()),
 	)
 
 	return nil
@@ -204,7 +230,14 @@ func (b *Back) matchMakeSession(tx *sqlx.Tx, session MatchSession) error {
 		return err
 	}
 
-	pairs := pairPlayers(players)
+	placing, calibrated, err := getPlacementStatus(tx, session.LeagueID, players)
+	if err != nil {
+		return err
+	}
+
+	pairs, players := pairPlacementPlayers(players, placing, calibrated)
+	log.Printf("debug: got %d placement pairs", len(pairs))
+	pairs = append(pairs, pairPlayers(players)...)
 	log.Printf("debug: got %d players in the pool (%d pairs)", len(players), len(pairs))
 
 	for k := range pairs {
@@ -275,6 +308,88 @@ func pairPlayers(players []Player) []pair {
 	return pairs
 }
 
+// getPlacementStatus returns the IDs of the given players who are running
+// their placement races and of those whose rating is not provisional.
+func getPlacementStatus(tx *sqlx.Tx, leagueID util.UUIDAsBlob, players []Player) (
+	placing, calibrated map[util.UUIDAsBlob]struct{},
+	_ error,
+) {
+	league, err := getLeagueByID(tx, leagueID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	placing = make(map[util.UUIDAsBlob]struct{}, len(players))
+	calibrated = make(map[util.UUIDAsBlob]struct{}, len(players))
+	if league.Leaderboard.PlacementRaces == 0 {
+		return placing, calibrated, nil
+	}
+
+	for _, v := range players {
+		races, err := getPlayerRatedRacesCount(tx, v.ID, leagueID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if league.Leaderboard.IsPlacing(races) {
+			placing[v.ID] = struct{}{}
+		} else if !league.Leaderboard.IsProvisional(v.Rating.Deviation, races) {
+			calibrated[v.ID] = struct{}{}
+		}
+	}
+
+	return placing, calibrated, nil
+}
+
+// pairPlacementPlayers pairs each player running their placement races with
+// the calibrated player closest to their rating, as long as there are
+// calibrated players left. It returns the pairs and the remaining players,
+// still sorted.
+func pairPlacementPlayers(
+	players []Player,
+	placing, calibrated map[util.UUIDAsBlob]struct{},
+) ([]pair, []Player) {
+	rest := make([]Player, len(players))
+	copy(rest, players)
+
+	var pairs []pair
+	for _, p := range players {
+		if _, ok := placing[p.ID]; !ok {
+			continue
+		}
+
+		opponent := -1
+		for k := range rest {
+			if _, ok := calibrated[rest[k].ID]; !ok {
+				continue
+			}
+			if opponent == -1 ||
+				math.Abs(rest[k].Rating.Rating-p.Rating.Rating) < math.Abs(rest[opponent].Rating.Rating-p.Rating.Rating) {
+				opponent = k
+			}
+		}
+		if opponent == -1 {
+			break
+		}
+
+		pairs = append(pairs, pair{p1: p, p2: rest[opponent]})
+		rest = removePlayer(rest, rest[opponent].ID)
+		rest = removePlayer(rest, p.ID)
+	}
+
+	return pairs, rest
+}
+
+func removePlayer(players []Player, id util.UUIDAsBlob) []Player {
+	for k := range players {
+		if players[k].ID == id {
+			return append(players[:k], players[k+1:]...)
+		}
+	}
+
+	return players
+}
+
 func getSessionPlayersSortedByRating(tx *sqlx.Tx, session MatchSession) ([]Player, error) {
 	ids := session.GetPlayerIDs()
 	players := make([]Player, 0, len(ids))
@@ -104,6 +104,8 @@ func innerTestMatchMaking(t *testing.T, back *Back) {
 		NotificationTypeMatchSeed:  6, // 1 per joined player
 		NotificationTypeMatchEnd:   6, // 1 per joined player
 		NotificationTypeSpoilerLog: 6, // 1 per joined player
+
+		NotificationTypeRatingChange: 6, // 1 per player who raced
 	}
 	close(notifsDone)
 	if !reflect.DeepEqual(expected, notifs) {
@@ -391,6 +393,63 @@ func createJoinableSession(back *Back) (MatchSession, League, error) {
 	return session, league, nil
 }
 
+// startTestSession plays a session of league testa like innerTestMatchMaking
+// up to the start of the race: Rauru cancels, Impa is kicked and Zelda
+// forfeits.
+func startTestSession(back *Back) (MatchSession, error) {
+	session, err := createSessionAndJoin(back)
+	if err != nil {
+		return MatchSession{}, err
+	}
+
+	sessions, err := prepareSession(back, session)
+	if err != nil {
+		return MatchSession{}, err
+	}
+	if err := back.doMatchMaking(sessions); err != nil {
+		return MatchSession{}, err
+	}
+
+	time.Sleep(200 * time.Millisecond) // HACK: wait for fake seed generation
+
+	if err := haveZeldaForfeit(back); err != nil {
+		return MatchSession{}, err
+	}
+	if err := fakeSessionStart(back, session.ID); err != nil {
+		return MatchSession{}, err
+	}
+
+	return session, back.instantlyStartMatchSessions()
+}
+
+// raceTestSession plays a whole session, see startTestSession, the remaining
+// players complete their race and the session is closed and ranked.
+func raceTestSession(back *Back) error {
+	if _, err := startTestSession(back); err != nil {
+		return err
+	}
+	if err := makeEveryoneComplete(back); err != nil {
+		return err
+	}
+
+	return back.runPeriodicTasks()
+}
+
+// createRacedTestBack returns a fixtured back where count sessions were
+// played with raceTestSession, its notifications are discarded.
+func createRacedTestBack(t *testing.T, count int) *Back {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	for i := 0; i < count; i++ {
+		if err := raceTestSession(back); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return back
+}
+
 func createFixturedTestBack(t *testing.T) *Back {
 	f, err := ioutil.TempFile("", "*.db")
 	if err != nil {
@@ -426,6 +485,22 @@ func createFixturedTestBack(t *testing.T) *Back {
 	return back
 }
 
+// discardNotifications drains the notifications of a back for tests that
+// don't check them, sending them would block otherwise.
+func discardNotifications(back *Back) {
+	go func(c <-chan Notification) {
+		for range c { // discard
+		}
+	}(back.GetNotificationsChan())
+}
+
+func expectPublicError(t *testing.T, name string, err error) {
+	t.Helper()
+	if !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("%s: expected a public error, got %v", name, err)
+	}
+}
+
 func fixtures(tx *sqlx.Tx) error {
 	game := NewGame("The Test Game")
 	leagues := []League{
@@ -551,3 +626,35 @@ func TestPairPlayers(t *testing.T) {
 		fmt.Printf("%-3d %s\n", dist, strings.Repeat("*", distrib[dist]))
 	}
 }
+
+func TestPairPlacementPlayers(t *testing.T) {
+	ratings := []float64{1200, 1400, 1500, 1500, 1650, 1800}
+	players := make([]Player, len(ratings))
+	for k := range players {
+		players[k] = NewPlayer("player#" + strconv.Itoa(k))
+		players[k].ID = util.UUIDAsBlob{}
+		players[k].ID[0] = byte(k)
+		players[k].Rating.Rating = ratings[k]
+	}
+
+	// #2 and #3 are new, #1 and #4 are calibrated, #0 and #5 are neither.
+	placing := map[util.UUIDAsBlob]struct{}{players[2].ID: {}, players[3].ID: {}}
+	calibrated := map[util.UUIDAsBlob]struct{}{players[1].ID: {}, players[4].ID: {}}
+
+	pairs, rest := pairPlacementPlayers(players, placing, calibrated)
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 placement pairs, got %d", len(pairs))
+	}
+	if pairs[0].p1.ID != players[2].ID || pairs[0].p2.ID != players[1].ID {
+		t.Errorf("expected #2 to face the closest calibrated player #1, got %s", pairs[0].p2.Name)
+	}
+	if pairs[1].p1.ID != players[3].ID || pairs[1].p2.ID != players[4].ID {
+		t.Errorf("expected #3 to face the remaining calibrated player #4, got %s", pairs[1].p2.Name)
+	}
+	if len(rest) != 2 || rest[0].ID != players[0].ID || rest[1].ID != players[5].ID {
+		t.Errorf("expected #0 and #5 to be left for regular pairing, got %v", rest)
+	}
+	if len(players) != len(ratings) || players[1].ID[0] != 1 {
+		t.Error("the given players must not be modified")
+	}
+}
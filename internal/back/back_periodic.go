@@ -19,6 +19,40 @@ func (b *Back) runPeriodicTasks() error {
 		}
 	}()
 
+	maintenance, err := b.GetMaintenance()
+	if err != nil {
+		return err
+	}
+	if maintenance.Enabled {
+		log.Print("debug: maintenance mode enabled, not creating nor advancing races")
+	} else if err := b.advanceMatchSessions(); err != nil {
+		return err
+	}
+
+	if err := b.closeExpiredPolls(); err != nil {
+		return err
+	}
+
+	// Races already running when the maintenance started still have to be
+	// closed and ranked.
+	if err := b.endMatchSessionsAndUpdateRanks(); err != nil {
+		return err
+	}
+
+	if err := b.closeRatingPeriods(); err != nil {
+		return err
+	}
+
+	if err := b.closeSeasons(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// advanceMatchSessions creates the scheduled races and moves them through
+// their statuses up to the start of the race.
+func (b *Back) advanceMatchSessions() error {
 	if err := b.createNextScheduledMatchSessions(); err != nil {
 		return err
 	}
@@ -38,15 +72,11 @@ func (b *Back) runPeriodicTasks() error {
 		return err
 	}
 
-	if err := b.startMatchSessions(); err != nil {
+	if err := b.cancelMissedMatchSessions(); err != nil {
 		return err
 	}
 
-	if err := b.endMatchSessionsAndUpdateRanks(); err != nil {
-		return err
-	}
-
-	return nil
+	return b.startMatchSessions()
 }
 
 // createNextScheduledMatchSessions look for leagues with scheduled races and
@@ -60,25 +90,31 @@ func (b *Back) createNextScheduledMatchSessions() error {
 
 		// Create MatchSession
 		for _, league := range leagues {
-			next := league.Schedule.Next()
-			if next.IsZero() {
+			if league.Paused {
 				continue
 			}
 
-			if _, err := getMatchSessionByStartDate(tx, league.ID, next); err != sql.ErrNoRows {
-				if err == nil {
-					continue // MatchSession already exists
-				}
-
-				return err
+			next, err := league.Schedule.Next()
+			if err != nil {
+				log.Printf("error: ignored schedule of league %s: %v", league.ShortCode, err)
+				continue
+			}
+			if next.IsZero() {
+				continue
 			}
 
-			sess := NewMatchSession(league.ID, next)
-			if err := sess.insert(tx); err != nil {
+			divisions, err := getDivisionsByLeagueID(tx, league.ID)
+			if err != nil {
 				return err
 			}
-			if err := b.sendSessionStatusUpdateNotification(tx, sess); err != nil {
-				return err
+			if len(divisions) == 0 {
+				divisions = []Division{{}} // single session without division
+			}
+
+			for _, division := range divisions {
+				if err := b.createScheduledMatchSession(tx, league, division, next); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -86,6 +122,31 @@ func (b *Back) createNextScheduledMatchSessions() error {
 	})
 }
 
+// createScheduledMatchSession creates the session for a league division if it
+// does not exist yet, division is zero for leagues without divisions.
+func (b *Back) createScheduledMatchSession(
+	tx *sqlx.Tx,
+	league League,
+	division Division,
+	next time.Time,
+) error {
+	if _, err := getMatchSessionByStartDate(tx, league.ID, division.ID, next); err != sql.ErrNoRows {
+		if err == nil {
+			return nil // MatchSession already exists
+		}
+
+		return err
+	}
+
+	sess := NewMatchSession(league.ID, next)
+	sess.DivisionID = util.NewNullUUIDAsBlob(division.ID)
+	if err := sess.insert(tx); err != nil {
+		return err
+	}
+
+	return b.sendSessionStatusUpdateNotification(tx, sess)
+}
+
 // makeMatchSessionsJoinable looks for races that have reached the time at
 // which they can be joined by players and update their status.
 func (b *Back) makeMatchSessionsJoinable() error {
@@ -98,6 +159,7 @@ func (b *Back) makeMatchSessionsJoinable() error {
             WHERE DATETIME(StartDate) > DATETIME(?)
               AND DATETIME(StartDate) <= DATETIME(?)
               AND Status = ?
+              AND LeagueID NOT IN(SELECT ID FROM League WHERE Paused = 1)
         `,
 			util.TimeAsDateTimeTZ(min),
 			util.TimeAsDateTimeTZ(max),
@@ -146,6 +208,19 @@ func (b *Back) makeMatchSessionsPreparing() ([]MatchSession, error) {
 				continue
 			}
 
+			// The race is about to start, no spot will free up anymore.
+			for _, id := range sessions[k].WaitlistPlayerIDs {
+				player, err := getPlayerByID(tx, util.UUIDAsBlob(id))
+				if err != nil {
+					return err
+				}
+
+				if err := b.sendWaitlistNotification(tx, sessions[k], player, false); err != nil {
+					return err
+				}
+			}
+			sessions[k].WaitlistPlayerIDs = util.UUIDArrayAsJSON{}
+
 			log.Printf("debug: put session %s in MatchSessionStatusPreparing", sessions[k].ID)
 			sessions[k].Status = MatchSessionStatusPreparing
 			if err := sessions[k].update(tx); err != nil {
@@ -169,6 +244,48 @@ func (b *Back) makeMatchSessionsPreparing() ([]MatchSession, error) {
 	return sessions, nil
 }
 
+// cancelMissedMatchSessions closes the races that reached their start date
+// without being prepared, eg. because their league was paused or the bot was
+// down, so their players are free to join other races.
+func (b *Back) cancelMissedMatchSessions() error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		var sessions []MatchSession
+		if err := tx.Select(&sessions, `
+            SELECT * FROM MatchSession
+            WHERE DATETIME(StartDate) <= DATETIME(?)
+              AND Status IN(?, ?)
+              AND LeagueID NOT IN(SELECT ID FROM League WHERE Paused = 1)
+        `,
+			util.TimeAsDateTimeTZ(time.Now()),
+			MatchSessionStatusWaiting,
+			MatchSessionStatusJoinable,
+		); err != nil {
+			return err
+		}
+
+		for k := range sessions {
+			log.Printf("info: closing missed session %s", sessions[k].ID)
+
+			// Don't announce races nobody remembers after a long downtime.
+			if time.Since(sessions[k].StartDate.Time()) > 24*time.Hour {
+				sessions[k].Status = MatchSessionStatusClosed
+				sessions[k].PlayerIDs = util.UUIDArrayAsJSON{}
+				sessions[k].WaitlistPlayerIDs = util.UUIDArrayAsJSON{}
+				if err := sessions[k].update(tx); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := b.cancelMatchSession(tx, &sessions[k]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 func (b *Back) startMatchSessions() error {
 	var sessions []MatchSession
 	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
@@ -276,7 +393,8 @@ func (b *Back) countdownAndStartMatchSession(session MatchSession) {
 
 func getMatchSessionsToStart(tx *sqlx.Tx) ([]MatchSession, error) {
 	query := `SELECT * FROM MatchSession
-    WHERE DATETIME(StartDate) <= DATETIME(?) AND Status = ?`
+    WHERE DATETIME(StartDate) <= DATETIME(?) AND Status = ?
+      AND LeagueID NOT IN(SELECT ID FROM League WHERE Paused = 1)`
 	var sessions []MatchSession
 	if err := tx.Select(
 		&sessions, query,
@@ -339,6 +457,7 @@ loop:
 func (b *Back) endMatchSessionsAndUpdateRanks() error {
 	var sessions []MatchSession
 	var matches map[util.UUIDAsBlob][]Match
+	changes := map[util.UUIDAsBlob]map[util.UUIDAsBlob]RatingChange{} // by session ID
 
 	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
 		sessions, matches, err = getMatchSessionsToEnd(tx)
@@ -358,11 +477,42 @@ func (b *Back) endMatchSessionsAndUpdateRanks() error {
 	}
 
 	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
-		now := time.Now()
 		for k := range sessions {
-			if err := b.updateLeagueRankings(tx, sessions[k].LeagueID, now); err != nil {
+			league, err := getLeagueByID(tx, sessions[k].LeagueID)
+			if err != nil {
+				return err
+			}
+			system, err := getLeagueRatingSystem(league)
+			if err != nil {
+				return err
+			}
+			calendar, err := getRatingCalendar(tx, league)
+			if err != nil {
+				return err
+			}
+
+			before, err := getPlayerRatingsByPlayerID(tx, league.ID)
+			if err != nil {
+				return err
+			}
+
+			// Sessions can span multiple periods, recompute all of them.
+			if err := b.updateLeagueRankingsSince(
+				tx, league, system, calendar, sessions[k].StartDate.Time(),
+			); err != nil {
 				return err
 			}
+
+			after, err := getPlayerRatingsByPlayerID(tx, league.ID)
+			if err != nil {
+				return err
+			}
+
+			var playerIDs []util.UUIDAsBlob
+			for _, match := range matches[sessions[k].ID] {
+				playerIDs = append(playerIDs, match.Entries[0].PlayerID, match.Entries[1].PlayerID)
+			}
+			changes[sessions[k].ID] = getRatingChanges(playerIDs, before, after)
 		}
 
 		return nil
@@ -379,7 +529,13 @@ func (b *Back) endMatchSessionsAndUpdateRanks() error {
 
 			if err := b.sendSessionRecapNotification(
 				tx, sessions[k], matches[sessions[k].ID],
-				RecapScopePublic, nil,
+				RecapScopePublic, nil, changes[sessions[k].ID],
+			); err != nil {
+				return err
+			}
+
+			if err := b.sendRatingChangeNotifications(
+				tx, sessions[k].LeagueID, changes[sessions[k].ID],
 			); err != nil {
 				return err
 			}
@@ -417,6 +573,7 @@ func getMatchSessionsToPrepare(tx *sqlx.Tx) ([]MatchSession, error) {
             WHERE DATETIME(StartDate) > DATETIME(?)
               AND DATETIME(StartDate) <= DATETIME(?)
               AND Status = ?
+              AND LeagueID NOT IN(SELECT ID FROM League WHERE Paused = 1)
         `,
 		util.TimeAsDateTimeTZ(min),
 		util.TimeAsDateTimeTZ(max),
@@ -428,3 +585,75 @@ func getMatchSessionsToPrepare(tx *sqlx.Tx) ([]MatchSession, error) {
 
 	return sessions, nil
 }
+
+// closeRatingPeriods looks for leagues whose rating period ended since the
+// last run and performs the end of period tasks.
+func (b *Back) closeRatingPeriods() error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		leagues, err := getLeagues(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, league := range leagues {
+			calendar, err := getRatingCalendar(tx, league)
+			if err != nil {
+				return err
+			}
+
+			current := calendar.start(time.Now())
+			previous := league.RatingPeriodStartedAt
+			if previous.Valid && !previous.Time.Time().Before(current) {
+				continue
+			}
+
+			// Nothing to close on the very first run, only remember the period.
+			if previous.Valid {
+				// Seasons end with a rating period, archive them with the
+				// divisions they ended with.
+				if err := b.closeLeagueSeasons(tx, league.ID, current); err != nil {
+					return err
+				}
+
+				if err := b.closeLeagueRatingPeriod(tx, league, calendar, previous.Time.Time()); err != nil {
+					return err
+				}
+			}
+
+			league.RatingPeriodStartedAt = util.NewNullTimeAsTimestamp(current)
+			if err := league.update(tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// closeLeagueRatingPeriod performs the end of period tasks for the period
+// that started at the given date. Ratings are already up to date as they are
+// computed after each session, only the deviation of everyone has to grow
+// for the new period.
+func (b *Back) closeLeagueRatingPeriod(
+	tx *sqlx.Tx,
+	league League,
+	calendar ratingCalendar,
+	periodStart time.Time,
+) error {
+	log.Printf("info: closing rating period %s for league %s", periodStart, league.ShortCode)
+
+	if err := b.reshuffleDivisions(tx, league); err != nil {
+		return err
+	}
+
+	system, err := getLeagueRatingSystem(league)
+	if err != nil {
+		return err
+	}
+
+	if err := b.updateLeagueRankings(tx, league, system, calendar, time.Now()); err != nil {
+		return err
+	}
+
+	return b.sendLeaderboardMoversNotification(tx, league, calendar, periodStart)
+}
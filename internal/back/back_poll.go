@@ -0,0 +1,310 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// pollMaxSlots caps the number of candidate dates of a poll so it stays
+// readable in a single Discord message.
+const pollMaxSlots = 9
+
+// OpenPoll opens a poll on the date of an extra race for a league, the race
+// is created at the first of the given dates to reach quorum votes.
+func (b *Back) OpenPoll(shortcode string, quorum int, starts []time.Time) (ret Poll, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if league.Paused {
+			return util.ErrPublic(fmt.Sprintf(
+				"league `%s` is paused, resume it before opening a poll", league.ShortCode,
+			))
+		}
+
+		if quorum < 2 {
+			return util.ErrPublic("the quorum must be at least 2 players")
+		}
+		if len(starts) == 0 || len(starts) > pollMaxSlots {
+			return util.ErrPublic(fmt.Sprintf("a poll must have between 1 and %d dates", pollMaxSlots))
+		}
+
+		if _, err := getLeagueOpenPoll(tx, league.ID); err != sql.ErrNoRows {
+			if err == nil {
+				return util.ErrPublic(fmt.Sprintf(
+					"league `%s` already has an open poll, close it first", league.ShortCode,
+				))
+			}
+			return err
+		}
+
+		if err := checkPollStarts(tx, league, starts); err != nil {
+			return err
+		}
+
+		ret = NewPoll(league.ID, quorum, starts)
+		if err := ret.insert(tx); err != nil {
+			return err
+		}
+
+		b.sendPollOpenNotification(league, ret)
+		return nil
+	})
+}
+
+// ClosePoll closes the open poll of a league without creating its race.
+func (b *Back) ClosePoll(shortcode string) (ret Poll, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, poll, err := getLeagueAndOpenPoll(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		poll.ClosedAt = util.NewNullTimeAsTimestamp(time.Now())
+		if err := poll.update(tx); err != nil {
+			return err
+		}
+
+		ret = poll
+		b.sendPollResultNotification(league, poll)
+		return nil
+	})
+}
+
+// GetLeagueOpenPoll returns the open poll of a league, or an util.ErrPublic
+// if there is none.
+func (b *Back) GetLeagueOpenPoll(shortcode string) (league League, poll Poll, _ error) {
+	return league, poll, b.transaction(func(tx *sqlx.Tx) (err error) {
+		league, poll, err = getLeagueAndOpenPoll(tx, shortcode)
+		return err
+	})
+}
+
+// GetOpenPolls returns every open poll along with their leagues.
+func (b *Back) GetOpenPolls() ([]Poll, map[util.UUIDAsBlob]League, error) {
+	var (
+		polls   []Poll
+		leagues = map[util.UUIDAsBlob]League{}
+	)
+
+	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
+		polls, err = getOpenPolls(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range polls {
+			if leagues[v.LeagueID], err = getLeagueByID(tx, v.LeagueID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return polls, leagues, nil
+}
+
+// VotePoll adds or removes (vote is false) the vote of a player for a poll
+// slot. The first slot to reach the quorum closes the poll and gets its race
+// created and announced.
+func (b *Back) VotePoll(player Player, slotID util.UUIDAsBlob, vote bool) (ret Poll, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		var pollID util.UUIDAsBlob
+		if err := tx.Get(&pollID, `SELECT PollID FROM PollSlot WHERE ID = ? LIMIT 1`, slotID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic("unknown poll date")
+			}
+			return err
+		}
+
+		poll, err := getPollByID(tx, pollID)
+		if err != nil {
+			return err
+		}
+		if poll.ClosedAt.Valid {
+			return util.ErrPublic("this poll is closed")
+		}
+
+		// Voting amounts to joining the race in advance, only removing a vote
+		// is always allowed.
+		if vote {
+			if err := checkCanVotePoll(tx, player, poll); err != nil {
+				return err
+			}
+		}
+
+		for k := range poll.Slots {
+			slot := &poll.Slots[k]
+			if slot.ID != slotID {
+				continue
+			}
+
+			if vote && !slot.IsVotable() {
+				return util.ErrPublic(fmt.Sprintf(
+					"the race of %s would start too soon, you can no longer vote for it",
+					util.Datetime(slot.StartDate),
+				))
+			}
+
+			slot.setVote(player.ID.UUID(), vote)
+			if vote && len(slot.VoterIDs) >= poll.Quorum {
+				if err := b.choosePollSlot(tx, &poll, k); err != nil {
+					return err
+				}
+			}
+		}
+
+		ret = poll
+		return poll.update(tx)
+	})
+}
+
+// checkCanVotePoll returns an util.ErrPublic if the player would not be able
+// to join the races of the league of the poll.
+func checkCanVotePoll(tx *sqlx.Tx, player Player, poll Poll) error {
+	league, err := getLeagueByID(tx, poll.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	maintenance, err := getMaintenance(tx)
+	if err != nil {
+		return err
+	}
+	if err := maintenance.Error(league); err != nil {
+		return err
+	}
+
+	return league.Requirements.check(tx, player)
+}
+
+// choosePollSlot closes a poll with the given slot and creates its race.
+func (b *Back) choosePollSlot(tx *sqlx.Tx, poll *Poll, k int) error {
+	league, err := getLeagueByID(tx, poll.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	poll.Slots[k].Chosen = true
+	poll.ClosedAt = util.NewNullTimeAsTimestamp(time.Now())
+	b.sendPollResultNotification(league, *poll)
+
+	divisions, err := getDivisionsByLeagueID(tx, league.ID)
+	if err != nil {
+		return err
+	}
+	if len(divisions) == 0 {
+		divisions = []Division{{}} // single session without division
+	}
+
+	for _, division := range divisions {
+		if err := b.createScheduledMatchSession(tx, league, division, poll.Slots[k].StartDate.Time()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeExpiredPolls closes the polls that can no longer receive votes as all
+// their dates are too close or past.
+func (b *Back) closeExpiredPolls() error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		polls, err := getOpenPolls(tx)
+		if err != nil {
+			return err
+		}
+
+	polls:
+		for _, poll := range polls {
+			for _, v := range poll.Slots {
+				if v.IsVotable() {
+					continue polls
+				}
+			}
+
+			poll.ClosedAt = util.NewNullTimeAsTimestamp(time.Now())
+			if err := poll.update(tx); err != nil {
+				return err
+			}
+
+			league, err := getLeagueByID(tx, poll.LeagueID)
+			if err != nil {
+				return err
+			}
+			b.sendPollResultNotification(league, poll)
+		}
+
+		return nil
+	})
+}
+
+// checkPollStarts sorts the dates of a new poll and ensures they are distinct
+// and could each get a race created.
+func checkPollStarts(tx *sqlx.Tx, league League, starts []time.Time) error {
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+
+	divisions, err := getDivisionsByLeagueID(tx, league.ID)
+	if err != nil {
+		return err
+	}
+	if len(divisions) == 0 {
+		divisions = []Division{{}}
+	}
+
+	for k, start := range starts {
+		if k > 0 && start.Equal(starts[k-1]) {
+			return util.ErrPublic(fmt.Sprintf("%s is given twice", util.Datetime(start)))
+		}
+
+		if err := checkAdminMatchSessionStart(start); err != nil {
+			return err
+		}
+
+		for _, division := range divisions {
+			if err := ensureNoMatchSessionAt(tx, league, division.ID, start); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getLeagueAndOpenPoll(tx *sqlx.Tx, shortcode string) (League, Poll, error) {
+	league, err := getLeagueByShortCode(tx, shortcode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return League{}, Poll{}, util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+		}
+		return League{}, Poll{}, err
+	}
+
+	poll, err := getLeagueOpenPoll(tx, league.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return League{}, Poll{}, util.ErrPublic(fmt.Sprintf(
+				"there is no open poll for league `%s`", league.ShortCode,
+			))
+		}
+		return League{}, Poll{}, err
+	}
+
+	return league, poll, nil
+}
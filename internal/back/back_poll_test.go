@@ -0,0 +1,158 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// nolint:funlen
+func TestPoll(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	var darunia, nabooru Player
+	if err := back.transaction(func(tx *sqlx.Tx) (err error) {
+		if darunia, err = getPlayerByName(tx, "Darunia"); err != nil {
+			return err
+		}
+		nabooru, err = getPlayerByName(tx, "Nabooru")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+	second := first.Add(24 * time.Hour)
+	_, err := back.OpenPoll("testa", 1, []time.Time{first})
+	expectPublicError(t, "quorum too low", err)
+	_, err = back.OpenPoll("testa", 2, nil)
+	expectPublicError(t, "no dates", err)
+	_, err = back.OpenPoll("testa", 2, []time.Time{first, first})
+	expectPublicError(t, "duplicate dates", err)
+	_, err = back.OpenPoll("testa", 2, []time.Time{time.Now().Add(time.Minute)})
+	expectPublicError(t, "too soon", err)
+	_, err = back.OpenPoll("nope", 2, []time.Time{first})
+	expectPublicError(t, "unknown league", err)
+	if err := back.SetLeaguePaused("testa", true); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.OpenPoll("testa", 2, []time.Time{first})
+	expectPublicError(t, "paused league", err)
+	if err := back.SetLeaguePaused("testa", false); err != nil {
+		t.Fatal(err)
+	}
+
+	poll, err := back.OpenPoll("testa", 2, []time.Time{second, first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(poll.Slots) != 2 || !poll.Slots[0].StartDate.Time().Equal(first) {
+		t.Fatalf("unexpected poll: %#v", poll)
+	}
+	_, err = back.OpenPoll("testa", 2, []time.Time{first})
+	expectPublicError(t, "second open poll", err)
+
+	// A player can change their mind, only the votes of a slot count.
+	vote := func(player Player, slot int, vote bool) Poll {
+		t.Helper()
+		poll, err := back.VotePoll(player, poll.Slots[slot].ID, vote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return poll
+	}
+	// Voting is refused to the players who could not join the race.
+	if err := back.SetMaintenance(true, ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.VotePoll(darunia, poll.Slots[0].ID, true)
+	expectPublicError(t, "vote during maintenance", err)
+	if err := back.SetMaintenance(false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.SetLeagueRequirement("testa", "inviteonly", "true"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.VotePoll(darunia, poll.Slots[0].ID, true)
+	expectPublicError(t, "vote without an invite", err)
+	if _, err := back.SetLeagueRequirement("testa", "inviteonly", "false"); err != nil {
+		t.Fatal(err)
+	}
+
+	vote(darunia, 0, true)
+	vote(darunia, 0, false)
+	vote(darunia, 1, true)
+	if poll := vote(nabooru, 0, true); poll.ClosedAt.Valid {
+		t.Fatalf("poll closed without reaching the quorum: %#v", poll)
+	}
+
+	closed := vote(darunia, 0, true)
+	if slot, ok := closed.ChosenSlot(); !closed.ClosedAt.Valid || !ok || !slot.StartDate.Time().Equal(first) {
+		t.Fatalf("expected the poll to be closed on the first date: %#v", closed)
+	}
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+		session, err := getMatchSessionByStartDate(tx, league.ID, util.UUIDAsBlob{}, first)
+		if err != nil {
+			return err
+		}
+		if session.Status != MatchSessionStatusWaiting {
+			t.Errorf("unexpected session: %#v", session)
+		}
+
+		saved, err := getPollByID(tx, poll.ID)
+		if err != nil {
+			return err
+		}
+		if slot, ok := saved.ChosenSlot(); !ok || len(slot.VoterIDs) != 2 || len(saved.Slots[1].VoterIDs) != 1 {
+			t.Errorf("unexpected saved poll: %#v", saved)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = back.VotePoll(nabooru, poll.Slots[1].ID, true)
+	expectPublicError(t, "vote on a closed poll", err)
+	_, _, err = back.GetLeagueOpenPoll("testa")
+	expectPublicError(t, "no open poll", err)
+
+	testClosePolls(t, back, second)
+}
+
+func testClosePolls(t *testing.T, back *Back, start time.Time) {
+	if _, err := back.OpenPoll("testa", 2, []time.Time{start}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.ClosePoll("testa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.ClosePoll("testa"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when closing twice, got %v", err)
+	}
+
+	// Polls whose dates are all too close are closed by the periodic tasks.
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+		poll := NewPoll(league.ID, 2, []time.Time{time.Now().Add(time.Minute)})
+		return poll.insert(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.closeExpiredPolls(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := back.GetLeagueOpenPoll("testa"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected the expired poll to be closed, got %v", err)
+	}
+}
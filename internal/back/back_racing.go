@@ -47,10 +47,29 @@ func (b *Back) JoinCurrentMatchSessionByShortcode(player Player, shortcode strin
 	return session, league, nil
 }
 
+// joinCurrentMatchSessionTx adds the player to the next joinable session of
+// the league, or to its waitlist if the session is full.
 func joinCurrentMatchSessionTx(
 	tx *sqlx.Tx, player Player, league League,
 ) (MatchSession, error) {
-	session, err := getNextJoinableMatchSessionForLeague(tx, league.ID)
+	maintenance, err := getMaintenance(tx)
+	if err != nil {
+		return MatchSession{}, err
+	}
+	if err := maintenance.Error(league); err != nil {
+		return MatchSession{}, err
+	}
+
+	if err := league.Requirements.check(tx, player); err != nil {
+		return MatchSession{}, err
+	}
+
+	division, err := getOrAssignPlayerDivision(tx, player.ID, league.ID)
+	if err != nil {
+		return MatchSession{}, err
+	}
+
+	session, err := getNextJoinableMatchSessionForLeague(tx, league.ID, division.ID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return MatchSession{},
@@ -65,11 +84,22 @@ func joinCurrentMatchSessionTx(
 		))
 	}
 
+	if pos := session.WaitlistPosition(player.ID.UUID()); pos > 0 {
+		return MatchSession{}, util.ErrPublic(fmt.Sprintf(
+			"you are already on the waitlist for the next %s race (position %d)", league.Name, pos,
+		))
+	}
+
 	if err := ensurePlayerHasNoActiveMatch(tx, player.ID); err != nil {
 		return MatchSession{}, err
 	}
 
-	session.AddPlayerID(player.ID.UUID())
+	if max := league.Requirements.MaxPlayersPerSession; max > 0 && len(session.PlayerIDs) >= max {
+		session.WaitlistPlayerIDs = append(session.WaitlistPlayerIDs, player.ID.UUID())
+	} else {
+		session.AddPlayerID(player.ID.UUID())
+	}
+
 	if err := session.update(tx); err != nil {
 		return MatchSession{}, err
 	}
@@ -92,7 +122,10 @@ func ensurePlayerHasNoActiveMatch(tx *sqlx.Tx, playerID util.UUIDAsBlob) error {
 }
 
 func (b *Back) CompleteActiveMatch(player Player) (Match, error) {
-	var ret Match
+	var (
+		ret  Match
+		live LiveEvent
+	)
 	if err := b.transaction(func(tx *sqlx.Tx) error {
 		match, self, against, err := getActiveMatchAndEntriesForPlayer(tx, player)
 		if err != nil {
@@ -115,10 +148,12 @@ func (b *Back) CompleteActiveMatch(player Player) (Match, error) {
 		}
 
 		ret = match
-		return nil
+		live, err = newEntryEndLiveEvent(tx, match, player, self)
+		return err
 	}); err != nil {
 		return Match{}, err
 	}
+	b.publishLiveEvent(live)
 
 	if err := b.sendPrivateRecapForSessionID(ret.MatchSessionID, player); err != nil {
 		return Match{}, err
@@ -150,7 +185,7 @@ func (b *Back) sendPrivateRecapForSessionID(sessionID util.UUIDAsBlob, player Pl
 
 		return b.sendSessionRecapNotification(
 			tx, session, matches,
-			RecapScopeRunner, &player.DiscordID.String,
+			RecapScopeRunner, &player.DiscordID.String, nil,
 		)
 	})
 }
@@ -159,7 +194,7 @@ func (b *Back) CancelActiveMatchSession(player Player) (MatchSession, error) {
 	var ret MatchSession
 
 	if err := b.transaction(func(tx *sqlx.Tx) error {
-		session, err := getPlayerActiveSession(tx, player.ID)
+		session, err := getPlayerCurrentSession(tx, player.ID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return util.ErrPublic("you are not in any active race right now")
@@ -171,7 +206,10 @@ func (b *Back) CancelActiveMatchSession(player Player) (MatchSession, error) {
 			return err
 		}
 
-		session.RemovePlayerID(player.ID.UUID())
+		if err := b.removeSessionPlayer(tx, &session, player); err != nil {
+			return err
+		}
+
 		if err := session.update(tx); err != nil {
 			return err
 		}
@@ -185,8 +223,46 @@ func (b *Back) CancelActiveMatchSession(player Player) (MatchSession, error) {
 	return ret, nil
 }
 
+// removeSessionPlayer removes a player from a session or its waitlist and
+// gives their spot to the first player on the waitlist that is not racing
+// elsewhere. The session is not saved.
+func (b *Back) removeSessionPlayer(tx *sqlx.Tx, session *MatchSession, player Player) error {
+	hadSpot := session.HasPlayerID(player.ID.UUID())
+	session.RemovePlayerID(player.ID.UUID())
+
+	if !hadSpot {
+		return nil
+	}
+
+	for {
+		id, ok := session.popWaitlist()
+		if !ok {
+			return nil
+		}
+
+		// Players can wait on several races, skip the ones who got a spot
+		// in another race since.
+		if _, err := getPlayerActiveSession(tx, util.UUIDAsBlob(id)); err == nil {
+			session.RemovePlayerID(id)
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		promoted, err := getPlayerByID(tx, util.UUIDAsBlob(id))
+		if err != nil {
+			return err
+		}
+
+		return b.sendWaitlistNotification(tx, *session, promoted, true)
+	}
+}
+
 func (b *Back) ForfeitActiveMatch(player Player) (Match, error) {
-	var ret Match
+	var (
+		ret  Match
+		live LiveEvent
+	)
 	if err := b.transaction(func(tx *sqlx.Tx) error {
 		match, self, against, err := getActiveMatchAndEntriesForPlayer(tx, player)
 		if err != nil {
@@ -205,10 +281,12 @@ func (b *Back) ForfeitActiveMatch(player Player) (Match, error) {
 		}
 
 		ret = match
-		return nil
+		live, err = newEntryEndLiveEvent(tx, match, player, self)
+		return err
 	}); err != nil {
 		return Match{}, err
 	}
+	b.publishLiveEvent(live)
 
 	if err := b.sendPrivateRecapForSessionID(ret.MatchSessionID, player); err != nil {
 		return Match{}, err
@@ -1,60 +1,152 @@
 package back
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"log"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	glicko "github.com/zelenin/go-glicko2"
 )
 
-// updateRankingsForMatchSession updates every player in a League with the
-// outcome from the matches in the session.
-// The matches slice is an implementation detail and contains all matches of
-// the session, since the caller already has the list we don't want to fetch
-// them again.
-func (b *Back) updateLeagueRankings(tx *sqlx.Tx, leagueID util.UUIDAsBlob, now time.Time) error {
-	previousPeriodStart := util.TimeAsTimestamp(previousPeriodStart(now))
-	currentPeriodStart := util.TimeAsTimestamp(currentPeriodStart(now))
-	nextPeriodStart := util.TimeAsTimestamp(nextPeriodStart(now))
-	log.Printf("debug: update league rankings for period %s to %s", currentPeriodStart.Time(), nextPeriodStart.Time())
-
-	glickoPlayers, err := getGlickoPlayersForLeague(tx, leagueID, previousPeriodStart)
+// updateLeagueRankings recomputes the ratings of every player in a League
+// for the rating period containing the given date using the given rating
+// system. Players who did not race during the period see their deviation
+// grow as if the period was over, once more for each period they skipped
+// since their last race.
+func (b *Back) updateLeagueRankings(
+	tx *sqlx.Tx,
+	league League,
+	system rating.System,
+	calendar ratingCalendar,
+	now time.Time,
+) error {
+	periodStart := calendar.start(now)
+	periodEnd := calendar.next(now)
+	log.Printf(
+		"debug: update league rankings for period %s to %s using %s",
+		periodStart, periodEnd, system.ID(),
+	)
+
+	ratings, err := getRatingsBefore(tx, league.ID, system, calendar, periodStart)
 	if err != nil {
 		return fmt.Errorf("unable to fetch ratings: %w", err)
 	}
-	log.Printf("debug: got %d ratings from previous period", len(glickoPlayers))
+	log.Printf("debug: got %d ratings from previous periods", len(ratings))
 
-	matches, err := getMatchesByPeriod(tx, leagueID, currentPeriodStart, nextPeriodStart)
+	matches, err := getMatchesByPeriod(
+		tx, league.ID,
+		util.TimeAsTimestamp(periodStart), util.TimeAsTimestamp(periodEnd),
+	)
 	if err != nil {
 		return fmt.Errorf("unable to fetch matches for period: %w", err)
 	}
 
-	computePeriod(leagueID, matches, glickoPlayers)
-	if err := b.updateRunningPeriodRatings(tx, leagueID, glickoPlayers); err != nil {
+	computePeriod(system, matches, ratings)
+
+	played := make(map[util.UUIDAsBlob]rating.Rating, 2*len(matches))
+	for k := range matches {
+		for _, v := range matches[k].Entries {
+			played[v.PlayerID] = ratings[v.PlayerID]
+		}
+	}
+	for playerID, v := range ratings {
+		if _, ok := played[playerID]; !ok {
+			ratings[playerID] = system.Inflate(v, 1)
+		}
+	}
+
+	if err := b.updateRunningPeriodRatings(tx, league.ID, ratings); err != nil {
 		return err
 	}
 
-	return b.closeRatingPeriod(tx, currentPeriodStart, leagueID, glickoPlayers)
+	return b.closeRatingPeriod(tx, util.TimeAsTimestamp(periodStart), league.ID, played)
 }
 
-func (b *Back) updateRunningPeriodRatings(
+// getRatingsBefore returns the ratings of every player of a league at the
+// start of the given period, indexed by player ID. Deviations are inflated
+// for the periods skipped since the last race of each player and season
+// resets are applied.
+func getRatingsBefore(
 	tx *sqlx.Tx,
 	leagueID util.UUIDAsBlob,
-	glickoPlayers map[util.UUIDAsBlob]*glicko.Player,
+	system rating.System,
+	calendar ratingCalendar,
+	periodStart time.Time,
+) (map[util.UUIDAsBlob]rating.Rating, error) {
+	history, err := getLatestRatings(
+		tx, leagueID,
+		util.TimeAsTimestamp(time.Unix(0, 0)), util.TimeAsTimestamp(periodStart),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	seasons, err := getSeasonsByLeagueID(tx, leagueID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch seasons: %w", err)
+	}
+
+	ret := make(map[util.UUIDAsBlob]rating.Rating, len(history))
+	for _, v := range history {
+		last := v.RatingPeriodStartedAt.Time()
+		r := system.Inflate(v.SystemRating(), calendar.count(last, periodStart)-1)
+
+		// Seasons are sorted by descending start date.
+		for i := len(seasons) - 1; i >= 0; i-- {
+			start := seasons[i].StartDate.Time()
+			if start.After(last) && !start.After(periodStart) {
+				r = seasons[i].reset(system, r)
+			}
+		}
+
+		ret[v.PlayerID] = r
+	}
+
+	return ret, nil
+}
+
+// updateLeagueRankingsSince recomputes the ratings of every rating period
+// from the one containing the given date to the current one.
+func (b *Back) updateLeagueRankingsSince(
+	tx *sqlx.Tx,
+	league League,
+	system rating.System,
+	calendar ratingCalendar,
+	since time.Time,
 ) error {
-	log.Printf("debug: updating %d PlayerRating entries", len(glickoPlayers))
-	for playerID, glickoPlayer := range glickoPlayers {
-		rating := NewPlayerRating(playerID, leagueID)
-		glickoRating := glickoPlayer.Rating()
+	return calendar.forEachPeriodSince(since, func(period time.Time) error {
+		return b.updateLeagueRankings(tx, league, system, calendar, period)
+	})
+}
 
-		rating.Rating = glickoRating.R()
-		rating.Deviation = glickoRating.Rd()
-		rating.Volatility = glickoRating.Sigma()
+// getLeagueRatingSystem returns the System used by a league.
+func getLeagueRatingSystem(league League) (rating.System, error) {
+	system, err := rating.New(league.RatingSystem)
+	if err != nil {
+		return nil, fmt.Errorf("league %s: %w", league.ShortCode, err)
+	}
+
+	return system, nil
+}
+
+func (b *Back) updateRunningPeriodRatings(
+	tx *sqlx.Tx,
+	leagueID util.UUIDAsBlob,
+	ratings map[util.UUIDAsBlob]rating.Rating,
+) error {
+	log.Printf("debug: updating %d PlayerRating entries", len(ratings))
+	for playerID, v := range ratings {
+		r := NewPlayerRating(playerID, leagueID)
+		r.SetRating(v)
 
-		if err := rating.upsert(tx); err != nil {
+		if err := r.upsert(tx); err != nil {
 			return fmt.Errorf("unable to update rating: %w", err)
 		}
 	}
@@ -63,39 +155,24 @@ func (b *Back) updateRunningPeriodRatings(
 }
 
 func computePeriod(
-	leagueID util.UUIDAsBlob,
+	system rating.System,
 	matches []Match,
-	glickoPlayers map[util.UUIDAsBlob]*glicko.Player,
+	ratings map[util.UUIDAsBlob]rating.Rating,
 ) {
-	getGlickoPlayer := func(playerID, leagueID util.UUIDAsBlob) *glicko.Player {
-		p, ok := glickoPlayers[playerID]
-		if !ok {
-			p = glicko.NewPlayer(NewPlayerRating(playerID, leagueID).GlickoRating())
-			glickoPlayers[playerID] = p
-		}
-		return p
-	}
-
-	period := glicko.NewRatingPeriod()
+	results := make([]rating.Match, 0, len(matches))
 	for k := range matches {
-		p1 := getGlickoPlayer(matches[k].Entries[0].PlayerID, leagueID)
-		p2 := getGlickoPlayer(matches[k].Entries[1].PlayerID, leagueID)
-
-		switch matches[k].Entries[0].Outcome {
-		case MatchEntryOutcomeWin:
-			period.AddMatch(p1, p2, glicko.MATCH_RESULT_WIN)
-		case MatchEntryOutcomeDraw:
-			period.AddMatch(p1, p2, glicko.MATCH_RESULT_DRAW)
-		case MatchEntryOutcomeLoss:
-			period.AddMatch(p1, p2, glicko.MATCH_RESULT_LOSS)
-		}
+		results = append(results, rating.Match{
+			P1:      matches[k].Entries[0].PlayerID,
+			P2:      matches[k].Entries[1].PlayerID,
+			Outcome: rating.Outcome(matches[k].Entries[0].Outcome),
+		})
 	}
 
 	start := time.Now()
-	period.Calculate()
+	system.ComputePeriod(ratings, results)
 	log.Printf(
 		"info: recalculated leaderboard for %d matches and %d players in %s",
-		len(matches), len(glickoPlayers),
+		len(matches), len(ratings),
 		time.Since(start),
 	)
 }
@@ -123,11 +200,13 @@ func previousPeriodStart(t time.Time) time.Time {
 	return currentPeriodStart(t).AddDate(0, 0, -7)
 }
 
-// deleteLeagueRankings removes the all ranking and ranking history of a given league.
-func deleteLeagueRankings(tx *sqlx.Tx, leagueID util.UUIDAsBlob) error {
+// deleteLeagueRankingsSince removes the ranking history of a given league
+// from the rating period starting at the given date onward. Current rankings
+// are derived from the history and removed altogether.
+func deleteLeagueRankingsSince(tx *sqlx.Tx, leagueID util.UUIDAsBlob, periodStart time.Time) error {
 	if _, err := tx.Exec(
-		`DELETE FROM "PlayerRatingHistory" WHERE LeagueID = ?`,
-		leagueID,
+		`DELETE FROM "PlayerRatingHistory" WHERE LeagueID = ? AND RatingPeriodStartedAt >= ?`,
+		leagueID, util.TimeAsTimestamp(periodStart),
 	); err != nil {
 		return err
 	}
@@ -142,26 +221,26 @@ func deleteLeagueRankings(tx *sqlx.Tx, leagueID util.UUIDAsBlob) error {
 	return nil
 }
 
-// closeRatingPeriod writes the current rankings to PlayerRatingHistory, it can
-// be called at any point in a period as rankings are upserted.
+// closeRatingPeriod writes the current rankings of the players who raced
+// during the period to PlayerRatingHistory, it can be called at any point in a
+// period as rankings are upserted.
 func (b *Back) closeRatingPeriod(
 	tx *sqlx.Tx,
 	currentPeriodStart util.TimeAsTimestamp,
 	leagueID util.UUIDAsBlob,
-	glickoPlayers map[util.UUIDAsBlob]*glicko.Player,
+	ratings map[util.UUIDAsBlob]rating.Rating,
 ) error {
 	log.Printf(
 		"debug: closing period starting at %s, upsert history for %d players",
 		currentPeriodStart.Time(),
-		len(glickoPlayers),
+		len(ratings),
 	)
 
-	for playerID, glickoPlayer := range glickoPlayers {
-		rating := NewPlayerRating(playerID, leagueID)
-		glickoRating := glickoPlayer.Rating()
-		rating.SetRating(glickoRating)
+	for playerID, v := range ratings {
+		r := NewPlayerRating(playerID, leagueID)
+		r.SetRating(v)
 
-		if err := rating.upsertHistory(tx, currentPeriodStart); err != nil {
+		if err := r.upsertHistory(tx, currentPeriodStart); err != nil {
 			return fmt.Errorf("unable to insert rating history: %w", err)
 		}
 	}
@@ -169,52 +248,300 @@ func (b *Back) closeRatingPeriod(
 	return nil
 }
 
-func (b *Back) Rerank(shortcode string) error {
-	var (
-		league          League
-		firstMatchStart util.TimeAsTimestamp
+// RankingDiff is the rating and leaderboard rank of a player before and
+// after a rerank, ranks are zero for players absent from the leaderboard.
+type RankingDiff struct {
+	PlayerName            string
+	Before, After         PlayerRating
+	RankBefore, RankAfter int
+}
+
+func (d RankingDiff) String() string {
+	rank := func(v int) string {
+		if v == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("#%d", v)
+	}
+
+	return fmt.Sprintf(
+		"%-20s %4.0f ±%3.0f → %4.0f ±%3.0f (%+5.0f) %4s → %4s",
+		d.PlayerName,
+		d.Before.Rating, d.Before.Deviation,
+		d.After.Rating, d.After.Deviation,
+		d.After.Rating-d.Before.Rating,
+		rank(d.RankBefore), rank(d.RankAfter),
 	)
+}
+
+// Changed returns true if the rerank changed the rating or rank of the player.
+func (d RankingDiff) Changed() bool {
+	return math.Abs(d.After.Rating-d.Before.Rating) >= 0.5 ||
+		math.Abs(d.After.Deviation-d.Before.Deviation) >= 0.5 ||
+		d.RankBefore != d.RankAfter
+}
+
+// Rerank erases and recomputes the ranking history of a league from the
+// rating period containing the given date onward, or from the first match of
+// the league if the date is zero. All periods are recomputed in a single
+// transaction that is rolled back if dryRun is true. The returned diff is
+// sorted by new leaderboard rank then new rating.
+func (b *Back) Rerank(shortcode string, since time.Time, dryRun bool) (ret []RankingDiff, _ error) {
+	transaction := b.transaction
+	if dryRun {
+		transaction = b.dryRunTransaction
+	}
+
+	return ret, transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		ret, err = b.rerank(tx, league, since)
+		return err
+	})
+}
+
+func (b *Back) rerank(tx *sqlx.Tx, league League, since time.Time) ([]RankingDiff, error) {
 	start := time.Now()
 
-	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
-		league, err = getLeagueByShortCode(tx, shortcode)
+	system, err := getLeagueRatingSystem(league)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := getRatingCalendar(tx, league)
+	if err != nil {
+		return nil, err
+	}
+
+	firstMatchStart, err := getFirstMatchStartOfLeague(tx, league.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) { // nothing to rank
+			return nil, deleteLeagueRankingsSince(tx, league.ID, time.Unix(0, 0))
+		}
+		return nil, fmt.Errorf("unable to find first match of league: %w", err)
+	}
+	if since.IsZero() || since.Before(firstMatchStart.Time()) {
+		since = firstMatchStart.Time()
+	}
+
+	before, err := getPlayerRatingsByPlayerID(tx, league.ID)
+	if err != nil {
+		return nil, err
+	}
+	ranksBefore, err := getLeagueRanks(tx, league)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteLeagueRankingsSince(tx, league.ID, calendar.start(since)); err != nil {
+		return nil, fmt.Errorf("unable to prune rankings: %w", err)
+	}
+
+	log.Printf("debug: reranking %s since %s (period %s)", league.ShortCode, since, calendar.start(since))
+	if err := b.updateLeagueRankingsSince(tx, league, system, calendar, since); err != nil {
+		return nil, fmt.Errorf("unable to update league rankings: %w", err)
+	}
+
+	after, err := getPlayerRatingsByPlayerID(tx, league.ID)
+	if err != nil {
+		return nil, err
+	}
+	ranksAfter, err := getLeagueRanks(tx, league)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]RankingDiff, 0, len(after))
+	for playerID, v := range after {
+		player, err := getPlayerByID(tx, playerID)
+		if err != nil {
+			return nil, err
+		}
+
+		prev, ok := before[playerID]
+		if !ok {
+			prev = NewPlayerRating(playerID, league.ID)
+		}
+
+		ret = append(ret, RankingDiff{
+			PlayerName: player.Name,
+			Before:     prev,
+			After:      v,
+			RankBefore: ranksBefore[playerID],
+			RankAfter:  ranksAfter[playerID],
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		ri, rj := ret[i].RankAfter, ret[j].RankAfter
+		if (ri == 0) != (rj == 0) {
+			return rj == 0
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return ret[i].After.Rating > ret[j].After.Rating
+	})
+
+	log.Printf("info: recomputed rankings in %s", time.Since(start))
+
+	return ret, nil
+}
+
+// getLeagueRanks returns the current leaderboard rank of every ranked player
+// of a league indexed by player ID, regardless of divisions.
+func getLeagueRanks(tx *sqlx.Tx, league League) (map[util.UUIDAsBlob]int, error) {
+	var rows []struct {
+		PlayerID util.UUIDAsBlob
+		Rank     int
+	}
+	if err := tx.Select(&rows, `
+        SELECT PlayerID, ROW_NUMBER() OVER (ORDER BY Rating DESC) AS Rank
+        FROM PlayerRating
+        WHERE LeagueID = ? AND Deviation < ? AND `+ratedRacesSubquery+` >= ?`,
+		league.ID, league.Leaderboard.Threshold(), league.Leaderboard.MinRaces,
+	); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[util.UUIDAsBlob]int, len(rows))
+	for _, v := range rows {
+		ret[v.PlayerID] = v.Rank
+	}
+
+	return ret, nil
+}
+
+// RatingComparison is the current and candidate ratings of a player when
+// comparing rating systems.
+type RatingComparison struct {
+	PlayerName         string
+	Current, Candidate PlayerRating
+}
+
+// CompareRatingSystem recomputes all the ranking history of a league using
+// another rating system and returns the resulting ratings alongside the
+// current ones, best candidate rating first. Nothing is saved.
+func (b *Back) CompareRatingSystem(shortcode, systemID string) (ret []RatingComparison, _ error) {
+	system, err := rating.New(systemID)
+	if err != nil {
+		return nil, util.ErrPublic(fmt.Sprintf("%s, valid systems are: %s", err, strings.Join(rating.SystemIDs, ", ")))
+	}
+
+	return ret, b.dryRunTransaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
 		if err != nil {
 			return fmt.Errorf("unable to find league with shortcode '%s': %w", shortcode, err)
 		}
 
-		if err := deleteLeagueRankings(tx, league.ID); err != nil {
+		current, err := getPlayerRatingsByPlayerID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+
+		calendar, err := getRatingCalendar(tx, league)
+		if err != nil {
+			return err
+		}
+
+		if err := deleteLeagueRankingsSince(tx, league.ID, time.Unix(0, 0)); err != nil {
 			return fmt.Errorf("unable to prune rankings: %w", err)
 		}
 
-		firstMatchStart, err = getFirstMatchStartOfLeague(tx, league.ID)
+		firstMatchStart, err := getFirstMatchStartOfLeague(tx, league.ID)
 		if err != nil {
 			return fmt.Errorf("unable to find first match of league: %w", err)
 		}
 
-		return nil
-	}); err != nil {
-		return err
-	}
+		if err := b.updateLeagueRankingsSince(tx, league, system, calendar, firstMatchStart.Time()); err != nil {
+			return err
+		}
+
+		candidate, err := getPlayerRatingsByPlayerID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+
+		for playerID, v := range candidate {
+			player, err := getPlayerByID(tx, playerID)
+			if err != nil {
+				return err
+			}
+
+			ret = append(ret, RatingComparison{
+				PlayerName: player.Name,
+				Current:    current[playerID],
+				Candidate:  v,
+			})
+		}
+
+		sort.Slice(ret, func(i, j int) bool {
+			return ret[i].Candidate.Rating > ret[j].Candidate.Rating
+		})
 
-	firstPeriodStart := currentPeriodStart(firstMatchStart.Time())
-	curPeriodEnd := nextPeriodStart(time.Now())
-	log.Printf("debug: first match: %s (period %s)", firstMatchStart.Time(), firstPeriodStart)
+		return nil
+	})
+}
 
-	for i := firstPeriodStart; i.Before(curPeriodEnd); i = i.AddDate(0, 0, 7) {
-		j := i // get out of range scope
+// SetLeagueRatingSystem changes the rating system of a league and recomputes
+// all its ranking history.
+func (b *Back) SetLeagueRatingSystem(shortcode, systemID string) error {
+	if _, err := rating.New(systemID); err != nil {
+		return util.ErrPublic(fmt.Sprintf("%s, valid systems are: %s", err, strings.Join(rating.SystemIDs, ", ")))
+	}
 
-		if err := b.transaction(func(tx *sqlx.Tx) (err error) {
-			if err := b.updateLeagueRankings(tx, league.ID, j); err != nil {
-				return fmt.Errorf("unable to update league rankings: %w", err)
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
 			}
+			return err
+		}
 
-			return nil
-		}); err != nil {
+		league.RatingSystem = systemID
+		if err := league.update(tx); err != nil {
 			return err
 		}
+
+		_, err = b.rerank(tx, league, time.Time{})
+		return err
+	})
+}
+
+// SetLeagueRatingPeriod changes the rating period length of a league and
+// recomputes all its ranking history.
+func (b *Back) SetLeagueRatingPeriod(shortcode string, period RatingPeriod) error {
+	if !period.valid() {
+		return util.ErrPublic(fmt.Sprintf(
+			"unknown rating period '%s', valid periods are: %s",
+			period, ratingPeriodNames(),
+		))
 	}
 
-	log.Printf("info: recomputed rankings in %s", time.Since(start))
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
 
-	return nil
+		league.RatingPeriod = period
+		// Restart end of period detection on the new boundaries.
+		league.RatingPeriodStartedAt = util.NullTimeAsTimestamp{}
+		if err := league.update(tx); err != nil {
+			return err
+		}
+
+		_, err = b.rerank(tx, league, time.Time{})
+		return err
+	})
 }
@@ -1,6 +1,8 @@
 package back // nolint:testpackage
 
 import (
+	"kaepora/internal/rating"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -50,3 +52,71 @@ func TestPeriodCompute(t *testing.T) {
 		}
 	}
 }
+
+func TestCompareRatingSystems(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+
+	current, err := back.GetPlayerRatings("testa", Season{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, system := range rating.SystemIDs {
+		comparison, err := back.CompareRatingSystem("testa", system)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(comparison) != 6 { // Impa always gets kicked
+			t.Errorf("%s: expected 6 rated players, got %d", system, len(comparison))
+		}
+	}
+
+	// Comparing must not change anything.
+	after, err := back.GetPlayerRatings("testa", Season{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, after) {
+		t.Error("comparing rating systems changed the ratings")
+	}
+}
+
+// Rankings are up to date after racing, reranking must then yield the same
+// ratings whatever the mode.
+func TestRerank(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+
+	current, err := back.GetPlayerRatings("testa", Season{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, since time.Time, dryRun bool) {
+		diff, err := back.Rerank("testa", since, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(diff) != 6 {
+			t.Errorf("%s: expected 6 rated players, got %d", name, len(diff))
+		}
+		for _, v := range diff {
+			if v.Changed() {
+				t.Errorf("%s: unexpected change: %s", name, v)
+			}
+		}
+	}
+
+	check("dry-run", time.Time{}, true)
+	after, err := back.GetPlayerRatings("testa", Season{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, after) {
+		t.Error("rerank dry-run changed the ratings")
+	}
+
+	check("full", time.Time{}, false)
+	check("incremental", time.Now(), false)
+}
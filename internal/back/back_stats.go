@@ -14,12 +14,17 @@ type StatsMisc struct {
 	AveragePlayersPerRace, MostPlayersInARace              int
 }
 
-func (b *Back) GetMiscStats(shortcode string) (misc StatsMisc, _ error) { // nolint:funlen
+// GetMiscStats returns the stats of a league for the given season, use the
+// zero Season for all time stats.
+func (b *Back) GetMiscStats(shortcode string, season Season) (misc StatsMisc, _ error) { // nolint:funlen
 	if err := b.transaction(func(tx *sqlx.Tx) error {
 		league, err := getLeagueByShortCode(tx, shortcode)
 		if err != nil {
 			return err
 		}
+		from, to := season.bounds()
+		// MatchSession.StartDate is not a timestamp.
+		sessionFrom, sessionTo := util.TimeAsDateTimeTZ(from.Time()), util.TimeAsDateTimeTZ(to.Time())
 
 		queries := []struct {
 			Dst   interface{}
@@ -30,49 +35,54 @@ func (b *Back) GetMiscStats(shortcode string) (misc StatsMisc, _ error) { // nol
 			{&misc.RankedPlayers, `SELECT COUNT(*) FROM PlayerRating WHERE LeagueID = ?`, []interface{}{league.ID}},
 			{
 				&misc.PlayersOnLeaderboard,
-				`SELECT COUNT(*) FROM PlayerRating WHERE LeagueID = ? AND Deviation < ?`,
-				[]interface{}{league.ID, DeviationThreshold},
+				`SELECT COUNT(*) FROM PlayerRating
+                WHERE LeagueID = ? AND Deviation < ? AND ` + ratedRacesSubquery + ` >= ?`,
+				[]interface{}{league.ID, league.Leaderboard.Threshold(), league.Leaderboard.MinRaces},
 			},
 
 			{
 				&misc.SeedsPlayed,
-				`SELECT COUNT(*) FROM Match WHERE LeagueID = ?`,
-				[]interface{}{league.ID},
+				`SELECT COUNT(*) FROM Match WHERE LeagueID = ? AND CreatedAt >= ? AND CreatedAt < ?`,
+				[]interface{}{league.ID, from, to},
 			},
 			{
 				&misc.Forfeits,
 				`SELECT COUNT(*) FROM MatchEntry
                 LEFT JOIN Match ON (MatchEntry.MatchID = Match.ID)
-                WHERE Match.LeagueID = ? AND MatchEntry.Status = ?`,
-				[]interface{}{league.ID, MatchEntryStatusForfeit},
+                WHERE Match.LeagueID = ? AND MatchEntry.Status = ?
+                AND Match.CreatedAt >= ? AND Match.CreatedAt < ?`,
+				[]interface{}{league.ID, MatchEntryStatusForfeit, from, to},
 			},
 			{
 				&misc.DoubleForfeits,
 				`SELECT COUNT(*) FROM (SELECT COUNT(*) as cnt FROM "MatchEntry"
                 LEFT JOIN Match ON (MatchEntry.MatchID = Match.ID)
                 WHERE Match.LeagueID = ? AND MatchEntry.Status == ?
+                AND Match.CreatedAt >= ? AND Match.CreatedAt < ?
                 GROUP BY MatchEntry.MatchID HAVING cnt > 1)`,
-				[]interface{}{league.ID, MatchEntryStatusForfeit},
+				[]interface{}{league.ID, MatchEntryStatusForfeit, from, to},
 			},
 
 			{
 				&misc.FirstLadderRace,
 				`SELECT StartDate FROM MatchSession
-                WHERE LeagueID = ?
+                WHERE LeagueID = ? AND DATETIME(StartDate) >= DATETIME(?) AND DATETIME(StartDate) < DATETIME(?)
                 ORDER BY StartDate ASC LIMIT 1`,
-				[]interface{}{league.ID},
+				[]interface{}{league.ID, sessionFrom, sessionTo},
 			},
 			{
 				&misc.AveragePlayersPerRace,
 				`SELECT round(avg(json_array_length(PlayerIDs)))
-                FROM MatchSession WHERE LeagueID = ?`,
-				[]interface{}{league.ID},
+                FROM MatchSession
+                WHERE LeagueID = ? AND DATETIME(StartDate) >= DATETIME(?) AND DATETIME(StartDate) < DATETIME(?)`,
+				[]interface{}{league.ID, sessionFrom, sessionTo},
 			},
 			{
 				&misc.MostPlayersInARace,
 				`SELECT max(json_array_length(PlayerIDs))
-                FROM MatchSession WHERE LeagueID = ?`,
-				[]interface{}{league.ID},
+                FROM MatchSession
+                WHERE LeagueID = ? AND DATETIME(StartDate) >= DATETIME(?) AND DATETIME(StartDate) < DATETIME(?)`,
+				[]interface{}{league.ID, sessionFrom, sessionTo},
 			},
 		}
 
@@ -82,6 +92,20 @@ func (b *Back) GetMiscStats(shortcode string) (misc StatsMisc, _ error) { // nol
 			}
 		}
 
+		if season.ID.IsZero() {
+			return nil
+		}
+
+		ranked, err := getLatestRatings(tx, league.ID, from, to)
+		if err != nil {
+			return err
+		}
+		onLeaderboard, err := getSeasonRatings(tx, league, season)
+		if err != nil {
+			return err
+		}
+		misc.RankedPlayers, misc.PlayersOnLeaderboard = len(ranked), len(onLeaderboard)
+
 		return nil
 	}); err != nil {
 		return StatsMisc{}, err
@@ -90,8 +114,11 @@ func (b *Back) GetMiscStats(shortcode string) (misc StatsMisc, _ error) { // nol
 	return misc, nil
 }
 
+// MapSpoilerLogs calls cb with the spoiler log of each completed match of the
+// league played during the given season, use the zero Season for all matches.
 func (b *Back) MapSpoilerLogs(
 	shortcode string,
+	season Season,
 	cb func(io.Reader) error,
 ) error {
 	return b.transaction(func(tx *sqlx.Tx) error {
@@ -100,10 +127,12 @@ func (b *Back) MapSpoilerLogs(
 			return err
 		}
 
+		from, to := season.bounds()
 		rows, err := tx.Query(`
             SELECT SpoilerLog FROM Match WHERE LeagueID = ?
+            AND CreatedAt >= ? AND CreatedAt < ?
             AND EndedAt IS NOT NULL`, // HACK: ensure we don't leak stats on in-progress matches
-			league.ID,
+			league.ID, from, to,
 		)
 		if err != nil {
 			return err
@@ -10,7 +10,10 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-func (b *Back) GetLeaderboardForShortcode(shortcode string, maxDeviation int) ([]LeaderboardEntry, error) {
+// GetLeaderboardForShortcode returns the ranked players of a league, see
+// LeagueLeaderboard, with their movements since the end of the previous
+// rating period.
+func (b *Back) GetLeaderboardForShortcode(shortcode string) ([]LeaderboardEntry, error) {
 	var ret []LeaderboardEntry
 
 	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
@@ -19,7 +22,7 @@ func (b *Back) GetLeaderboardForShortcode(shortcode string, maxDeviation int) ([
 			return err
 		}
 
-		return tx.Select(&ret, `
+		if err := tx.Select(&ret, `
             SELECT
                 Player.Name AS PlayerName,
                 Player.StreamURL AS PlayerStreamURL,
@@ -28,14 +31,25 @@ func (b *Back) GetLeaderboardForShortcode(shortcode string, maxDeviation int) ([
                 SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Wins,
                 SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Losses,
                 SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Draws,
-                SUM(CASE WHEN MatchEntry.Status = ? THEN 1 ELSE 0 END) AS Forfeits
+                SUM(CASE WHEN MatchEntry.Status = ? THEN 1 ELSE 0 END) AS Forfeits,
+                COALESCE(Division.Name, '') AS DivisionName,
+                ROW_NUMBER() OVER (
+                    PARTITION BY PlayerDivision.DivisionID
+                    ORDER BY PlayerRating.Rating DESC
+                ) AS DivisionRank
             FROM PlayerRating
             INNER JOIN Player ON(PlayerRating.PlayerID = Player.ID)
             LEFT JOIN MatchEntry ON(PlayerRating.PlayerID = MatchEntry.PlayerID AND MatchEntry.Status != ?)
             LEFT JOIN Match ON(Match.ID = MatchEntry.MatchID)
+            LEFT JOIN PlayerDivision ON(
+                PlayerDivision.PlayerID = PlayerRating.PlayerID
+                AND PlayerDivision.LeagueID = PlayerRating.LeagueID
+            )
+            LEFT JOIN Division ON(Division.ID = PlayerDivision.DivisionID)
             WHERE Match.LeagueID = ? AND PlayerRating.LeagueID = ? AND PlayerRating.Deviation < ?
+              AND `+ratedRacesSubquery+` >= ?
             GROUP BY Player.ID
-            ORDER BY PlayerRating.Rating DESC
+            ORDER BY Division.Tier IS NULL, Division.Tier ASC, PlayerRating.Rating DESC
         `,
 			MatchEntryOutcomeWin,
 			MatchEntryOutcomeLoss,
@@ -43,8 +57,84 @@ func (b *Back) GetLeaderboardForShortcode(shortcode string, maxDeviation int) ([
 			MatchEntryStatusForfeit,
 			MatchEntryStatusInProgress,
 			league.ID, league.ID,
-			maxDeviation,
-		)
+			league.Leaderboard.Threshold(), league.Leaderboard.MinRaces,
+		); err != nil {
+			return err
+		}
+
+		calendar, err := getRatingCalendar(tx, league)
+		if err != nil {
+			return err
+		}
+
+		previous, err := getLeaderboardBefore(tx, league, calendar, calendar.start(time.Now()))
+		if err != nil {
+			return err
+		}
+		setLeaderboardMovements(ret, previous)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// GetProvisionalPlayersForShortcode returns the players of a league who raced
+// recently but are not ranked yet, best rating first.
+func (b *Back) GetProvisionalPlayersForShortcode(shortcode string) ([]LeaderboardEntry, error) {
+	var ret []LeaderboardEntry
+
+	if err := b.transaction(func(tx *sqlx.Tx) (err error) {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		var ratings []struct {
+			PlayerRating
+			PlayerName      string
+			PlayerStreamURL string
+			Races           int
+		}
+		if err := tx.Select(&ratings, `
+            SELECT
+                PlayerRating.*,
+                Player.Name AS PlayerName,
+                Player.StreamURL AS PlayerStreamURL,
+                `+ratedRacesSubquery+` AS Races
+            FROM PlayerRating
+            INNER JOIN Player ON(PlayerRating.PlayerID = Player.ID)
+            WHERE PlayerRating.LeagueID = ? AND EXISTS(
+                SELECT 1 FROM MatchEntry
+                INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+                WHERE MatchEntry.PlayerID = PlayerRating.PlayerID
+                  AND Match.LeagueID = PlayerRating.LeagueID
+                  AND Match.StartedAt >= ?
+            )
+            ORDER BY PlayerRating.Rating DESC`,
+			league.ID, util.TimeAsTimestamp(time.Now().AddDate(0, 0, -provisionalRecentDays)),
+		); err != nil {
+			return err
+		}
+
+		for _, v := range ratings {
+			if !league.Leaderboard.IsProvisional(v.Deviation, v.Races) {
+				continue
+			}
+
+			ret = append(ret, LeaderboardEntry{
+				PlayerName:      v.PlayerName,
+				PlayerStreamURL: v.PlayerStreamURL,
+				Rating:          v.Rating,
+				Deviation:       v.Deviation,
+				Races:           v.Races,
+				Provisional:     true,
+			})
+		}
+
+		return nil
 	}); err != nil {
 		return nil, err
 	}
@@ -52,6 +142,10 @@ func (b *Back) GetLeaderboardForShortcode(shortcode string, maxDeviation int) ([
 	return ret, nil
 }
 
+// provisionalRecentDays is how long provisional players stay listed after
+// their last race.
+const provisionalRecentDays = 30
+
 func (b *Back) GetLeagues() (ret []League, _ error) {
 	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
 		ret, err = getLeagues(tx)
@@ -133,22 +227,17 @@ func (b *Back) GetMatchSessions(
 	return sessions, leagues, nil
 }
 
-func (b *Back) GetPlayerRatings(shortcode string) (ret []PlayerRating, _ error) {
+// GetPlayerRatings returns the ratings of the players on the leaderboard of
+// the given season, use the zero Season for the current leaderboard.
+func (b *Back) GetPlayerRatings(shortcode string, season Season) (ret []PlayerRating, _ error) {
 	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
 		league, err := getLeagueByShortCode(tx, shortcode)
 		if err != nil {
 			return err
 		}
 
-		if err := tx.Select(
-			&ret,
-			`SELECT * FROM PlayerRating WHERE LeagueID = ? AND Deviation < ?`,
-			league.ID, DeviationThreshold,
-		); err != nil {
-			return err
-		}
-
-		return nil
+		ret, err = getSeasonRatings(tx, league, season)
+		return err
 	})
 }
 
@@ -0,0 +1,89 @@
+package back
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrWebLoginUsed is returned by ConsumeWebLogin for unknown, expired, or
+// already used nonces.
+var ErrWebLoginUsed = errors.New("unknown, expired, or already used login link")
+
+// CreateWebLogin returns a nonce to put in a "!weblogin" link, it can be
+// consumed once by ConsumeWebLogin until it expires.
+func (b *Back) CreateWebLogin(playerID util.UUIDAsBlob, expires time.Time) (nonce string, _ error) {
+	return nonce, b.transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(
+			`DELETE FROM WebLogin WHERE ExpiresAt <= ?`,
+			util.TimeAsTimestamp(time.Now()),
+		); err != nil {
+			return err
+		}
+
+		nonce = uuid.New().String()
+		query, args, err := squirrel.Insert("WebLogin").SetMap(squirrel.Eq{
+			"Nonce":     nonce,
+			"PlayerID":  playerID,
+			"ExpiresAt": util.TimeAsTimestamp(expires),
+		}).ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(query, args...)
+		return err
+	})
+}
+
+// ConsumeWebLogin invalidates a nonce created by CreateWebLogin for the
+// given player, it returns ErrWebLoginUsed if the nonce cannot be used.
+func (b *Back) ConsumeWebLogin(playerID util.UUIDAsBlob, nonce string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(
+			`DELETE FROM WebLogin WHERE Nonce = ? AND PlayerID = ? AND ExpiresAt > ?`,
+			nonce, playerID, util.TimeAsTimestamp(time.Now()),
+		)
+		if err != nil {
+			return err
+		}
+
+		count, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			return ErrWebLoginUsed
+		}
+
+		return nil
+	})
+}
+
+// GetWebSessionVersion returns the version website sessions of the player
+// must be signed with, older sessions have been revoked.
+func (b *Back) GetWebSessionVersion(playerID util.UUIDAsBlob) (version int, _ error) {
+	return version, b.transaction(func(tx *sqlx.Tx) error {
+		return tx.Get(
+			&version,
+			`SELECT COALESCE(MAX(Version), 0) FROM WebSessionVersion WHERE PlayerID = ?`,
+			playerID,
+		)
+	})
+}
+
+// RevokeWebSessions logs the player out of the website on every device.
+func (b *Back) RevokeWebSessions(playerID util.UUIDAsBlob) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`
+            INSERT INTO WebSessionVersion (PlayerID, Version) VALUES (?, 1)
+            ON CONFLICT(PlayerID) DO UPDATE SET Version = Version + 1`,
+			playerID,
+		)
+		return err
+	})
+}
@@ -0,0 +1,64 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestWebSessions(t *testing.T) {
+	back := createFixturedTestBack(t)
+
+	var darunia, nabooru Player
+	if err := back.transaction(func(tx *sqlx.Tx) (err error) {
+		if darunia, err = getPlayerByName(tx, "Darunia"); err != nil {
+			return err
+		}
+		nabooru, err = getPlayerByName(tx, "Nabooru")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, err := back.CreateWebLogin(darunia.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := back.ConsumeWebLogin(nabooru.ID, nonce); !errors.Is(err, ErrWebLoginUsed) {
+		t.Errorf("expected the nonce of another player to be refused, got %v", err)
+	}
+	if err := back.ConsumeWebLogin(darunia.ID, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.ConsumeWebLogin(darunia.ID, nonce); !errors.Is(err, ErrWebLoginUsed) {
+		t.Errorf("expected a nonce to be usable once, got %v", err)
+	}
+
+	expired, err := back.CreateWebLogin(darunia.ID, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := back.ConsumeWebLogin(darunia.ID, expired); !errors.Is(err, ErrWebLoginUsed) {
+		t.Errorf("expected an expired nonce to be refused, got %v", err)
+	}
+
+	for expected := 0; expected < 3; expected++ {
+		version, err := back.GetWebSessionVersion(darunia.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expected {
+			t.Errorf("expected session version %d, got %d", expected, version)
+		}
+
+		if err := back.RevokeWebSessions(darunia.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if version, err := back.GetWebSessionVersion(nabooru.ID); err != nil || version != 0 {
+		t.Errorf("expected other players to keep their sessions, got %d (%v)", version, err)
+	}
+}
@@ -104,8 +104,40 @@ type LeaderboardEntry struct {
 	Rating          float64
 	Deviation       float64
 
+	// Empty for leagues without divisions, DivisionRank is the rank of the
+	// player inside its division.
+	DivisionName string
+	DivisionRank int
+
 	// Web only, unused in top20 (which is destined to die)
 	Wins, Losses, Draws, Forfeits int
+
+	// Set for players around a player and provisional players only, see
+	// LeagueLeaderboard.IsProvisional.
+	Races       int
+	Provisional bool `db:"-"`
+
+	// Rank on the whole league leaderboard and movement since the previous
+	// rating period, PreviousRank is zero for players who were not on the
+	// previous leaderboard. See setLeaderboardMovements.
+	Rank, PreviousRank int     `db:"-"`
+	RatingDelta        float64 `db:"-"`
+}
+
+// RankDelta returns the number of places gained since the previous rating
+// period, negative if the player went down the leaderboard.
+func (e LeaderboardEntry) RankDelta() int {
+	if e.PreviousRank == 0 {
+		return 0
+	}
+
+	return e.PreviousRank - e.Rank
+}
+
+// IsNew returns true if the player was not on the leaderboard at the end of
+// the previous rating period.
+func (e LeaderboardEntry) IsNew() bool {
+	return e.Rank > 0 && e.PreviousRank == 0
 }
 
 func (b *Back) GetLeaderboardsForDiscordUser(discordID, shortcode string) (
@@ -127,7 +159,7 @@ func (b *Back) GetLeaderboardsForDiscordUser(discordID, shortcode string) (
 			return err
 		}
 
-		top, err = getTop20(tx, league.ID, DeviationThreshold)
+		top, err = getTop20(tx, league)
 		if err != nil {
 			return err
 		}
@@ -137,7 +169,7 @@ func (b *Back) GetLeaderboardsForDiscordUser(discordID, shortcode string) (
 			player.ID = util.UUIDAsBlob{} // zero value as canary
 		}
 		if !player.ID.IsZero() {
-			around, err = getTopAroundPlayer(tx, player, league.ID)
+			around, err = getTopAroundPlayer(tx, player, league)
 			if err != nil {
 				return err
 			}
@@ -151,21 +183,41 @@ func (b *Back) GetLeaderboardsForDiscordUser(discordID, shortcode string) (
 	return top, around, nil
 }
 
-func getTop20(tx *sqlx.Tx, leagueID util.UUIDAsBlob, maxDeviation int) ([]LeaderboardEntry, error) {
+// getTop20 returns the top 20 ranked players of each division of a league,
+// top division first.
+func getTop20(tx *sqlx.Tx, league League) ([]LeaderboardEntry, error) {
 	query := `
-    SELECT
-        Player.Name AS PlayerName,
-        Player.StreamURL AS PlayerStreamURL,
-        PlayerRating.Rating AS Rating,
-        PlayerRating.Deviation AS Deviation
-    FROM PlayerRating
-    INNER JOIN Player ON(PlayerRating.PlayerID = Player.ID)
-    WHERE PlayerRating.LeagueID = ? AND PlayerRating.Deviation < ?
-    ORDER BY PlayerRating.Rating DESC
-    LIMIT 20`
+    SELECT PlayerName, PlayerStreamURL, Rating, Deviation, DivisionName, DivisionRank
+    FROM (
+        SELECT
+            Player.Name AS PlayerName,
+            Player.StreamURL AS PlayerStreamURL,
+            PlayerRating.Rating AS Rating,
+            PlayerRating.Deviation AS Deviation,
+            COALESCE(Division.Name, '') AS DivisionName,
+            Division.Tier AS DivisionTier,
+            ROW_NUMBER() OVER (
+                PARTITION BY PlayerDivision.DivisionID
+                ORDER BY PlayerRating.Rating DESC
+            ) AS DivisionRank
+        FROM PlayerRating
+        INNER JOIN Player ON(PlayerRating.PlayerID = Player.ID)
+        LEFT JOIN PlayerDivision ON(
+            PlayerDivision.PlayerID = PlayerRating.PlayerID
+            AND PlayerDivision.LeagueID = PlayerRating.LeagueID
+        )
+        LEFT JOIN Division ON(Division.ID = PlayerDivision.DivisionID)
+        WHERE PlayerRating.LeagueID = ? AND PlayerRating.Deviation < ?
+          AND ` + ratedRacesSubquery + ` >= ?
+    )
+    WHERE DivisionRank <= 20
+    ORDER BY DivisionTier IS NULL, DivisionTier ASC, DivisionRank ASC`
 
 	var ret []LeaderboardEntry
-	if err := tx.Select(&ret, query, leagueID, maxDeviation); err != nil {
+	if err := tx.Select(
+		&ret, query,
+		league.ID, league.Leaderboard.Threshold(), league.Leaderboard.MinRaces,
+	); err != nil {
 		return nil, err
 	}
 
@@ -173,12 +225,20 @@ func getTop20(tx *sqlx.Tx, leagueID util.UUIDAsBlob, maxDeviation int) ([]Leader
 }
 
 // nolint:funlen
-func getTopAroundPlayer(tx *sqlx.Tx, player Player, leagueID util.UUIDAsBlob) ([]LeaderboardEntry, error) {
+func getTopAroundPlayer(tx *sqlx.Tx, player Player, league League) ([]LeaderboardEntry, error) {
+	leagueID := league.ID
 	rating, err := getPlayerRating(tx, player.ID, leagueID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Only compare the player with the members of their division.
+	division, err := getPlayerDivision(tx, player.ID, leagueID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	divisionID := util.NewNullUUIDAsBlob(division.ID)
+
 	topAround := func(above bool, rating float64) ([]LeaderboardEntry, error) {
 		op := ">"
 		dir := "ASC"
@@ -193,19 +253,25 @@ func getTopAroundPlayer(tx *sqlx.Tx, player Player, leagueID util.UUIDAsBlob) ([
                 Player.Name AS PlayerName,
                 Player.StreamURL AS PlayerStreamURL,
                 PlayerRating.Rating AS Rating,
-                PlayerRating.Deviation AS Deviation
+                PlayerRating.Deviation AS Deviation,
+                %[3]s AS Races
             FROM PlayerRating
             INNER JOIN Player ON (PlayerRating.PlayerID = Player.ID)
+            LEFT JOIN PlayerDivision ON (
+                PlayerDivision.PlayerID = PlayerRating.PlayerID
+                AND PlayerDivision.LeagueID = PlayerRating.LeagueID
+            )
             WHERE
                 PlayerRating.LeagueID = ?
+                AND PlayerDivision.DivisionID IS ?
                 AND PlayerRating.Rating %[1]s ?  AND Player.ID != ?
             ORDER BY PlayerRating.Rating %[2]s
             LIMIT 5`,
-			op, dir,
+			op, dir, ratedRacesSubquery,
 		)
 
 		var ret []LeaderboardEntry
-		if err := tx.Select(&ret, query, leagueID, rating, player.ID); err != nil {
+		if err := tx.Select(&ret, query, leagueID, divisionID, rating, player.ID); err != nil {
 			return nil, err
 		}
 
@@ -232,11 +298,25 @@ func getTopAroundPlayer(tx *sqlx.Tx, player Player, leagueID util.UUIDAsBlob) ([
 		return nil, nil
 	}
 
+	races, err := getPlayerRatedRacesCount(tx, player.ID, leagueID)
+	if err != nil {
+		return nil, err
+	}
+
 	ret := make([]LeaderboardEntry, 0, len(above)+1+len(below))
 	ret = append(ret, above...)
-	ret = append(ret, LeaderboardEntry{PlayerName: player.Name, Rating: rating.Rating})
+	ret = append(ret, LeaderboardEntry{
+		PlayerName: player.Name,
+		Rating:     rating.Rating,
+		Deviation:  rating.Deviation,
+		Races:      races,
+	})
 	ret = append(ret, below...)
 
+	for k := range ret {
+		ret[k].Provisional = league.Leaderboard.IsProvisional(ret[k].Deviation, ret[k].Races)
+	}
+
 	return ret, nil
 }
 
@@ -279,7 +359,7 @@ func (b *Back) SendRecaps(toUserID string, shortcode string, scope RecapScope) e
 			if err != nil {
 				return err
 			}
-			if err := b.sendSessionRecapNotification(tx, sessions[k], matches, scope, &toUserID); err != nil {
+			if err := b.sendSessionRecapNotification(tx, sessions[k], matches, scope, &toUserID, nil); err != nil {
 				return err
 			}
 		}
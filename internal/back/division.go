@@ -0,0 +1,400 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// A Division splits the players of a League in separate pools, each with its
+// own sessions and matchmaking. At the end of each rating period the best
+// players of a division are promoted to the division above it and the worst
+// are relegated to the division below.
+// Leagues without divisions put all their players in the same pool.
+type Division struct {
+	ID        util.UUIDAsBlob
+	LeagueID  util.UUIDAsBlob
+	CreatedAt util.TimeAsTimestamp
+	Name      string
+	Tier      int // 0 is the top division
+
+	PromotionCount  int
+	RelegationCount int
+}
+
+func NewDivision(leagueID util.UUIDAsBlob, name string, tier, promotionCount, relegationCount int) Division {
+	return Division{
+		ID:              util.NewUUIDAsBlob(),
+		LeagueID:        leagueID,
+		CreatedAt:       util.TimeAsTimestamp(time.Now()),
+		Name:            name,
+		Tier:            tier,
+		PromotionCount:  promotionCount,
+		RelegationCount: relegationCount,
+	}
+}
+
+func (d *Division) insert(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Insert("Division").SetMap(squirrel.Eq{
+		"ID":              d.ID,
+		"LeagueID":        d.LeagueID,
+		"CreatedAt":       d.CreatedAt,
+		"Name":            d.Name,
+		"Tier":            d.Tier,
+		"PromotionCount":  d.PromotionCount,
+		"RelegationCount": d.RelegationCount,
+	}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getDivisionsByLeagueID returns the divisions of a league, top division first.
+func getDivisionsByLeagueID(tx *sqlx.Tx, leagueID util.UUIDAsBlob) ([]Division, error) {
+	var ret []Division
+	query := `SELECT * FROM Division WHERE Division.LeagueID = ? ORDER BY Division.Tier ASC`
+	if err := tx.Select(&ret, query, leagueID); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func getDivisionByID(tx *sqlx.Tx, id util.UUIDAsBlob) (Division, error) {
+	var ret Division
+	query := `SELECT * FROM Division WHERE Division.ID = ? LIMIT 1`
+	if err := tx.Get(&ret, query, id); err != nil {
+		return Division{}, err
+	}
+
+	return ret, nil
+}
+
+func getPlayerDivision(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) (Division, error) {
+	var ret Division
+	query := `
+        SELECT Division.* FROM Division
+        INNER JOIN PlayerDivision ON(PlayerDivision.DivisionID = Division.ID)
+        WHERE PlayerDivision.PlayerID = ? AND PlayerDivision.LeagueID = ?
+        LIMIT 1`
+	if err := tx.Get(&ret, query, playerID, leagueID); err != nil {
+		return Division{}, err
+	}
+
+	return ret, nil
+}
+
+func setPlayerDivision(tx *sqlx.Tx, playerID util.UUIDAsBlob, division Division) error {
+	query, args, err := squirrel.Insert("PlayerDivision").SetMap(squirrel.Eq{
+		"PlayerID":   playerID,
+		"LeagueID":   division.LeagueID,
+		"DivisionID": division.ID,
+		"CreatedAt":  util.TimeAsTimestamp(time.Now()),
+	}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	query += ` ON CONFLICT(PlayerID, LeagueID) DO UPDATE SET DivisionID=excluded.DivisionID`
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getOrAssignPlayerDivision returns the division of the player in the given
+// league. Players without a division are placed according to their rating
+// like seedDivisions does, players without a rating are put in the lowest
+// division.
+// A zero Division is returned if the league has no divisions.
+func getOrAssignPlayerDivision(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) (Division, error) {
+	division, err := getPlayerDivision(tx, playerID, leagueID)
+	if err == nil {
+		return division, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Division{}, err
+	}
+
+	divisions, err := getDivisionsByLeagueID(tx, leagueID)
+	if err != nil {
+		return Division{}, err
+	}
+	if len(divisions) == 0 {
+		return Division{}, nil
+	}
+
+	division, err = getRatedPlayerDivision(tx, playerID, leagueID, divisions)
+	if err != nil {
+		return Division{}, err
+	}
+	if err := setPlayerDivision(tx, playerID, division); err != nil {
+		return Division{}, err
+	}
+
+	return division, nil
+}
+
+// getRatedPlayerDivision returns the division the player would be put in by
+// seedDivisions given their rank among the rated players of the league, or
+// the lowest division if the player has no rating.
+func getRatedPlayerDivision(
+	tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob, divisions []Division,
+) (Division, error) {
+	var rank struct {
+		Better int
+		Total  int
+	}
+	query := `
+        SELECT
+            (SELECT COUNT(*) FROM PlayerRating AS Other
+             WHERE Other.LeagueID = PlayerRating.LeagueID AND Other.Rating > PlayerRating.Rating) AS Better,
+            (SELECT COUNT(*) FROM PlayerRating AS Other
+             WHERE Other.LeagueID = PlayerRating.LeagueID) AS Total
+        FROM PlayerRating
+        WHERE PlayerRating.PlayerID = ? AND PlayerRating.LeagueID = ?
+        LIMIT 1`
+	if err := tx.Get(&rank, query, playerID, leagueID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return divisions[len(divisions)-1], nil
+		}
+		return Division{}, err
+	}
+
+	perDivision := (rank.Total + len(divisions) - 1) / len(divisions)
+	return divisions[rank.Better/perDivision], nil
+}
+
+// getDivisionMembersSortedByRating returns the ID of the players of each
+// division of a league indexed by division ID, best rated player first.
+func getDivisionMembersSortedByRating(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (
+	map[util.UUIDAsBlob][]util.UUIDAsBlob, error,
+) {
+	query := `
+        SELECT PlayerDivision.PlayerID, PlayerDivision.DivisionID
+        FROM PlayerDivision
+        LEFT JOIN PlayerRating ON(
+            PlayerRating.PlayerID = PlayerDivision.PlayerID
+            AND PlayerRating.LeagueID = PlayerDivision.LeagueID
+        )
+        WHERE PlayerDivision.LeagueID = ?
+        ORDER BY COALESCE(PlayerRating.Rating, ?) DESC`
+
+	var rows []struct {
+		PlayerID   util.UUIDAsBlob
+		DivisionID util.UUIDAsBlob
+	}
+	if err := tx.Select(&rows, query, leagueID, rating.BaseRating); err != nil {
+		return nil, err
+	}
+
+	ret := map[util.UUIDAsBlob][]util.UUIDAsBlob{}
+	for _, v := range rows {
+		ret[v.DivisionID] = append(ret[v.DivisionID], v.PlayerID)
+	}
+
+	return ret, nil
+}
+
+// computeDivisionMoves returns the new division of the players that need to
+// be promoted or relegated indexed by player ID.
+// divisions must be sorted by tier and members by rating, best player first.
+func computeDivisionMoves(
+	divisions []Division,
+	members map[util.UUIDAsBlob][]util.UUIDAsBlob,
+) map[util.UUIDAsBlob]Division {
+	ret := map[util.UUIDAsBlob]Division{}
+
+	for i, division := range divisions {
+		players := members[division.ID]
+
+		promoted := 0
+		if i > 0 {
+			promoted = division.PromotionCount
+			if promoted > len(players) {
+				promoted = len(players)
+			}
+			for _, playerID := range players[:promoted] {
+				ret[playerID] = divisions[i-1]
+			}
+		}
+
+		if i < len(divisions)-1 {
+			// Don't relegate someone we just promoted in small divisions.
+			relegated := division.RelegationCount
+			if relegated > len(players)-promoted {
+				relegated = len(players) - promoted
+			}
+			for _, playerID := range players[len(players)-relegated:] {
+				ret[playerID] = divisions[i+1]
+			}
+		}
+	}
+
+	return ret
+}
+
+// reshuffleDivisions promotes and relegates the players of a league according
+// to their current rating and notifies them.
+func (b *Back) reshuffleDivisions(tx *sqlx.Tx, league League) error {
+	divisions, err := getDivisionsByLeagueID(tx, league.ID)
+	if err != nil {
+		return err
+	}
+	if len(divisions) < 2 {
+		return nil
+	}
+
+	members, err := getDivisionMembersSortedByRating(tx, league.ID)
+	if err != nil {
+		return err
+	}
+
+	moves := computeDivisionMoves(divisions, members)
+	if len(moves) == 0 {
+		return nil
+	}
+
+	changes := make([]DivisionChange, 0, len(moves))
+	for playerID, to := range moves {
+		from, err := getPlayerDivision(tx, playerID, league.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := setPlayerDivision(tx, playerID, to); err != nil {
+			return err
+		}
+
+		player, err := getPlayerByID(tx, playerID)
+		if err != nil {
+			return err
+		}
+
+		changes = append(changes, DivisionChange{Player: player, From: from, To: to})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].To.Tier != changes[j].To.Tier {
+			return changes[i].To.Tier < changes[j].To.Tier
+		}
+		return changes[i].Player.Name < changes[j].Player.Name
+	})
+
+	b.sendDivisionChangesNotifications(league, changes)
+
+	return nil
+}
+
+// seedDivisions spreads all the rated players of a league evenly across its
+// divisions, best players in the top division.
+func seedDivisions(tx *sqlx.Tx, leagueID util.UUIDAsBlob) error {
+	divisions, err := getDivisionsByLeagueID(tx, leagueID)
+	if err != nil {
+		return err
+	}
+	if len(divisions) == 0 {
+		return util.ErrPublic("this league has no divisions")
+	}
+
+	var playerIDs []util.UUIDAsBlob
+	if err := tx.Select(
+		&playerIDs,
+		`SELECT PlayerID FROM PlayerRating WHERE LeagueID = ? ORDER BY Rating DESC`,
+		leagueID,
+	); err != nil {
+		return err
+	}
+
+	perDivision := (len(playerIDs) + len(divisions) - 1) / len(divisions)
+	for k, playerID := range playerIDs {
+		if err := setPlayerDivision(tx, playerID, divisions[k/perDivision]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type DivisionChange struct {
+	Player   Player
+	From, To Division
+}
+
+func (c DivisionChange) IsPromotion() bool {
+	return c.To.Tier < c.From.Tier
+}
+
+// AddDivision creates a new division at the bottom of a league.
+func (b *Back) AddDivision(shortcode, name string, promotionCount, relegationCount int) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if name == "" {
+			return util.ErrPublic("you need to give the division a name")
+		}
+		if promotionCount < 0 || relegationCount < 0 {
+			return util.ErrPublic("promotion and relegation counts cannot be negative")
+		}
+
+		divisions, err := getDivisionsByLeagueID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+
+		division := NewDivision(league.ID, name, len(divisions), promotionCount, relegationCount)
+		return division.insert(tx)
+	})
+}
+
+// SeedDivisions resets the division of every rated player of a league
+// according to their rating.
+func (b *Back) SeedDivisions(shortcode string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		return seedDivisions(tx, league.ID)
+	})
+}
+
+func (b *Back) GetDivisions(shortcode string) (ret []Division, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		ret, err = getDivisionsByLeagueID(tx, league.ID)
+		return err
+	})
+}
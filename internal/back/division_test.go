@@ -0,0 +1,226 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/util"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestComputeDivisionMoves(t *testing.T) {
+	id := func(b byte) util.UUIDAsBlob {
+		var ret util.UUIDAsBlob
+		ret[0] = b
+		return ret
+	}
+
+	divisions := []Division{
+		{ID: id(1), Tier: 0, PromotionCount: 0, RelegationCount: 2},
+		{ID: id(2), Tier: 1, PromotionCount: 1, RelegationCount: 1},
+		{ID: id(3), Tier: 2, PromotionCount: 2, RelegationCount: 0},
+	}
+	members := map[util.UUIDAsBlob][]util.UUIDAsBlob{
+		id(1): {id(10), id(11), id(12), id(13)},
+		id(2): {id(20), id(21), id(22)},
+		id(3): {id(30)}, // fewer players than promotions
+	}
+
+	expected := map[util.UUIDAsBlob]Division{
+		id(12): divisions[1],
+		id(13): divisions[1],
+		id(20): divisions[0],
+		id(22): divisions[2],
+		id(30): divisions[1],
+	}
+
+	actual := computeDivisionMoves(divisions, members)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("moves do not match\nexpected: %#v\nactual  : %#v", expected, actual)
+	}
+
+	// A player cannot be both promoted and relegated.
+	members[id(2)] = []util.UUIDAsBlob{id(20)}
+	actual = computeDivisionMoves(divisions, members)
+	if actual[id(20)].ID != id(1) {
+		t.Errorf("expected the only player of the middle division to be promoted")
+	}
+}
+
+// nolint:funlen
+func TestDivisions(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	if err := back.AddDivision("testa", "Gold", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.AddDivision("testa", "Silver", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+
+		ratings := map[string]float64{"Darunia": 1800, "Nabooru": 1700, "Rauru": 1600, "Ruto": 1500}
+		for name, v := range ratings {
+			player, err := getPlayerByName(tx, name)
+			if err != nil {
+				return err
+			}
+
+			rating := NewPlayerRating(player.ID, league.ID)
+			rating.Rating = v
+			if err := rating.upsert(tx); err != nil {
+				return err
+			}
+		}
+
+		if err := seedDivisions(tx, league.ID); err != nil {
+			return err
+		}
+
+		// Rated after the divisions were seeded.
+		saria, err := getPlayerByName(tx, "Saria")
+		if err != nil {
+			return err
+		}
+		rating := NewPlayerRating(saria.ID, league.ID)
+		rating.Rating = 1900
+		return rating.upsert(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDivisions := func(expected map[string]string) {
+		t.Helper()
+		if err := back.transaction(func(tx *sqlx.Tx) error {
+			league, err := getLeagueByShortCode(tx, "testa")
+			if err != nil {
+				return err
+			}
+
+			for name, divisionName := range expected {
+				player, err := getPlayerByName(tx, name)
+				if err != nil {
+					return err
+				}
+				division, err := getOrAssignPlayerDivision(tx, player.ID, league.ID)
+				if err != nil {
+					return err
+				}
+				if division.Name != divisionName {
+					t.Errorf("expected %s in division %s, got %s", name, divisionName, division.Name)
+				}
+			}
+
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkDivisions(map[string]string{
+		"Darunia": "Gold", "Nabooru": "Gold", "Rauru": "Silver", "Ruto": "Silver",
+		"Saria": "Gold",   // rated, placed by rating
+		"Zelda": "Silver", // unrated, goes to the bottom
+	})
+
+	// Ruto now outranks Nabooru.
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+		player, err := getPlayerByName(tx, "Ruto")
+		if err != nil {
+			return err
+		}
+
+		rating := NewPlayerRating(player.ID, league.ID)
+		rating.Rating = 2000
+		if err := rating.upsert(tx); err != nil {
+			return err
+		}
+
+		return back.reshuffleDivisions(tx, league)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDivisions(map[string]string{
+		"Darunia": "Gold", "Nabooru": "Silver", "Rauru": "Silver", "Ruto": "Gold", "Zelda": "Silver",
+	})
+}
+
+// Sessions created before the league had divisions are shared by every
+// division.
+func TestSessionWithoutDivision(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	var session MatchSession
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testb")
+		if err != nil {
+			return err
+		}
+
+		session = NewMatchSession(league.ID, time.Now().Add(-MatchSessionJoinableAfterOffset))
+		session.Status = MatchSessionStatusJoinable
+		return session.insert(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.AddDivision("testb", "Gold", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.AddDivision("testb", "Silver", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testb")
+		if err != nil {
+			return err
+		}
+		divisions, err := getDivisionsByLeagueID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+		for _, v := range divisions {
+			if err := back.createScheduledMatchSession(tx, league, v, session.StartDate.Time()); err != nil {
+				return err
+			}
+		}
+
+		var count int
+		if err := tx.Get(&count, `SELECT COUNT(*) FROM MatchSession WHERE LeagueID = ?`, league.ID); err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("expected no session to be created next to the existing one, got %d sessions", count)
+		}
+
+		player, err := getPlayerByName(tx, "Darunia")
+		if err != nil {
+			return err
+		}
+		joined, err := joinCurrentMatchSessionTx(tx, player, league)
+		if err != nil {
+			return err
+		}
+		if joined.ID != session.ID {
+			t.Errorf("expected to join the session without division, got %s", joined.ID)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
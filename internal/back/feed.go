@@ -0,0 +1,153 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"kaepora/internal/util"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	feedSessionsCount = 20
+	feedPeriodsCount  = 5
+)
+
+// LeagueFeed contains the latest public results of a league, as announced
+// on Discord.
+type LeagueFeed struct {
+	League   League
+	Sessions []FeedSession // most recent first
+	Periods  []FeedPeriod  // most recent first
+}
+
+// Updated returns the date of the latest entry of the feed.
+func (f LeagueFeed) Updated() time.Time {
+	var ret time.Time
+	if len(f.Sessions) > 0 {
+		ret = f.Sessions[0].EndedAt
+	}
+	if len(f.Periods) > 0 && f.Periods[0].End.After(ret) {
+		ret = f.Periods[0].End
+	}
+
+	return ret
+}
+
+// FeedSession is a closed session and its public recap.
+type FeedSession struct {
+	Session  MatchSession
+	MatchIDs []util.UUIDAsBlob
+	EndedAt  time.Time // end of the last match
+	Recap    string    // same table as the session recap notification
+}
+
+// FeedPeriod is a closed rating period and its leaderboard movements.
+type FeedPeriod struct {
+	Start, End time.Time
+	Movers     []LeaderboardEntry
+	Newcomers  []LeaderboardEntry
+}
+
+// GetLeagueFeed returns the latest closed sessions and rating periods of a
+// league.
+func (b *Back) GetLeagueFeed(shortcode string) (ret LeagueFeed, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret.League, err = getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		ret.Sessions, err = getFeedSessions(tx, ret.League)
+		if err != nil {
+			return err
+		}
+
+		ret.Periods, err = getFeedPeriods(tx, ret.League)
+		return err
+	})
+}
+
+func getFeedSessions(tx *sqlx.Tx, league League) ([]FeedSession, error) {
+	var sessions []MatchSession
+	if err := tx.Select(&sessions, `
+        SELECT * FROM MatchSession
+        WHERE LeagueID = ? AND Status = ?
+          AND EXISTS(SELECT 1 FROM Match WHERE Match.MatchSessionID = MatchSession.ID)
+        ORDER BY DATETIME(StartDate) DESC
+        LIMIT ?`,
+		league.ID, MatchSessionStatusClosed, feedSessionsCount,
+	); err != nil {
+		return nil, err
+	}
+
+	ret := make([]FeedSession, 0, len(sessions))
+	for _, session := range sessions {
+		matches, err := getMatchesBySessionID(tx, session.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		v := FeedSession{Session: session, EndedAt: session.StartDate.Time()}
+		for _, match := range matches {
+			v.MatchIDs = append(v.MatchIDs, match.ID)
+			if end := match.EndedAt.Time.Time(); match.EndedAt.Valid && end.After(v.EndedAt) {
+				v.EndedAt = end
+			}
+		}
+
+		var recap strings.Builder
+		writeResultsTable(tx, &recap, matches, RecapScopePublic, nil)
+		v.Recap = recap.String()
+
+		ret = append(ret, v)
+	}
+
+	return ret, nil
+}
+
+// getFeedPeriods returns the closed rating periods that moved the
+// leaderboard, like sendLeaderboardMoversNotification.
+func getFeedPeriods(tx *sqlx.Tx, league League) ([]FeedPeriod, error) {
+	calendar, err := getRatingCalendar(tx, league)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := getFirstMatchStartOfLeague(tx, league.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	firstPeriod := calendar.start(first.Time())
+
+	var ret []FeedPeriod
+	start := calendar.start(time.Now())
+	for i := 0; i < feedPeriodsCount; i++ {
+		start = calendar.start(start.Add(-time.Second))
+		if start.Before(firstPeriod) {
+			break
+		}
+
+		_, movers, newcomers, err := getLeaderboardMoves(tx, league, calendar, start)
+		if err != nil {
+			return nil, err
+		}
+		if len(movers) == 0 && len(newcomers) == 0 {
+			continue
+		}
+
+		ret = append(ret, FeedPeriod{
+			Start:     start,
+			End:       calendar.next(start),
+			Movers:    movers,
+			Newcomers: newcomers,
+		})
+	}
+
+	return ret, nil
+}
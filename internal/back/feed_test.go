@@ -0,0 +1,27 @@
+package back // nolint:testpackage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeagueFeed(t *testing.T) {
+	back := createRacedTestBack(t, 3) // during the current rating period
+
+	feed, err := back.GetLeagueFeed("testa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feed.Sessions) != 3 || len(feed.Periods) != 0 {
+		t.Fatalf("expected 3 sessions and no period, got %d and %d", len(feed.Sessions), len(feed.Periods))
+	}
+	for _, v := range feed.Sessions {
+		if len(v.MatchIDs) == 0 || !strings.Contains(v.Recap, "Player 1") {
+			t.Errorf("expected matches and a recap, got %#v", v)
+		}
+	}
+	if !feed.Updated().Equal(feed.Sessions[0].EndedAt) {
+		t.Errorf("expected the feed to be updated by the last session, got %s", feed.Updated())
+	}
+}
@@ -39,6 +39,21 @@ func (g *Game) insert(tx *sqlx.Tx) error {
 	return nil
 }
 
+func (g *Game) update(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Update("Game").SetMap(squirrel.Eq{
+		"Name": g.Name,
+	}).Where("Game.ID = ?", g.ID).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func getGames(tx *sqlx.Tx) ([]Game, error) {
 	var ret []Game
 	if err := tx.Select(&ret, "SELECT * FROM Game ORDER BY Name ASC"); err != nil {
@@ -47,3 +62,13 @@ func getGames(tx *sqlx.Tx) ([]Game, error) {
 
 	return ret, nil
 }
+
+func getGameByID(tx *sqlx.Tx, id util.UUIDAsBlob) (Game, error) {
+	var ret Game
+	query := `SELECT * FROM Game WHERE Game.ID = ? LIMIT 1`
+	if err := tx.Get(&ret, query, id); err != nil {
+		return Game{}, err
+	}
+
+	return ret, nil
+}
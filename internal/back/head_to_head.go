@@ -0,0 +1,232 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RatingTrendDays is how far back in time the rating trend of a player goes.
+const RatingTrendDays = 30
+
+// HeadToHead is the record between two players across all leagues, every
+// [2] array is indexed like Players.
+type HeadToHead struct {
+	Players [2]Player
+	Leagues []HeadToHeadLeague // only leagues the two players met in
+	Matches []HeadToHeadMatch  // ended matches, most recent first
+}
+
+// HeadToHeadLeague is the record between two players in a single league.
+type HeadToHeadLeague struct {
+	League  League
+	Wins    [2]int
+	Draws   int
+	Ratings [2]PlayerRating
+
+	// Forecast of each player against the other using the current ratings
+	// and the rating system of the league.
+	Forecasts [2]rating.Forecast
+
+	// Rating change of each player over the last RatingTrendDays.
+	Trends [2]float64
+
+	// Mean of the time of the first player minus the time of the second,
+	// only counting matches both players finished.
+	AverageDelta time.Duration
+	TimedMatches int
+}
+
+// Races returns the number of matches between the two players in the league.
+func (h HeadToHeadLeague) Races() int {
+	return h.Wins[0] + h.Wins[1] + h.Draws
+}
+
+// HeadToHeadMatch is a single match between two players.
+type HeadToHeadMatch struct {
+	Match   Match
+	League  League
+	Entries [2]MatchEntry
+}
+
+// GetHeadToHead returns the record between two players given their names.
+func (b *Back) GetHeadToHead(name1, name2 string) (ret HeadToHead, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		p1, err := getPlayerByName(tx, name1)
+		if err != nil {
+			return err
+		}
+		p2, err := getPlayerByName(tx, name2)
+		if err != nil {
+			return err
+		}
+
+		ret, err = getHeadToHead(tx, p1, p2)
+		return err
+	})
+}
+
+// GetHeadToHeadForDiscordUser returns the record between the player linked
+// to the given Discord user and the player of the given name.
+func (b *Back) GetHeadToHeadForDiscordUser(discordID, opponentName string) (ret HeadToHead, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		player, err := getPlayerByDiscordID(tx, discordID)
+		if err != nil {
+			return err
+		}
+
+		opponent, err := getPlayerByName(tx, opponentName)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("no player found with name '%s'", opponentName))
+			}
+			return err
+		}
+
+		ret, err = getHeadToHead(tx, player, opponent)
+		return err
+	})
+}
+
+func getHeadToHead(tx *sqlx.Tx, p1, p2 Player) (HeadToHead, error) {
+	if p1.ID == p2.ID {
+		return HeadToHead{}, util.ErrPublic("a player cannot face themselves")
+	}
+
+	ret := HeadToHead{Players: [2]Player{p1, p2}}
+	matches, err := getEndedMatchesBetween(tx, p1.ID, p2.ID)
+	if err != nil {
+		return HeadToHead{}, err
+	}
+
+	leagues := map[util.UUIDAsBlob]int{} // index in ret.Leagues
+	var deltas []time.Duration           // sum of time deltas, indexed like ret.Leagues
+	for k := range matches {
+		e1, e2, err := matches[k].getPlayerAndOpponentEntries(p1.ID)
+		if err != nil {
+			return HeadToHead{}, err
+		}
+
+		i, ok := leagues[matches[k].LeagueID]
+		if !ok {
+			league, err := getHeadToHeadLeague(tx, p1.ID, p2.ID, matches[k].LeagueID)
+			if err != nil {
+				return HeadToHead{}, err
+			}
+
+			i = len(ret.Leagues)
+			leagues[matches[k].LeagueID] = i
+			ret.Leagues = append(ret.Leagues, league)
+			deltas = append(deltas, 0)
+		}
+
+		league := &ret.Leagues[i]
+		switch {
+		case e1.HasWon():
+			league.Wins[0]++
+		case e2.HasWon():
+			league.Wins[1]++
+		default:
+			league.Draws++
+		}
+
+		if d1, d2 := e1.Duration(), e2.Duration(); d1 > 0 && d2 > 0 {
+			deltas[i] += d1 - d2
+			league.TimedMatches++
+		}
+
+		ret.Matches = append(ret.Matches, HeadToHeadMatch{
+			Match:   matches[k],
+			League:  league.League,
+			Entries: [2]MatchEntry{e1, e2},
+		})
+	}
+
+	for k := range ret.Leagues {
+		if n := ret.Leagues[k].TimedMatches; n > 0 {
+			ret.Leagues[k].AverageDelta = (deltas[k] / time.Duration(n)).Round(time.Second)
+		}
+	}
+
+	return ret, nil
+}
+
+// getHeadToHeadLeague returns the current ratings, forecast and trends of two
+// players in a league, results are left to the caller.
+func getHeadToHeadLeague(tx *sqlx.Tx, p1, p2, leagueID util.UUIDAsBlob) (HeadToHeadLeague, error) {
+	league, err := getLeagueByID(tx, leagueID)
+	if err != nil {
+		return HeadToHeadLeague{}, err
+	}
+
+	system, err := getLeagueRatingSystem(league)
+	if err != nil {
+		return HeadToHeadLeague{}, err
+	}
+
+	ret := HeadToHeadLeague{League: league}
+	since := time.Now().AddDate(0, 0, -RatingTrendDays)
+	for k, id := range [2]util.UUIDAsBlob{p1, p2} {
+		ret.Ratings[k], err = getPlayerRating(tx, id, leagueID)
+		if err != nil {
+			return HeadToHeadLeague{}, err
+		}
+
+		ret.Trends[k], err = getRatingTrend(tx, ret.Ratings[k], since)
+		if err != nil {
+			return HeadToHeadLeague{}, err
+		}
+	}
+
+	r1, r2 := ret.Ratings[0].SystemRating(), ret.Ratings[1].SystemRating()
+	ret.Forecasts = [2]rating.Forecast{rating.Predict(system, r1, r2), rating.Predict(system, r2, r1)}
+
+	return ret, nil
+}
+
+// getRatingTrend returns how much the given current rating changed since the
+// given date, players without history before that date started from the
+// default rating.
+func getRatingTrend(tx *sqlx.Tx, current PlayerRating, since time.Time) (float64, error) {
+	previous := NewPlayerRating(current.PlayerID, current.LeagueID)
+	query := `
+        SELECT * FROM PlayerRatingHistory
+        WHERE PlayerID = ? AND LeagueID = ? AND RatingPeriodStartedAt < ?
+        ORDER BY RatingPeriodStartedAt DESC LIMIT 1`
+	if err := tx.Get(
+		&previous, query,
+		current.PlayerID, current.LeagueID, util.TimeAsTimestamp(since),
+	); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return current.Rating - previous.Rating, nil
+}
+
+// getEndedMatchesBetween returns the ended matches two players raced against
+// each other, most recent first.
+func getEndedMatchesBetween(tx *sqlx.Tx, p1, p2 util.UUIDAsBlob) ([]Match, error) {
+	var matches []Match
+	query := `
+        SELECT Match.* FROM Match
+        INNER JOIN MatchEntry AS Entry1 ON(Entry1.MatchID = Match.ID AND Entry1.PlayerID = ?)
+        INNER JOIN MatchEntry AS Entry2 ON(Entry2.MatchID = Match.ID AND Entry2.PlayerID = ?)
+        WHERE Match.EndedAt IS NOT NULL
+        ORDER BY Match.StartedAt DESC`
+	if err := tx.Select(&matches, query, p1, p2); err != nil {
+		return nil, err
+	}
+
+	for k := range matches {
+		if err := injectEntries(tx, &matches[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
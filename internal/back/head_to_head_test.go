@@ -0,0 +1,36 @@
+package back // nolint:testpackage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHeadToHead(t *testing.T) {
+	back := createRacedTestBack(t, 1) // Zelda forfeits all of her races
+
+	profile, err := back.GetPlayerProfile("Zelda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opponent := profile.Matches[0].Opponent.Name
+
+	h2h, err := back.GetHeadToHead("Zelda", opponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h2h.Matches) == 0 || len(h2h.Leagues) != 1 {
+		t.Fatalf("expected at least a match in a single league, got %d in %d", len(h2h.Matches), len(h2h.Leagues))
+	}
+
+	league := h2h.Leagues[0]
+	if league.Wins[0] != 0 || league.Wins[1] != len(h2h.Matches) || league.TimedMatches != 0 {
+		t.Errorf("expected %s to win every race against Zelda, got %#v", opponent, league)
+	}
+	if p := league.Forecasts[0].WinProbability + league.Forecasts[1].WinProbability; math.Abs(p-1) > 0.001 {
+		t.Errorf("expected win probabilities to sum to 1, got %f", p)
+	}
+
+	if _, err := back.GetHeadToHead("Zelda", "Zelda"); err == nil {
+		t.Error("expected an error when comparing a player with themselves")
+	}
+}
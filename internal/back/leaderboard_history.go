@@ -0,0 +1,230 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"kaepora/internal/util"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LeaderboardPeriod is the leaderboard of a league as it was at the end of a
+// rating period, sorted by rating.
+type LeaderboardPeriod struct {
+	Start, End time.Time
+
+	// Start of the rating period before this one, zero if the league had no
+	// match before Start.
+	Previous time.Time
+
+	Entries []LeaderboardEntry
+}
+
+// IsCurrent returns true if the period is not over yet.
+func (p LeaderboardPeriod) IsCurrent() bool {
+	return p.End.After(time.Now())
+}
+
+// GetLeaderboardAtForShortcode returns the leaderboard of a league at the end
+// of the rating period containing the given date, with the movements since
+// the period before it.
+func (b *Back) GetLeaderboardAtForShortcode(shortcode string, at time.Time) (ret LeaderboardPeriod, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		calendar, err := getRatingCalendar(tx, league)
+		if err != nil {
+			return err
+		}
+
+		ret = LeaderboardPeriod{Start: calendar.start(at), End: calendar.next(at)}
+		firstMatchStart, err := getFirstMatchStartOfLeague(tx, league.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if calendar.start(firstMatchStart.Time()).Before(ret.Start) {
+			ret.Previous = calendar.start(ret.Start.Add(-time.Second))
+		}
+
+		ret.Entries, err = getLeaderboardBefore(tx, league, calendar, ret.End)
+		if err != nil {
+			return err
+		}
+
+		previous, err := getLeaderboardBefore(tx, league, calendar, ret.Start)
+		if err != nil {
+			return err
+		}
+		setLeaderboardMovements(ret.Entries, previous)
+
+		return nil
+	})
+}
+
+// getLeaderboardBefore returns the leaderboard of a league as it was at the
+// given date, which must be the start of a rating period. Divisions are not
+// historized and left empty.
+func getLeaderboardBefore(
+	tx *sqlx.Tx,
+	league League,
+	calendar ratingCalendar,
+	periodStart time.Time,
+) ([]LeaderboardEntry, error) {
+	system, err := getLeagueRatingSystem(league)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := getRatingsBefore(tx, league.ID, system, calendar, periodStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		LeaderboardEntry
+		PlayerID util.UUIDAsBlob
+	}
+	if err := tx.Select(&results, `
+        SELECT
+            Player.ID AS PlayerID,
+            Player.Name AS PlayerName,
+            Player.StreamURL AS PlayerStreamURL,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Wins,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Losses,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Draws,
+            SUM(CASE WHEN MatchEntry.Status = ? THEN 1 ELSE 0 END) AS Forfeits,
+            SUM(CASE WHEN MatchEntry.Status IN (?, ?) THEN 1 ELSE 0 END) AS Races
+        FROM MatchEntry
+        INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+        INNER JOIN Player ON(Player.ID = MatchEntry.PlayerID)
+        WHERE Match.LeagueID = ? AND Match.StartedAt < ? AND MatchEntry.Status != ?
+        GROUP BY Player.ID`,
+		MatchEntryOutcomeWin,
+		MatchEntryOutcomeLoss,
+		MatchEntryOutcomeDraw,
+		MatchEntryStatusForfeit,
+		MatchEntryStatusFinished, MatchEntryStatusForfeit,
+		league.ID, util.TimeAsTimestamp(periodStart), MatchEntryStatusInProgress,
+	); err != nil {
+		return nil, err
+	}
+
+	ret := make([]LeaderboardEntry, 0, len(results))
+	for _, v := range results {
+		r, ok := ratings[v.PlayerID]
+		if !ok || r.Deviation >= league.Leaderboard.Threshold() || v.Races < league.Leaderboard.MinRaces {
+			continue
+		}
+
+		v.LeaderboardEntry.Rating = r.Rating
+		v.LeaderboardEntry.Deviation = r.Deviation
+		ret = append(ret, v.LeaderboardEntry)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Rating > ret[j].Rating })
+	for k := range ret {
+		ret[k].Rank = k + 1
+	}
+
+	return ret, nil
+}
+
+// setLeaderboardMovements sets the rank of every entry on the whole
+// leaderboard and its movement compared to the previous leaderboard, which
+// must have its ranks set.
+func setLeaderboardMovements(entries, previous []LeaderboardEntry) {
+	byName := make(map[string]LeaderboardEntry, len(previous))
+	for _, v := range previous {
+		byName[v.PlayerName] = v
+	}
+
+	order := make([]int, len(entries))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return entries[order[i]].Rating > entries[order[j]].Rating
+	})
+
+	for rank, k := range order {
+		entries[k].Rank = rank + 1
+		if v, ok := byName[entries[k].PlayerName]; ok {
+			entries[k].PreviousRank = v.Rank
+			entries[k].RatingDelta = entries[k].Rating - v.Rating
+		}
+	}
+}
+
+// getLeaderboardMoves returns the leaderboard at the end of the rating
+// period that started at the given date, its biggest movers, and its
+// newcomers.
+func getLeaderboardMoves(
+	tx *sqlx.Tx,
+	league League,
+	calendar ratingCalendar,
+	periodStart time.Time,
+) (entries, movers, newcomers []LeaderboardEntry, _ error) {
+	entries, err := getLeaderboardBefore(tx, league, calendar, calendar.next(periodStart))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	previous, err := getLeaderboardBefore(tx, league, calendar, periodStart)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	setLeaderboardMovements(entries, previous)
+
+	return entries,
+		getLeaderboardMovers(entries, leaderboardMoversCount),
+		getLeaderboardNewcomers(entries),
+		nil
+}
+
+// getLeaderboardMovers returns the players who gained the most places on the
+// leaderboard, then the most rating, best first. Newcomers are left out.
+func getLeaderboardMovers(entries []LeaderboardEntry, n int) []LeaderboardEntry {
+	var ret []LeaderboardEntry
+	for _, v := range entries {
+		if v.RankDelta() > 0 || (!v.IsNew() && v.RatingDelta >= 1) {
+			ret = append(ret, v)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].RankDelta() != ret[j].RankDelta() {
+			return ret[i].RankDelta() > ret[j].RankDelta()
+		}
+		return ret[i].RatingDelta > ret[j].RatingDelta
+	})
+
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+
+	return ret
+}
+
+// getLeaderboardNewcomers returns the players who entered the leaderboard,
+// best rank first.
+func getLeaderboardNewcomers(entries []LeaderboardEntry) []LeaderboardEntry {
+	var ret []LeaderboardEntry
+	for _, v := range entries {
+		if v.IsNew() {
+			ret = append(ret, v)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Rank < ret[j].Rank })
+
+	return ret
+}
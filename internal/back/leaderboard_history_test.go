@@ -0,0 +1,90 @@
+package back // nolint:testpackage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLeaderboardMovements(t *testing.T) {
+	previous := []LeaderboardEntry{
+		{PlayerName: "Saria", Rating: 1700, Rank: 1},
+		{PlayerName: "Ruto", Rating: 1600, Rank: 2},
+		{PlayerName: "Nabooru", Rating: 1500, Rank: 3},
+	}
+	entries := []LeaderboardEntry{
+		{PlayerName: "Nabooru", Rating: 1750},
+		{PlayerName: "Saria", Rating: 1690},
+		{PlayerName: "Darunia", Rating: 1650},
+		{PlayerName: "Ruto", Rating: 1640},
+	}
+
+	setLeaderboardMovements(entries, previous)
+	expected := []struct {
+		rank, delta int
+		rating      float64
+		isNew       bool
+	}{
+		{1, 2, 250, false},
+		{2, -1, -10, false},
+		{3, 0, 0, true},
+		{4, -2, 40, false},
+	}
+	for k, v := range expected {
+		e := entries[k]
+		if e.Rank != v.rank || e.RankDelta() != v.delta || e.RatingDelta != v.rating || e.IsNew() != v.isNew {
+			t.Errorf("%s: expected %+v, got rank %d, delta %d, rating %.0f, new %t",
+				e.PlayerName, v, e.Rank, e.RankDelta(), e.RatingDelta, e.IsNew())
+		}
+	}
+
+	movers := getLeaderboardMovers(entries, 5)
+	if len(movers) != 2 || movers[0].PlayerName != "Nabooru" || movers[1].PlayerName != "Ruto" {
+		t.Errorf("unexpected movers: %v", movers)
+	}
+
+	newcomers := getLeaderboardNewcomers(entries)
+	if len(newcomers) != 1 || newcomers[0].PlayerName != "Darunia" {
+		t.Errorf("unexpected newcomers: %v", newcomers)
+	}
+}
+
+func TestLeaderboardHistory(t *testing.T) {
+	back := createRacedTestBack(t, 1) // during the current rating period
+
+	if _, err := back.SetLeagueLeaderboard("testa", "maxdeviation", "351"); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := back.GetLeaderboardForShortcode("testa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	period, err := back.GetLeaderboardAtForShortcode("testa", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !period.IsCurrent() || !period.Previous.IsZero() {
+		t.Errorf("unexpected period: %s - %s, previous %s", period.Start, period.End, period.Previous)
+	}
+	if len(period.Entries) != len(current) || len(current) == 0 {
+		t.Fatalf("expected %d entries, got %d", len(current), len(period.Entries))
+	}
+	for k, v := range period.Entries {
+		if !v.IsNew() || !current[k].IsNew() {
+			t.Errorf("expected %s to be new on the leaderboard", v.PlayerName)
+		}
+		if v.PlayerName != current[k].PlayerName || math.Abs(v.Rating-current[k].Rating) > 0.01 {
+			t.Errorf("expected %s (%.0f), got %s (%.0f)", current[k].PlayerName, current[k].Rating, v.PlayerName, v.Rating)
+		}
+	}
+
+	past, err := back.GetLeaderboardAtForShortcode("testa", time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(past.Entries) != 0 || past.IsCurrent() {
+		t.Errorf("expected an empty leaderboard a year ago, got %d entries", len(past.Entries))
+	}
+}
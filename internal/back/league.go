@@ -1,6 +1,7 @@
 package back
 
 import (
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"time"
 
@@ -19,7 +20,20 @@ type League struct {
 	Settings  string
 	Schedule  Schedule
 
+	Requirements LeagueRequirements
+	Leaderboard  LeagueLeaderboard
+	RatingSystem string // see rating.SystemIDs
+	RatingPeriod RatingPeriod
+
 	AnnounceDiscordChannelID null.String
+
+	// A paused league does not create nor advance its sessions and cannot be
+	// joined, see Maintenance for the global equivalent.
+	Paused bool
+
+	// Start of the rating period that was running on the last periodic
+	// check, used to detect when the period ends.
+	RatingPeriodStartedAt util.NullTimeAsTimestamp
 }
 
 func NewLeague(name string, shortCode string, gameID util.UUIDAsBlob, generator, settings string) League {
@@ -32,6 +46,9 @@ func NewLeague(name string, shortCode string, gameID util.UUIDAsBlob, generator,
 		ShortCode: shortCode,
 		Settings:  settings,
 		Schedule:  NewSchedule(),
+
+		RatingSystem: rating.DefaultSystem,
+		RatingPeriod: RatingPeriodWeekly,
 	}
 }
 
@@ -46,7 +63,14 @@ func (l *League) insert(tx *sqlx.Tx) error {
 		"Settings":  l.Settings,
 		"Schedule":  l.Schedule,
 
+		"Requirements": l.Requirements,
+		"Leaderboard":  l.Leaderboard,
+		"RatingSystem": l.RatingSystem,
+		"RatingPeriod": l.RatingPeriod,
+
 		"AnnounceDiscordChannelID": l.AnnounceDiscordChannelID,
+		"RatingPeriodStartedAt":    l.RatingPeriodStartedAt,
+		"Paused":                   l.Paused,
 	}).ToSql()
 	if err != nil {
 		return err
@@ -68,7 +92,14 @@ func (l *League) update(tx *sqlx.Tx) error {
 		"Settings":  l.Settings,
 		"Schedule":  l.Schedule,
 
+		"Requirements": l.Requirements,
+		"Leaderboard":  l.Leaderboard,
+		"RatingSystem": l.RatingSystem,
+		"RatingPeriod": l.RatingPeriod,
+
 		"AnnounceDiscordChannelID": l.AnnounceDiscordChannelID,
+		"RatingPeriodStartedAt":    l.RatingPeriodStartedAt,
+		"Paused":                   l.Paused,
 	}).Where("League.ID = ?", l.ID).ToSql()
 	if err != nil {
 		return err
@@ -0,0 +1,170 @@
+package back
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DeviationThreshold is the default maximum deviation of a ranked player, an
+// RD of 50 is about the average for active players.
+const DeviationThreshold = 220
+
+// LeagueLeaderboard are the conditions a player must meet to appear in the
+// leaderboards of a league, players who don't meet them yet have a
+// provisional rating. The zero value only uses DeviationThreshold.
+type LeagueLeaderboard struct {
+	MaxDeviation float64 `json:",omitempty"` // DeviationThreshold if zero
+	MinRaces     int     `json:",omitempty"` // rated races, forfeits included
+
+	// The first races of a player are placement races where they are paired
+	// against opponents whose rating is not provisional.
+	PlacementRaces int `json:",omitempty"`
+}
+
+// Threshold returns the deviation under which players are ranked.
+func (l LeagueLeaderboard) Threshold() float64 {
+	if l.MaxDeviation == 0 {
+		return DeviationThreshold
+	}
+
+	return l.MaxDeviation
+}
+
+// IsProvisional returns true if a player with the given deviation and number
+// of rated races does not appear on the leaderboard.
+func (l LeagueLeaderboard) IsProvisional(deviation float64, races int) bool {
+	return deviation >= l.Threshold() || races < l.MinRaces || races < l.PlacementRaces
+}
+
+// IsPlacing returns true if a player with the given number of rated races is
+// still running their placement races.
+func (l LeagueLeaderboard) IsPlacing(races int) bool {
+	return races < l.PlacementRaces
+}
+
+func (l LeagueLeaderboard) Validate() error {
+	if l.MaxDeviation < 0 || l.MinRaces < 0 || l.PlacementRaces < 0 {
+		return util.ErrPublic("leaderboard settings cannot be negative")
+	}
+
+	return nil
+}
+
+// Set sets a single setting from its textual representation, as given by an
+// admin.
+func (l *LeagueLeaderboard) Set(key, value string) error {
+	ret := *l
+
+	var err error
+	switch strings.ToLower(key) {
+	case "maxdeviation":
+		ret.MaxDeviation, err = strconv.ParseFloat(value, 64)
+	case "minraces":
+		ret.MinRaces, err = strconv.Atoi(value)
+	case "placementraces":
+		ret.PlacementRaces, err = strconv.Atoi(value)
+	default:
+		return util.ErrPublic(fmt.Sprintf("unknown leaderboard setting '%s'", key))
+	}
+
+	if err != nil {
+		return util.ErrPublic(fmt.Sprintf("invalid value for '%s': %s", key, err))
+	}
+
+	*l = ret
+	return nil
+}
+
+// String returns a human readable summary of the settings.
+func (l LeagueLeaderboard) String() string {
+	parts := []string{fmt.Sprintf("deviation under %.0f", l.Threshold())}
+
+	if l.MinRaces != 0 {
+		parts = append(parts, fmt.Sprintf("at least %d races", l.MinRaces))
+	}
+	if l.PlacementRaces != 0 {
+		parts = append(parts, fmt.Sprintf("%d placement races", l.PlacementRaces))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (l *LeagueLeaderboard) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(src), l)
+	case []byte:
+		return json.Unmarshal(src, l)
+	default:
+		return fmt.Errorf("expected []byte or string, got %T", src)
+	}
+}
+
+func (l LeagueLeaderboard) Value() (driver.Value, error) {
+	str, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(str), nil
+}
+
+// ratedRacesSubquery counts the rated races of the player of the PlayerRating
+// row it is used in.
+var ratedRacesSubquery = fmt.Sprintf(`(
+    SELECT COUNT(*) FROM MatchEntry AS RatedEntry
+    INNER JOIN Match AS RatedMatch ON(RatedMatch.ID = RatedEntry.MatchID)
+    WHERE RatedEntry.PlayerID = PlayerRating.PlayerID
+      AND RatedMatch.LeagueID = PlayerRating.LeagueID
+      AND RatedEntry.Status IN (%d, %d)
+)`, MatchEntryStatusFinished, MatchEntryStatusForfeit) // nolint:gochecknoglobals
+
+// getPlayerRatedRacesCount returns the number of rated races of a player in a
+// league, forfeits included.
+func getPlayerRatedRacesCount(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) (int, error) {
+	var ret int
+	query := `
+        SELECT COUNT(*) FROM MatchEntry
+        INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+        WHERE MatchEntry.PlayerID = ? AND Match.LeagueID = ? AND MatchEntry.Status IN (?, ?)`
+	if err := tx.Get(
+		&ret, query, playerID, leagueID,
+		MatchEntryStatusFinished, MatchEntryStatusForfeit,
+	); err != nil {
+		return 0, err
+	}
+
+	return ret, nil
+}
+
+// SetLeagueLeaderboard changes a single leaderboard setting of a league, see
+// LeagueLeaderboard.Set, and returns the updated settings.
+func (b *Back) SetLeagueLeaderboard(shortcode, key, value string) (ret LeagueLeaderboard, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if err := league.Leaderboard.Set(key, value); err != nil {
+			return err
+		}
+		if err := league.Leaderboard.Validate(); err != nil {
+			return err
+		}
+
+		ret = league.Leaderboard
+		return league.update(tx)
+	})
+}
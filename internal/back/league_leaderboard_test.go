@@ -0,0 +1,82 @@
+package back // nolint:testpackage
+
+import (
+	"testing"
+)
+
+func TestLeagueLeaderboard(t *testing.T) {
+	var l LeagueLeaderboard
+	if l.Threshold() != DeviationThreshold {
+		t.Errorf("expected the default threshold, got %.0f", l.Threshold())
+	}
+	if l.IsProvisional(DeviationThreshold-1, 0) || !l.IsProvisional(DeviationThreshold, 100) {
+		t.Error("expected only the deviation to matter by default")
+	}
+
+	for _, v := range [][2]string{
+		{"maxdeviation", "150"},
+		{"minraces", "5"},
+		{"placementraces", "3"},
+	} {
+		if err := l.Set(v[0], v[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Set("minraces", "five"); err == nil {
+		t.Error("expected an error on an invalid value")
+	}
+	if err := l.Set("nope", "1"); err == nil {
+		t.Error("expected an error on an unknown setting")
+	}
+
+	type entry struct {
+		deviation   float64
+		races       int
+		provisional bool
+		placing     bool
+	}
+	for _, v := range []entry{
+		{100, 2, true, true},
+		{100, 4, true, false},
+		{100, 5, false, false},
+		{150, 10, true, false},
+	} {
+		if actual := l.IsProvisional(v.deviation, v.races); actual != v.provisional {
+			t.Errorf("%.0f/%d: expected provisional %t, got %t", v.deviation, v.races, v.provisional, actual)
+		}
+		if actual := l.IsPlacing(v.races); actual != v.placing {
+			t.Errorf("%d races: expected placing %t, got %t", v.races, v.placing, actual)
+		}
+	}
+}
+
+func TestProvisionalLeaderboard(t *testing.T) {
+	back := createRacedTestBack(t, 3) // 6 players with 3 races each
+
+	check := func(key, value string, ranked, provisional int) {
+		if _, err := back.SetLeagueLeaderboard("testa", key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		leaderboard, err := back.GetLeaderboardForShortcode("testa")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leaderboard) != ranked {
+			t.Errorf("%s %s: expected %d ranked players, got %d", key, value, ranked, len(leaderboard))
+		}
+
+		entries, err := back.GetProvisionalPlayersForShortcode("testa")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != provisional {
+			t.Errorf("%s %s: expected %d provisional players, got %d", key, value, provisional, len(entries))
+		}
+	}
+
+	check("maxdeviation", "351", 6, 0)
+	check("minraces", "4", 0, 6)
+	check("minraces", "3", 6, 0)
+	check("maxdeviation", "1", 0, 6)
+}
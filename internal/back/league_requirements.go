@@ -0,0 +1,296 @@
+package back
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// LeagueRequirements are the conditions a player must meet to join a race in
+// a league, the zero value lets everyone in.
+type LeagueRequirements struct {
+	// Only the invited players can join.
+	InviteOnly       bool        `json:",omitempty"`
+	InvitedPlayerIDs []uuid.UUID `json:",omitempty"`
+
+	// Shortcode of the league where MinRating and MinRaces are checked.
+	PrerequisiteLeague string  `json:",omitempty"`
+	MinRating          float64 `json:",omitempty"`
+	MinRaces           int     `json:",omitempty"` // completed races, forfeits excluded
+
+	// Players joining a full session are put on its waitlist.
+	MaxPlayersPerSession int `json:",omitempty"`
+
+	// Age of the Discord account, or of the Player for players without one.
+	MinAccountAgeDays int `json:",omitempty"`
+}
+
+func (r LeagueRequirements) Validate() error {
+	if (r.MinRating != 0 || r.MinRaces != 0) && r.PrerequisiteLeague == "" {
+		return util.ErrPublic("a minimum rating or races count requires a prerequisite league")
+	}
+
+	if r.MinRaces < 0 || r.MaxPlayersPerSession < 0 || r.MinAccountAgeDays < 0 {
+		return util.ErrPublic("requirements cannot be negative")
+	}
+
+	// Odd players get kicked anyway, don't give them a spot to begin with.
+	if r.MaxPlayersPerSession%2 != 0 {
+		return util.ErrPublic("the maximum number of players per session must be even")
+	}
+
+	return nil
+}
+
+func (r LeagueRequirements) IsInvited(playerID uuid.UUID) bool {
+	for _, v := range r.InvitedPlayerIDs {
+		if v == playerID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Invite adds or removes a player from the list of invited players.
+func (r *LeagueRequirements) Invite(playerID uuid.UUID, invite bool) {
+	filtered := make([]uuid.UUID, 0, len(r.InvitedPlayerIDs)+1)
+	for _, v := range r.InvitedPlayerIDs {
+		if v != playerID {
+			filtered = append(filtered, v)
+		}
+	}
+
+	if invite {
+		filtered = append(filtered, playerID)
+	}
+
+	r.InvitedPlayerIDs = filtered
+}
+
+// Set sets a single requirement from its textual representation, as given
+// by an admin.
+func (r *LeagueRequirements) Set(key, value string) (err error) {
+	switch strings.ToLower(key) {
+	case "inviteonly":
+		r.InviteOnly, err = strconv.ParseBool(value)
+	case "prerequisite":
+		r.PrerequisiteLeague = value
+	case "minrating":
+		r.MinRating, err = strconv.ParseFloat(value, 64)
+	case "minraces":
+		r.MinRaces, err = strconv.Atoi(value)
+	case "maxplayers":
+		r.MaxPlayersPerSession, err = strconv.Atoi(value)
+	case "minaccountage":
+		r.MinAccountAgeDays, err = strconv.Atoi(value)
+	default:
+		return util.ErrPublic(fmt.Sprintf("unknown requirement '%s'", key))
+	}
+
+	if err != nil {
+		return util.ErrPublic(fmt.Sprintf("invalid value for '%s': %s", key, err))
+	}
+
+	return nil
+}
+
+// String returns a human readable summary of the requirements.
+func (r LeagueRequirements) String() string {
+	var parts []string
+
+	if r.InviteOnly {
+		parts = append(parts, fmt.Sprintf("invite only (%d invited)", len(r.InvitedPlayerIDs)))
+	}
+	if r.MinRating != 0 {
+		parts = append(parts, fmt.Sprintf("rating of at least %.0f in `%s`", r.MinRating, r.PrerequisiteLeague))
+	}
+	if r.MinRaces != 0 {
+		parts = append(parts, fmt.Sprintf("%d completed races in `%s`", r.MinRaces, r.PrerequisiteLeague))
+	}
+	if r.MaxPlayersPerSession != 0 {
+		parts = append(parts, fmt.Sprintf("max %d players per race", r.MaxPlayersPerSession))
+	}
+	if r.MinAccountAgeDays != 0 {
+		parts = append(parts, fmt.Sprintf("account older than %d days", r.MinAccountAgeDays))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// check returns a public error explaining why the player cannot join a race
+// in the league, the session capacity is handled by the caller.
+func (r LeagueRequirements) check(tx *sqlx.Tx, player Player) error {
+	if r.InviteOnly && !r.IsInvited(player.ID.UUID()) {
+		return util.ErrPublic("this league is invite-only and you have not been invited")
+	}
+
+	if r.MinAccountAgeDays > 0 {
+		minAge := time.Duration(r.MinAccountAgeDays) * 24 * time.Hour
+		if age := time.Since(playerAccountCreatedAt(player)); age < minAge {
+			return util.ErrPublic(fmt.Sprintf(
+				"your account must be at least %d days old to join this league",
+				r.MinAccountAgeDays,
+			))
+		}
+	}
+
+	if r.MinRating == 0 && r.MinRaces == 0 {
+		return nil
+	}
+
+	prereq, err := getLeagueByShortCode(tx, r.PrerequisiteLeague)
+	if err != nil {
+		return fmt.Errorf("unable to fetch prerequisite league: %w", err)
+	}
+
+	if r.MinRaces > 0 {
+		races, err := getPlayerCompletedRacesCount(tx, player.ID, prereq.ID)
+		if err != nil {
+			return err
+		}
+
+		if races < r.MinRaces {
+			return util.ErrPublic(fmt.Sprintf(
+				"you need to complete at least %d races in league `%s` to join this league (%d so far)",
+				r.MinRaces, prereq.ShortCode, races,
+			))
+		}
+	}
+
+	if r.MinRating > 0 {
+		var rating PlayerRating
+		err := tx.Get(
+			&rating, `SELECT * FROM PlayerRating WHERE PlayerID = ? AND LeagueID = ? LIMIT 1`,
+			player.ID, prereq.ID,
+		)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if errors.Is(err, sql.ErrNoRows) || rating.Rating < r.MinRating {
+			return util.ErrPublic(fmt.Sprintf(
+				"you need a rating of at least %.0f in league `%s` to join this league",
+				r.MinRating, prereq.ShortCode,
+			))
+		}
+	}
+
+	return nil
+}
+
+func getPlayerCompletedRacesCount(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) (int, error) {
+	var ret int
+	query := `
+        SELECT COUNT(*) FROM MatchEntry
+        INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+        WHERE MatchEntry.PlayerID = ? AND Match.LeagueID = ? AND MatchEntry.Status = ?`
+	if err := tx.Get(&ret, query, playerID, leagueID, MatchEntryStatusFinished); err != nil {
+		return 0, err
+	}
+
+	return ret, nil
+}
+
+// playerAccountCreatedAt returns the creation date of the player Discord
+// account (encoded in the snowflake ID), or the date the player registered
+// on the ladder if they don't have a Discord account.
+func playerAccountCreatedAt(player Player) time.Time {
+	const discordEpochMS = 1420070400000
+
+	if player.DiscordID.Valid {
+		snowflake, err := strconv.ParseUint(player.DiscordID.String, 10, 64)
+		if err == nil {
+			ms := int64(snowflake>>22) + discordEpochMS
+			return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+		}
+	}
+
+	return player.CreatedAt.Time()
+}
+
+func (r *LeagueRequirements) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(src), r)
+	case []byte:
+		return json.Unmarshal(src, r)
+	default:
+		return fmt.Errorf("expected []byte or string, got %T", src)
+	}
+}
+
+func (r LeagueRequirements) Value() (driver.Value, error) {
+	str, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(str), nil
+}
+
+// SetLeagueRequirement changes a single requirement of a league, see
+// LeagueRequirements.Set, and returns the updated requirements.
+func (b *Back) SetLeagueRequirement(shortcode, key, value string) (ret LeagueRequirements, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		if err := league.Requirements.Set(key, value); err != nil {
+			return err
+		}
+		if err := league.Requirements.Validate(); err != nil {
+			return err
+		}
+
+		if league.Requirements.PrerequisiteLeague != "" {
+			if _, err := getLeagueByShortCode(tx, league.Requirements.PrerequisiteLeague); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return util.ErrPublic("the prerequisite league does not exist")
+				}
+				return err
+			}
+		}
+
+		ret = league.Requirements
+		return league.update(tx)
+	})
+}
+
+// InvitePlayer adds or removes a player from the invite list of a league.
+func (b *Back) InvitePlayer(shortcode, playerName string, invite bool) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		player, err := getPlayerByName(tx, playerName)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("no player found with name '%s'", playerName))
+			}
+			return err
+		}
+
+		league.Requirements.Invite(player.ID.UUID(), invite)
+		return league.update(tx)
+	})
+}
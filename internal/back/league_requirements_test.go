@@ -0,0 +1,148 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// nolint:funlen
+func TestLeagueRequirements(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	if _, err := back.SetLeagueRequirement("testb", "minraces", "1"); err == nil {
+		t.Error("expected an error when setting minraces without a prerequisite league")
+	}
+	if _, err := back.SetLeagueRequirement("testb", "maxplayers", "3"); err == nil {
+		t.Error("expected an error when setting an odd maxplayers")
+	}
+
+	for _, v := range [][2]string{
+		{"prerequisite", "testa"},
+		{"minraces", "1"},
+	} {
+		if _, err := back.SetLeagueRequirement("testb", v[0], v[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	join := func(name string) (MatchSession, error) {
+		var session MatchSession
+		err := back.transaction(func(tx *sqlx.Tx) error {
+			league, err := getLeagueByShortCode(tx, "testb")
+			if err != nil {
+				return err
+			}
+			player, err := getPlayerByName(tx, name)
+			if err != nil {
+				return err
+			}
+
+			session, err = joinCurrentMatchSessionTx(tx, player, league)
+			return err
+		})
+
+		return session, err
+	}
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testb")
+		if err != nil {
+			return err
+		}
+
+		session := NewMatchSession(league.ID, time.Now().Add(-MatchSessionJoinableAfterOffset))
+		session.Status = MatchSessionStatusJoinable
+		return session.insert(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var public util.ErrPublic
+	if _, err := join("Darunia"); !errors.As(err, &public) {
+		t.Errorf("expected a public error for a player without races in the prerequisite league, got %v", err)
+	}
+
+	if _, err := back.SetLeagueRequirement("testb", "minraces", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.SetLeagueRequirement("testb", "inviteonly", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := join("Darunia"); !errors.As(err, &public) {
+		t.Errorf("expected a public error for a player that was not invited, got %v", err)
+	}
+
+	for _, v := range []string{"Darunia", "Nabooru", "Rauru", "Ruto"} {
+		if err := back.InvitePlayer("testb", v, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := back.SetLeagueRequirement("testb", "maxplayers", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"Darunia", "Nabooru"} {
+		if _, err := join(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := join("Rauru"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := join("Ruto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.PlayerIDs) != 2 || len(session.WaitlistPlayerIDs) != 2 {
+		t.Fatalf("expected 2 players and 2 waitlisted players, got %d and %d",
+			len(session.PlayerIDs), len(session.WaitlistPlayerIDs))
+	}
+
+	var darunia, rauru, ruto Player
+	if err := back.transaction(func(tx *sqlx.Tx) (err error) {
+		if darunia, err = getPlayerByName(tx, "Darunia"); err != nil {
+			return err
+		}
+		if rauru, err = getPlayerByName(tx, "Rauru"); err != nil {
+			return err
+		}
+		ruto, err = getPlayerByName(tx, "Ruto")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiting for a spot is not racing, other races can still be joined.
+	_, other, err := createJoinableSession(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.JoinCurrentMatchSession(rauru, other); err != nil {
+		t.Fatalf("expected a waitlisted player to be able to join another league, got %v", err)
+	}
+
+	session, err = back.CancelActiveMatchSession(darunia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.HasPlayerID(ruto.ID.UUID()) || session.HasPlayerID(rauru.ID.UUID()) ||
+		len(session.WaitlistPlayerIDs) != 0 {
+		t.Error("expected the first waitlisted player not racing elsewhere to take the spot of the cancelled one")
+	}
+}
+
+func TestPlayerAccountCreatedAt(t *testing.T) {
+	player := NewPlayer("Navi")
+	player.DiscordID = util.NullString("175928847299117063")
+
+	expected := time.Date(2016, 4, 30, 11, 18, 25, 796*int(time.Millisecond), time.UTC)
+	if actual := playerAccountCreatedAt(player); !actual.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
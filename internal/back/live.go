@@ -0,0 +1,133 @@
+package back
+
+import (
+	"kaepora/internal/util"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type LiveEventType int
+
+const (
+	LiveEventSessionStatus LiveEventType = iota // the session changed status
+	LiveEventCountdown                          // the session starts soon
+	LiveEventEntryEnd                           // a player finished or forfeited
+)
+
+// LiveEvent is a public event of a race session sent as it happens, it is
+// produced alongside the Notification of the same event. It contains no
+// spoiler: no seed, no spoiler log and no player comment.
+type LiveEvent struct {
+	Type      LiveEventType
+	CreatedAt time.Time
+	League    League
+	Session   MatchSession
+
+	// LiveEventEntryEnd only.
+	PlayerName string
+	Entry      MatchEntry
+}
+
+// liveEventsBufferSize is the number of events a subscriber can lag behind
+// before it starts missing events.
+const liveEventsBufferSize = 16
+
+// liveBroker fans out LiveEvent to every subscriber.
+type liveBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan LiveEvent]struct{}
+}
+
+// SubscribeLiveEvents returns a channel receiving every LiveEvent from now on
+// and a function that MUST be called to unsubscribe. Slow subscribers miss
+// events instead of blocking the Back.
+func (b *Back) SubscribeLiveEvents() (<-chan LiveEvent, func()) {
+	c := make(chan LiveEvent, liveEventsBufferSize)
+
+	b.live.mu.Lock()
+	b.live.subscribers[c] = struct{}{}
+	b.live.mu.Unlock()
+
+	return c, func() {
+		b.live.mu.Lock()
+		defer b.live.mu.Unlock()
+
+		if _, ok := b.live.subscribers[c]; ok {
+			delete(b.live.subscribers, c)
+			close(c)
+		}
+	}
+}
+
+func (b *Back) publishLiveEvent(ev LiveEvent) {
+	ev.CreatedAt = time.Now()
+	ev.Entry.Comment = ""
+
+	b.live.mu.Lock()
+	defer b.live.mu.Unlock()
+
+	for c := range b.live.subscribers {
+		select {
+		case c <- ev:
+		default:
+			log.Printf("warning: live event subscriber is lagging, dropped event %d", ev.Type)
+		}
+	}
+}
+
+// publishSessionLiveEvent publishes a session-wide event.
+func (b *Back) publishSessionLiveEvent(typ LiveEventType, league League, session MatchSession) {
+	b.publishLiveEvent(LiveEvent{
+		Type:    typ,
+		League:  league,
+		Session: session,
+	})
+}
+
+// newEntryEndLiveEvent returns the event of the end of the race of a single
+// player, it must only be published once the transaction is committed.
+func newEntryEndLiveEvent(tx *sqlx.Tx, match Match, player Player, entry MatchEntry) (LiveEvent, error) {
+	session, err := getMatchSessionByID(tx, match.MatchSessionID)
+	if err != nil {
+		return LiveEvent{}, err
+	}
+
+	league, err := getLeagueByID(tx, match.LeagueID)
+	if err != nil {
+		return LiveEvent{}, err
+	}
+
+	return LiveEvent{
+		Type:       LiveEventEntryEnd,
+		League:     league,
+		Session:    session,
+		PlayerName: player.Name,
+		Entry:      entry,
+	}, nil
+}
+
+// GetLiveMatchSession returns a session with the number of players who have
+// ended their race, as a starting point for LiveEvent subscribers.
+func (b *Back) GetLiveMatchSession(id util.UUIDAsBlob) (session MatchSession, league League, ended int, _ error) {
+	return session, league, ended, b.transaction(func(tx *sqlx.Tx) (err error) {
+		session, err = getMatchSessionByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		league, err = getLeagueByID(tx, session.LeagueID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Get(&ended, `
+            SELECT COUNT(*) FROM MatchEntry
+            INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+            WHERE Match.MatchSessionID = ? AND MatchEntry.Status IN(?, ?)`,
+			id, MatchEntryStatusFinished, MatchEntryStatusForfeit,
+		)
+	})
+}
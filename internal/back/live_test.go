@@ -0,0 +1,38 @@
+package back // nolint:testpackage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLiveEvents(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	events := make(map[LiveEventType]int)
+	live, unsubscribe := back.SubscribeLiveEvents()
+	done := make(chan struct{})
+	go func() {
+		for ev := range live {
+			if ev.Entry.Comment != "" {
+				t.Error("live event leaked a comment")
+			}
+			events[ev.Type]++
+		}
+		close(done)
+	}()
+
+	if err := raceTestSession(back); err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+	<-done
+	expected := map[LiveEventType]int{
+		LiveEventSessionStatus: 3, // joinable, preparing, closed
+		LiveEventEntryEnd:      6, // 1 per player who raced
+	}
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("live events count does not match\nexpected: %#v\nactual  : %#v", expected, events)
+	}
+}
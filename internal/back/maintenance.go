@@ -0,0 +1,99 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Maintenance is the global switch used when the bot cannot run races, eg.
+// when the seed generator is down or during a migration. While enabled the
+// periodic tasks do nothing and players cannot join races, see League.Paused
+// for the per-league equivalent.
+type Maintenance struct {
+	Enabled   bool
+	Message   string // shown to players, can be empty
+	UpdatedAt util.TimeAsTimestamp
+}
+
+// Error returns the public error to give to players trying to join a race
+// of the given league, or nil if nothing prevents it.
+func (m Maintenance) Error(league League) error {
+	if m.Enabled {
+		if m.Message != "" {
+			return util.ErrPublic(fmt.Sprintf("races are paused for maintenance: %s", m.Message))
+		}
+		return util.ErrPublic("races are paused for maintenance, please try again later")
+	}
+
+	if league.Paused {
+		return util.ErrPublic(fmt.Sprintf("races of league %s are paused for now, please try again later", league.Name))
+	}
+
+	return nil
+}
+
+func (b *Back) GetMaintenance() (ret Maintenance, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret, err = getMaintenance(tx)
+		return err
+	})
+}
+
+// SetMaintenance toggles the global maintenance mode, message explains the
+// reason to the players.
+func (b *Back) SetMaintenance(enabled bool, message string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		query, args, err := squirrel.Update("Maintenance").SetMap(squirrel.Eq{
+			"Enabled":   enabled,
+			"Message":   message,
+			"UpdatedAt": util.TimeAsTimestamp(time.Now()),
+		}).Where("Maintenance.ID = 1").ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(query, args...)
+		return err
+	})
+}
+
+// SetLeaguePaused pauses or resumes the sessions of a league. Unlike the
+// other league setters the league is not validated, a league with a broken
+// generator must still be pausable.
+func (b *Back) SetLeaguePaused(shortcode string, paused bool) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		league.Paused = paused
+		return league.update(tx)
+	})
+}
+
+// GetPausedLeagues returns the leagues whose sessions are paused.
+func (b *Back) GetPausedLeagues() (ret []League, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		return tx.Select(&ret, `SELECT * FROM League WHERE Paused = 1 ORDER BY Name ASC`)
+	})
+}
+
+func getMaintenance(tx *sqlx.Tx) (Maintenance, error) {
+	var ret Maintenance
+	query := `SELECT Enabled, Message, UpdatedAt FROM Maintenance WHERE ID = 1`
+	if err := tx.Get(&ret, query); err != nil {
+		return Maintenance{}, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,92 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// nolint:funlen
+func TestMaintenance(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	session, league, err := createJoinableSession(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var player Player
+	if err := back.transaction(func(tx *sqlx.Tx) (err error) {
+		player, err = getPlayerByName(tx, "Darunia")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	moveSession := func(start time.Time) {
+		t.Helper()
+		session.StartDate = util.TimeAsDateTimeTZ(start)
+		if err := back.transaction(session.update); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runAndCheck := func(status MatchSessionStatus) {
+		t.Helper()
+		if err := back.runPeriodicTasks(); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkSessionStatus(back, session.ID, status); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := back.SetMaintenance(true, "the generator is down"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = back.JoinCurrentMatchSession(player, league)
+	if !errors.Is(err, util.ErrPublic("")) || !strings.Contains(err.Error(), "the generator is down") {
+		t.Errorf("expected a public maintenance error, got %v", err)
+	}
+	moveSession(time.Now().Add(-MatchSessionPreparationOffset / 2))
+	runAndCheck(MatchSessionStatusJoinable)
+
+	// Races already running are still ended during the maintenance.
+	running := NewMatchSession(league.ID, time.Now().Add(-time.Hour))
+	running.Status = MatchSessionStatusInProgress
+	if err := back.transaction(running.insert); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.runPeriodicTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSessionStatus(back, running.ID, MatchSessionStatusClosed); err != nil {
+		t.Error(err)
+	}
+
+	if err := back.SetMaintenance(false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.SetLeaguePaused("nope", true); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error for an unknown league, got %v", err)
+	}
+	if err := back.SetLeaguePaused("testa", true); err != nil {
+		t.Fatal(err)
+	}
+	league.Paused = true
+	if _, err := back.JoinCurrentMatchSession(player, league); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when joining a paused league, got %v", err)
+	}
+	runAndCheck(MatchSessionStatusJoinable)
+
+	// Once resumed, races missed during the pause are cancelled.
+	moveSession(time.Now().Add(-time.Minute))
+	runAndCheck(MatchSessionStatusJoinable)
+	if err := back.SetLeaguePaused("testa", false); err != nil {
+		t.Fatal(err)
+	}
+	runAndCheck(MatchSessionStatusClosed)
+}
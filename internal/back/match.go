@@ -110,8 +110,13 @@ func (m *Match) update(tx *sqlx.Tx) error {
 		"StartedAt": m.StartedAt,
 		"EndedAt":   m.EndedAt,
 
+		"Generator": m.Generator,
+		"Settings":  m.Settings,
+		"Seed":      m.Seed,
+
 		"SpoilerLog":     m.SpoilerLog,
 		"GeneratorState": m.GeneratorState,
+		"SeedPatch":      m.SeedPatch,
 	}).Where("Match.ID = ?", m.ID).ToSql()
 	if err != nil {
 		return err
@@ -156,11 +161,14 @@ func getMatchByPlayerAndSession(tx *sqlx.Tx, playerID, sessionID util.UUIDAsBlob
 	return match, nil
 }
 
+// getMatchesByPeriod returns the finished matches that started in the given
+// [from, to) range, matches still running are left to the next ranking update.
 func getMatchesByPeriod(tx *sqlx.Tx, leagueID util.UUIDAsBlob, from, to util.TimeAsTimestamp) ([]Match, error) {
 	var matches []Match
 	query := `
         SELECT Match.* FROM Match
         WHERE Match.LeagueID = ? AND Match.StartedAt >= ? AND Match.StartedAt < ?
+          AND Match.EndedAt IS NOT NULL
         ORDER BY StartedAt ASC
         `
 
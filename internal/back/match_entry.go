@@ -32,6 +32,20 @@ func (m MatchEntry) HasWon() bool {
 	return m.Outcome == MatchEntryOutcomeWin
 }
 
+func (m MatchEntry) HasLost() bool {
+	return m.Outcome == MatchEntryOutcomeLoss
+}
+
+// Duration returns the time taken by a player to complete a match, zero if
+// the player did not finish.
+func (m MatchEntry) Duration() time.Duration {
+	if m.Status != MatchEntryStatusFinished {
+		return 0
+	}
+
+	return m.EndedAt.Time.Time().Sub(m.StartedAt.Time.Time())
+}
+
 type MatchEntryStatus int
 
 const ( // this is stored in DB, don't change values
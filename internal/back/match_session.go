@@ -37,6 +37,13 @@ type MatchSession struct {
 	StartDate util.TimeAsDateTimeTZ
 	Status    MatchSessionStatus
 	PlayerIDs util.UUIDArrayAsJSON // sorted by join date asc
+
+	// Only set when the league has divisions.
+	DivisionID util.NullUUIDAsBlob
+
+	// Players who tried to join when the session was full, sorted by join
+	// date asc. They take the spot of the players who cancel.
+	WaitlistPlayerIDs util.UUIDArrayAsJSON
 }
 
 // GetPlayerIDs returns the list of players who joined the session and should
@@ -56,6 +63,18 @@ func (s *MatchSession) HasPlayerID(needle uuid.UUID) bool {
 	return false
 }
 
+// WaitlistPosition returns the position of the player on the waitlist
+// starting at 1, or 0 if the player is not on the waitlist.
+func (s *MatchSession) WaitlistPosition(needle uuid.UUID) int {
+	for k, v := range s.WaitlistPlayerIDs {
+		if v == needle {
+			return k + 1
+		}
+	}
+
+	return 0
+}
+
 func NewMatchSession(leagueID util.UUIDAsBlob, startDate time.Time) MatchSession {
 	return MatchSession{
 		ID:        util.NewUUIDAsBlob(),
@@ -67,10 +86,25 @@ func NewMatchSession(leagueID util.UUIDAsBlob, startDate time.Time) MatchSession
 	}
 }
 
-func getMatchSessionByStartDate(tx *sqlx.Tx, leagueID util.UUIDAsBlob, startDate time.Time) (MatchSession, error) {
+// getMatchSessionByStartDate returns the session of a league division at
+// the given date. Sessions without division, created before the league had
+// divisions, are shared by every division.
+func getMatchSessionByStartDate(
+	tx *sqlx.Tx,
+	leagueID, divisionID util.UUIDAsBlob,
+	startDate time.Time,
+) (MatchSession, error) {
 	var ret MatchSession
-	query := `SELECT * FROM MatchSession WHERE MatchSession.LeagueID = ? AND MatchSession.StartDate = ? LIMIT 1`
-	if err := tx.Get(&ret, query, leagueID, util.TimeAsDateTimeTZ(startDate)); err != nil {
+	query := `
+        SELECT * FROM MatchSession
+        WHERE MatchSession.LeagueID = ?
+          AND (MatchSession.DivisionID IS ? OR MatchSession.DivisionID IS NULL)
+          AND MatchSession.StartDate = ?
+        LIMIT 1`
+	if err := tx.Get(
+		&ret, query,
+		leagueID, util.NewNullUUIDAsBlob(divisionID), util.TimeAsDateTimeTZ(startDate),
+	); err != nil {
 		return MatchSession{}, err
 	}
 
@@ -89,12 +123,12 @@ func getMatchSessionByID(tx *sqlx.Tx, id util.UUIDAsBlob) (MatchSession, error)
 
 // getPlayerActiveSession returns the MatchSession the player is currently
 // _running_. If a session is still in progress but the player has completed
-// his race in it, it won't be considered as active.
+// his race in it, it won't be considered as active. Waitlisted players hold
+// no spot and are not running any race, see getPlayerWaitlistSession.
 func getPlayerActiveSession(tx *sqlx.Tx, playerID util.UUIDAsBlob) (MatchSession, error) {
 	query := `
         SELECT * FROM MatchSession
-        WHERE MatchSession.Status IN(?, ?, ?) AND
-            PlayerIDs LIKE ?
+        WHERE MatchSession.Status IN(?, ?, ?) AND PlayerIDs LIKE ?
         ORDER BY MatchSession.StartDate ASC`
 
 	var sessions []MatchSession
@@ -132,6 +166,37 @@ func getPlayerActiveSession(tx *sqlx.Tx, playerID util.UUIDAsBlob) (MatchSession
 	return MatchSession{}, sql.ErrNoRows
 }
 
+// getPlayerWaitlistSession returns the first MatchSession the player is
+// waiting a spot in. Waitlists are emptied once the races are prepared.
+func getPlayerWaitlistSession(tx *sqlx.Tx, playerID util.UUIDAsBlob) (MatchSession, error) {
+	var ret MatchSession
+	query := `
+        SELECT * FROM MatchSession
+        WHERE MatchSession.Status = ? AND WaitlistPlayerIDs LIKE ?
+        ORDER BY MatchSession.StartDate ASC
+        LIMIT 1`
+	if err := tx.Get(
+		&ret, query,
+		MatchSessionStatusJoinable,
+		`%"`+playerID.String()+`"%`,
+	); err != nil {
+		return MatchSession{}, err
+	}
+
+	return ret, nil
+}
+
+// getPlayerCurrentSession returns the active session of the player, or the
+// session they are waiting a spot in if they are not racing.
+func getPlayerCurrentSession(tx *sqlx.Tx, playerID util.UUIDAsBlob) (MatchSession, error) {
+	session, err := getPlayerActiveSession(tx, playerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return getPlayerWaitlistSession(tx, playerID)
+	}
+
+	return session, err
+}
+
 func getNextMatchSessionForLeague(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (MatchSession, error) {
 	var ret MatchSession
 	query := `
@@ -154,11 +219,18 @@ func getNextMatchSessionForLeague(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (MatchS
 	return ret, nil
 }
 
-func getNextJoinableMatchSessionForLeague(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (MatchSession, error) {
+// getNextJoinableMatchSessionForLeague returns the next session that can be
+// joined in the given league division, divisionID is zero for leagues without
+// divisions. Sessions without division can be joined from any division.
+func getNextJoinableMatchSessionForLeague(
+	tx *sqlx.Tx,
+	leagueID, divisionID util.UUIDAsBlob,
+) (MatchSession, error) {
 	var ret MatchSession
 	query := `
         SELECT * FROM MatchSession
         WHERE MatchSession.LeagueID = ? AND
+              (MatchSession.DivisionID IS ? OR MatchSession.DivisionID IS NULL) AND
               DATETIME(MatchSession.StartDate) > DATETIME(?) AND
               MatchSession.Status = ?
         ORDER BY MatchSession.StartDate ASC
@@ -166,7 +238,7 @@ func getNextJoinableMatchSessionForLeague(tx *sqlx.Tx, leagueID util.UUIDAsBlob)
 
 	if err := tx.Get(
 		&ret, query,
-		leagueID,
+		leagueID, util.NewNullUUIDAsBlob(divisionID),
 		util.TimeAsDateTimeTZ(time.Now()),
 		MatchSessionStatusJoinable,
 	); err != nil {
@@ -178,12 +250,15 @@ func getNextJoinableMatchSessionForLeague(tx *sqlx.Tx, leagueID util.UUIDAsBlob)
 
 func (s *MatchSession) insert(tx *sqlx.Tx) error {
 	query, args, err := squirrel.Insert("MatchSession").SetMap(squirrel.Eq{
-		"ID":        s.ID,
-		"CreatedAt": s.CreatedAt,
-		"LeagueID":  s.LeagueID,
-		"StartDate": s.StartDate,
-		"Status":    s.Status,
-		"PlayerIDs": s.PlayerIDs,
+		"ID":         s.ID,
+		"CreatedAt":  s.CreatedAt,
+		"LeagueID":   s.LeagueID,
+		"DivisionID": s.DivisionID,
+		"StartDate":  s.StartDate,
+		"Status":     s.Status,
+		"PlayerIDs":  s.PlayerIDs,
+
+		"WaitlistPlayerIDs": s.WaitlistPlayerIDs,
 	}).ToSql()
 	if err != nil {
 		return err
@@ -210,17 +285,37 @@ loop:
 	}
 }
 
+// RemovePlayerID removes a player from the session, or from its waitlist.
 func (s *MatchSession) RemovePlayerID(toRemove uuid.UUID) {
-	filtered := make([]uuid.UUID, 0, len(s.PlayerIDs)-1)
-	for k := range s.PlayerIDs {
-		if s.PlayerIDs[k] == toRemove {
-			continue
+	remove := func(ids util.UUIDArrayAsJSON) util.UUIDArrayAsJSON {
+		filtered := make([]uuid.UUID, 0, len(ids))
+		for k := range ids {
+			if ids[k] == toRemove {
+				continue
+			}
+
+			filtered = append(filtered, ids[k])
 		}
 
-		filtered = append(filtered, s.PlayerIDs[k])
+		return filtered
 	}
 
-	s.PlayerIDs = filtered
+	s.PlayerIDs = remove(s.PlayerIDs)
+	s.WaitlistPlayerIDs = remove(s.WaitlistPlayerIDs)
+}
+
+// popWaitlist removes the first player of the waitlist and adds them to the
+// session, it returns false if the waitlist was empty.
+func (s *MatchSession) popWaitlist() (uuid.UUID, bool) {
+	if len(s.WaitlistPlayerIDs) == 0 {
+		return uuid.UUID{}, false
+	}
+
+	id := s.WaitlistPlayerIDs[0]
+	s.WaitlistPlayerIDs = s.WaitlistPlayerIDs[1:]
+	s.AddPlayerID(id)
+
+	return id, true
 }
 
 func (s *MatchSession) CanCancel() error {
@@ -262,6 +357,8 @@ func (s *MatchSession) update(tx *sqlx.Tx) error {
 		"StartDate": s.StartDate,
 		"Status":    s.Status,
 		"PlayerIDs": s.PlayerIDs,
+
+		"WaitlistPlayerIDs": s.WaitlistPlayerIDs,
 	}).
 		Where("MatchSession.ID = ?", s.ID).
 		ToSql()
@@ -345,8 +442,11 @@ func getActiveSessionsForLeagueID(tx *sqlx.Tx, leagueID util.UUIDAsBlob) ([]Matc
 	return ret, nil
 }
 
+// MapMatchSessions calls cb for each session of the league that started
+// during the given season, use the zero Season for all sessions.
 func (b *Back) MapMatchSessions(
 	shortcode string,
+	season Season,
 	cb func(MatchSession) error,
 ) error {
 	return b.transaction(func(tx *sqlx.Tx) error {
@@ -355,7 +455,12 @@ func (b *Back) MapMatchSessions(
 			return err
 		}
 
-		rows, err := tx.Queryx(`SELECT * FROM MatchSession WHERE LeagueID = ?`, league.ID)
+		from, to := season.bounds()
+		rows, err := tx.Queryx(`
+            SELECT * FROM MatchSession WHERE LeagueID = ?
+            AND DATETIME(StartDate) >= DATETIME(?) AND DATETIME(StartDate) < DATETIME(?)`,
+			league.ID, util.TimeAsDateTimeTZ(from.Time()), util.TimeAsDateTimeTZ(to.Time()),
+		)
 		if err != nil {
 			return err
 		}
@@ -7,11 +7,13 @@ import (
 	"io"
 	"kaepora/internal/generator"
 	"kaepora/internal/generator/oot"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"log"
 	"text/tabwriter"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -34,6 +36,13 @@ const (
 	NotificationTypeMatchSessionRecap
 	NotificationTypeSpoilerLog
 	NotificationTypeLeagueLeaderboardUpdate
+	NotificationTypeDivisionChange
+	NotificationTypeMatchSessionWaitlist
+	NotificationTypeSeasonEnd
+	NotificationTypeRatingChange
+	NotificationTypeLeaderboardMovers
+	NotificationTypeMatchSessionChange
+	NotificationTypePoll
 )
 
 type NotificationFile struct {
@@ -93,6 +102,20 @@ func NotificationTypeName(typ NotificationType) string {
 		return "MatchEnd"
 	case NotificationTypeSpoilerLog:
 		return "SpoilerLog"
+	case NotificationTypeDivisionChange:
+		return "DivisionChange"
+	case NotificationTypeMatchSessionWaitlist:
+		return "MatchSessionWaitlist"
+	case NotificationTypeSeasonEnd:
+		return "SeasonEnd"
+	case NotificationTypeRatingChange:
+		return "RatingChange"
+	case NotificationTypeLeaderboardMovers:
+		return "LeaderboardMovers"
+	case NotificationTypeMatchSessionChange:
+		return "MatchSessionChange"
+	case NotificationTypePoll:
+		return "Poll"
 	default:
 		return "invalid"
 	}
@@ -147,6 +170,42 @@ func (b *Back) sendOddKickNotification(player Player) {
 	b.notifications <- notif
 }
 
+// sendWaitlistNotification tells a waitlisted player whether they got a spot
+// in the race or if the race starts without them.
+func (b *Back) sendWaitlistNotification(
+	tx *sqlx.Tx,
+	session MatchSession,
+	player Player,
+	gotSpot bool,
+) error {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordUser,
+		Recipient:     player.DiscordID.String,
+		Type:          NotificationTypeMatchSessionWaitlist,
+	}
+
+	if gotSpot {
+		notif.Printf(
+			"Good news %s, a spot freed up and you are now registered for the next race in league %s.\n"+
+				"The race starts at %s, if you can no longer make it please `!cancel`.\n",
+			player.Name, sessionLeagueName(tx, league, session), util.Datetime(session.StartDate),
+		)
+	} else {
+		notif.Printf(
+			"Sorry %s, no spot freed up for the race in league %s, you have been removed from the waitlist.\n",
+			player.Name, sessionLeagueName(tx, league, session),
+		)
+	}
+
+	b.notifications <- notif
+	return nil
+}
+
 func (b *Back) sendMatchSessionEmptyNotification(tx *sqlx.Tx, session MatchSession) error {
 	league, err := getLeagueByID(tx, session.LeagueID)
 	if err != nil {
@@ -160,15 +219,92 @@ func (b *Back) sendMatchSessionEmptyNotification(tx *sqlx.Tx, session MatchSessi
 	}
 
 	notif.Printf(
-		"The race for league `%s` is closed, you can no longer join.\n"+
+		"The race for league %s is closed, you can no longer join.\n"+
 			"There was not enough players to start the race.\n",
-		league.ShortCode,
+		sessionLeagueName(tx, league, session),
 	)
 
 	b.notifications <- notif
 	return nil
 }
 
+// sendMatchSessionChangeNotification announces a change made by an admin to
+// a session both in the league channel and to the given players.
+func (b *Back) sendMatchSessionChangeNotification(
+	tx *sqlx.Tx,
+	session MatchSession,
+	playerIDs []uuid.UUID,
+	format string, args ...interface{},
+) error {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	b.publishSessionLiveEvent(LiveEventSessionStatus, league, session)
+
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypeMatchSessionChange,
+	}
+	notif.Printf(format, args...)
+	b.notifications <- notif
+
+	for _, id := range playerIDs {
+		player, err := getPlayerByID(tx, util.UUIDAsBlob(id))
+		if err != nil {
+			return err
+		}
+
+		notif := Notification{
+			RecipientType: NotificationRecipientTypeDiscordUser,
+			Recipient:     player.DiscordID.String,
+			Type:          NotificationTypeMatchSessionChange,
+		}
+		notif.Printf(format, args...)
+		b.notifications <- notif
+	}
+
+	return nil
+}
+
+// sendMatchSessionRosterNotification tells a player an admin added them to
+// or removed them from a race.
+func (b *Back) sendMatchSessionRosterNotification(
+	tx *sqlx.Tx,
+	session MatchSession,
+	player Player,
+	added bool,
+) error {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordUser,
+		Recipient:     player.DiscordID.String,
+		Type:          NotificationTypeMatchSessionChange,
+	}
+
+	if added {
+		notif.Printf(
+			"%s, an admin registered you for the race in league %s.\n"+
+				"The race starts at %s, if you can no longer make it please `!cancel`.\n",
+			player.Name, sessionLeagueName(tx, league, session), util.Datetime(session.StartDate),
+		)
+	} else {
+		notif.Printf(
+			"%s, an admin removed you from the race in league %s starting at %s.\n",
+			player.Name, sessionLeagueName(tx, league, session), util.Datetime(session.StartDate),
+		)
+	}
+
+	b.notifications <- notif
+	return nil
+}
+
 func (b *Back) sendMatchEndNotification(
 	tx *sqlx.Tx,
 	selfEntry MatchEntry,
@@ -234,13 +370,14 @@ func (b *Back) sendMatchSeedNotification(
 	url string,
 	out generator.Output,
 	p1, p2 Player,
+	f1, f2 rating.Forecast,
 ) {
 	name := fmt.Sprintf(
 		"seed_%s.zpf",
 		session.StartDate.Time().Format("2006-01-02_15h04"),
 	)
 
-	send := func(player Player) {
+	send := func(player, opponent Player, forecast rating.Forecast) {
 		notif := Notification{
 			RecipientType: NotificationRecipientTypeDiscordUser,
 			Recipient:     player.DiscordID.String,
@@ -266,13 +403,18 @@ func (b *Back) sendMatchSeedNotification(
 			notif.Print("Your seed hash is: **", hash, "**\n")
 		}
 
-		if !session.StartDate.Time().IsZero() {
+		// Seeds regenerated by an admin can arrive after the start.
+		if start := session.StartDate.Time(); !start.IsZero() && time.Until(start) > 0 {
 			notif.Printf(
 				"Your race starts in %s, **do not explore the seed before the match starts**.\n",
 				time.Until(session.StartDate.Time()).Round(time.Second),
 			)
 		}
 
+		if !opponent.ID.IsZero() { // dev seeds have no opponent
+			writeForecast(&notif, opponent, forecast)
+		}
+
 		if err := maybeWriteSettingsPatchInfo(&notif, out.State); err != nil {
 			log.Printf("warning: unable to send settings info: %v", err)
 		}
@@ -280,8 +422,16 @@ func (b *Back) sendMatchSeedNotification(
 		b.notifications <- notif
 	}
 
-	send(p1)
-	send(p2)
+	send(p1, p2, f1)
+	send(p2, p1, f2)
+}
+
+// writeForecast is an helper for sendMatchSeedNotification.
+func writeForecast(w io.Writer, opponent Player, forecast rating.Forecast) {
+	fmt.Fprintf(
+		w, "Your estimated chance of beating %s is %.0f %% (%+.0f rating on a win, %+.0f on a loss).\n",
+		opponent.Name, 100*forecast.WinProbability, forecast.WinDelta, forecast.LossDelta,
+	)
 }
 
 // maybeWriteSettingsPatchInfo sends OOTR-specific documentation about the
@@ -333,42 +483,45 @@ func (b *Back) sendSessionStatusUpdateNotification(tx *sqlx.Tx, session MatchSes
 		Type:          NotificationTypeMatchSessionStatusUpdate,
 	}
 
+	b.publishSessionLiveEvent(LiveEventSessionStatus, league, session)
+
+	name := sessionLeagueName(tx, league, session)
 	switch session.Status {
 	case MatchSessionStatusWaiting:
 		notif.Printf(
-			"The next race for league `%s` has been scheduled for %s (in %s)",
-			league.ShortCode,
+			"The next race for league %s has been scheduled for %s (in %s)",
+			name,
 			util.Datetime(session.StartDate),
 			time.Until(session.StartDate.Time()).Round(time.Second),
 		)
 	case MatchSessionStatusJoinable:
 		notif.Printf(
-			"The race for league `%s` can now be joined! The race starts at %s (in %s).\n"+
+			"The race for league %s can now be joined! The race starts at %s (in %s).\n"+
 				"You can join using `!join %s`.",
-			league.ShortCode,
+			name,
 			util.Datetime(session.StartDate),
 			time.Until(session.StartDate.Time()).Round(time.Second),
 			league.ShortCode,
 		)
 	case MatchSessionStatusPreparing:
 		notif.Printf(
-			"The race for league `%s` has begun preparations, you can no longer join. "+
+			"The race for league %s has begun preparations, you can no longer join. "+
 				"Seeds will soon be sent to the %d contestants.\n"+
 				"The race starts at %s (in %s). Watch this channel for the official go.",
-			league.ShortCode,
+			name,
 			len(session.PlayerIDs)-(len(session.PlayerIDs)%2),
 			util.Datetime(session.StartDate),
 			time.Until(session.StartDate.Time()).Round(time.Second),
 		)
 	case MatchSessionStatusInProgress:
 		notif.Printf(
-			"The race for league `%s` **starts now**. Good luck and have fun!",
-			league.ShortCode,
+			"The race for league %s **starts now**. Good luck and have fun!",
+			name,
 		)
 	case MatchSessionStatusClosed:
 		notif.Printf(
-			"All players have finished their last `%s` race, rankings have been updated.",
-			league.ShortCode,
+			"All players have finished their last %s race, rankings have been updated.",
+			name,
 		)
 	}
 
@@ -376,6 +529,22 @@ func (b *Back) sendSessionStatusUpdateNotification(tx *sqlx.Tx, session MatchSes
 	return nil
 }
 
+// sessionLeagueName formats the league of a session for announcements,
+// including the division name for leagues with divisions.
+func sessionLeagueName(tx *sqlx.Tx, league League, session MatchSession) string {
+	if !session.DivisionID.Valid {
+		return "`" + league.ShortCode + "`"
+	}
+
+	division, err := getDivisionByID(tx, session.DivisionID.UUID)
+	if err != nil {
+		log.Printf("warning: unable to fetch division %s: %s", session.DivisionID.UUID, err)
+		return "`" + league.ShortCode + "`"
+	}
+
+	return fmt.Sprintf("`%s` (%s)", league.ShortCode, division.Name)
+}
+
 func (b *Back) sendSessionCountdownNotification(tx *sqlx.Tx, session MatchSession) error {
 	league, err := getLeagueByID(tx, session.LeagueID)
 	if err != nil {
@@ -388,9 +557,11 @@ func (b *Back) sendSessionCountdownNotification(tx *sqlx.Tx, session MatchSessio
 		Type:          NotificationTypeMatchSessionStatusUpdate,
 	}
 
+	b.publishSessionLiveEvent(LiveEventCountdown, league, session)
+
 	notif.Printf(
-		"The next race for league `%s` starts in %s.",
-		league.ShortCode,
+		"The next race for league %s starts in %s.",
+		sessionLeagueName(tx, league, session),
 		time.Until(session.StartDate.Time()).Round(time.Second),
 	)
 
@@ -413,7 +584,7 @@ func (b *Back) sendLeaderboardUpdateNotification(
 		Type:          NotificationTypeLeagueLeaderboardUpdate,
 	}
 
-	top, err := getTop20(tx, league.ID, DeviationThreshold)
+	top, err := getTop20(tx, league)
 	if err != nil {
 		return err
 	}
@@ -422,13 +593,158 @@ func (b *Back) sendLeaderboardUpdateNotification(
 		return nil
 	}
 
-	notif.Printf("Top players for league `%s`:\n```\n", league.ShortCode)
+	notif.Printf("Top players for league `%s`:\n", league.ShortCode)
+	WriteTop20(&notif, top)
+
+	b.notifications <- notif
+	return nil
+}
+
+// leaderboardMoversCount is the maximum number of players listed in the
+// biggest movers announcement.
+const leaderboardMoversCount = 5
+
+// sendLeaderboardMoversNotification announces the players who climbed the
+// leaderboard of a league the most during the rating period that started at
+// the given date and the ones who entered it.
+func (b *Back) sendLeaderboardMoversNotification(
+	tx *sqlx.Tx,
+	league League,
+	calendar ratingCalendar,
+	periodStart time.Time,
+) error {
+	_, movers, newcomers, err := getLeaderboardMoves(tx, league, calendar, periodStart)
+	if err != nil {
+		return err
+	}
+	if len(movers) == 0 && len(newcomers) == 0 {
+		return nil
+	}
+
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypeLeaderboardMovers,
+	}
+
+	notif.Printf(
+		"Biggest movers of league `%s` since %s:\n",
+		league.ShortCode, periodStart.Format("2006-01-02"),
+	)
+	if len(movers) > 0 {
+		notif.Print("```\n")
+		for _, v := range movers {
+			notif.Printf(
+				" %-20s #%-3d → #%-3d (%+.0f)\n",
+				v.PlayerName, v.PreviousRank, v.Rank, v.RatingDelta,
+			)
+		}
+		notif.Print("```\n")
+	}
+
+	for k, v := range newcomers {
+		if k == 0 {
+			notif.Print("New on the leaderboard: ")
+		} else {
+			notif.Print(", ")
+		}
+		notif.Printf("%s (#%d)", v.PlayerName, v.Rank)
+	}
+
+	b.notifications <- notif
+	return nil
+}
+
+// WriteTop20 writes the top players of a league, as returned by getTop20, as
+// a code block per division.
+func WriteTop20(w io.Writer, top []LeaderboardEntry) {
+	if len(top) == 0 {
+		return
+	}
+
 	for i := range top {
-		notif.Printf(" %2.d. %s\n", i+1, top[i].PlayerName)
+		if i == 0 || top[i].DivisionName != top[i-1].DivisionName {
+			if i > 0 {
+				fmt.Fprint(w, "```\n")
+			}
+			if top[i].DivisionName != "" {
+				fmt.Fprintf(w, "**%s**\n", top[i].DivisionName)
+			}
+			fmt.Fprint(w, "```\n")
+		}
+
+		fmt.Fprintf(w, " %2.d. %s\n", top[i].DivisionRank, top[i].PlayerName)
+	}
+	fmt.Fprint(w, "```\n")
+}
+
+func (b *Back) sendDivisionChangesNotifications(league League, changes []DivisionChange) {
+	announce := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypeDivisionChange,
+	}
+	announce.Printf("Division changes for league `%s`:\n", league.ShortCode)
+
+	for _, change := range changes {
+		notif := Notification{
+			RecipientType: NotificationRecipientTypeDiscordUser,
+			Recipient:     change.Player.DiscordID.String,
+			Type:          NotificationTypeDivisionChange,
+		}
+
+		if change.IsPromotion() {
+			notif.Printf(
+				"Congratulations %s, you have been promoted to the %s division of league `%s`!\n",
+				change.Player.Name, change.To.Name, league.ShortCode,
+			)
+			announce.Printf(" - %s is promoted to %s\n", change.Player.Name, change.To.Name)
+		} else {
+			notif.Printf(
+				"Sorry %s, you have been relegated to the %s division of league `%s`.\n",
+				change.Player.Name, change.To.Name, league.ShortCode,
+			)
+			announce.Printf(" - %s is relegated to %s\n", change.Player.Name, change.To.Name)
+		}
+
+		b.notifications <- notif
+	}
+
+	b.notifications <- announce
+}
+
+// sendSeasonEndNotification announces the podium of each division of a
+// season that just ended.
+func (b *Back) sendSeasonEndNotification(tx *sqlx.Tx, season Season) error {
+	league, err := getLeagueByID(tx, season.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	entries, err := getSeasonLeaderboard(tx, season.ID)
+	if err != nil {
+		return err
+	}
+
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypeSeasonEnd,
+	}
+	notif.Printf("Season **%s** of league `%s` is over!\n", season.Name, league.ShortCode)
+
+	for _, v := range entries {
+		if v.DivisionRank > 3 {
+			continue
+		}
+		if v.DivisionRank == 1 && v.DivisionName != "" {
+			notif.Printf("%s division:\n", v.DivisionName)
+		}
+		notif.Printf(" %d. %s (%.0f)\n", v.DivisionRank, v.PlayerName, v.Rating)
 	}
-	notif.Print("```\n")
 
 	b.notifications <- notif
+
 	return nil
 }
 
@@ -446,6 +762,7 @@ func (b *Back) sendSessionRecapNotification(
 	matches []Match,
 	scope RecapScope, // private recap, don't send to announce channel
 	toDiscordUserID *string, // can be nil for public recaps: will send to announce channel
+	changes map[util.UUIDAsBlob]RatingChange, // nil until the session is ranked
 ) error {
 	league, err := getLeagueByID(tx, session.LeagueID)
 	if err != nil {
@@ -461,8 +778,11 @@ func (b *Back) sendSessionRecapNotification(
 		notif.SetDiscordUserRecipient(*toDiscordUserID)
 	}
 
-	notif.Printf("Results for `%s` race started at %s:\n```\n", league.ShortCode, util.Datetime(session.StartDate))
-	known, unknown := writeResultsTable(tx, &notif, matches, scope)
+	notif.Printf(
+		"Results for %s race started at %s:\n```\n",
+		sessionLeagueName(tx, league, session), util.Datetime(session.StartDate),
+	)
+	known, unknown := writeResultsTable(tx, &notif, matches, scope, changes)
 	notif.Print("```\n")
 
 	if known == 0 {
@@ -486,6 +806,7 @@ func writeResultsTable(
 	w io.Writer,
 	matches []Match,
 	scope RecapScope,
+	changes map[util.UUIDAsBlob]RatingChange,
 ) (known, unknown int) {
 	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(table, "Player 1\t\tvs\tPlayer 2\t")
@@ -505,6 +826,12 @@ func writeResultsTable(
 
 		wrap0, name0, duration0 := entryDetails(tx, match.Entries[0])
 		wrap1, name1, duration1 := entryDetails(tx, match.Entries[1])
+		if change, ok := changes[match.Entries[0].PlayerID]; ok {
+			duration0 += fmt.Sprintf(" (%+.0f)", change.Delta())
+		}
+		if change, ok := changes[match.Entries[1].PlayerID]; ok {
+			duration1 += fmt.Sprintf(" (%+.0f)", change.Delta())
+		}
 		fmt.Fprint(
 			table,
 			wrap0, name0, wrap0, "\t", duration0, "\t\t",
@@ -547,6 +874,41 @@ func entryDetails(tx *sqlx.Tx, entry MatchEntry) (wrap string, name string, dura
 	return
 }
 
+// sendRatingChangeNotifications tells every player of a session how their
+// rating changed once the session was ranked.
+func (b *Back) sendRatingChangeNotifications(
+	tx *sqlx.Tx, leagueID util.UUIDAsBlob, changes map[util.UUIDAsBlob]RatingChange,
+) error {
+	league, err := getLeagueByID(tx, leagueID)
+	if err != nil {
+		return err
+	}
+
+	for playerID, change := range changes {
+		player, err := getPlayerByID(tx, playerID)
+		if err != nil {
+			return err
+		}
+		if !player.DiscordID.Valid {
+			continue
+		}
+
+		notif := Notification{
+			RecipientType: NotificationRecipientTypeDiscordUser,
+			Recipient:     player.DiscordID.String,
+			Type:          NotificationTypeRatingChange,
+		}
+		notif.Printf(
+			"Your rating in league `%s` went from %.0f to %.0f (%+.0f).\n",
+			league.ShortCode, change.Before, change.After, change.Delta(),
+		)
+
+		b.notifications <- notif
+	}
+
+	return nil
+}
+
 func (b *Back) sendSpoilerLogNotification(player Player, seed string, spoilerLog util.ZLIBBlob) {
 	notif := Notification{
 		RecipientType: NotificationRecipientTypeDiscordUser,
@@ -567,3 +929,57 @@ func (b *Back) sendSpoilerLogNotification(player Player, seed string, spoilerLog
 
 	b.notifications <- notif
 }
+
+// sendPollOpenNotification invites the players of a league to vote on the
+// date of its extra race.
+func (b *Back) sendPollOpenNotification(league League, poll Poll) {
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypePoll,
+	}
+
+	notif.Printf(
+		"When should the next extra race of league `%s` happen? "+
+			"The first date to get %d votes wins:\n",
+		league.ShortCode, poll.Quorum,
+	)
+	for k, v := range poll.Slots {
+		notif.Printf("%d. %s\n", k+1, util.Datetime(v.StartDate))
+	}
+	notif.Printf("Vote using `!poll %s N` or on the website.", league.ShortCode)
+
+	b.notifications <- notif
+}
+
+// sendPollResultNotification announces the race chosen by a closed poll, or
+// that the poll expired without a date reaching the quorum.
+func (b *Back) sendPollResultNotification(league League, poll Poll) {
+	notif := Notification{
+		RecipientType: NotificationRecipientTypeDiscordChannel,
+		Recipient:     league.AnnounceDiscordChannelID.String,
+		Type:          NotificationTypePoll,
+	}
+
+	slot, ok := poll.ChosenSlot()
+	if !ok {
+		notif.Printf(
+			"The poll for an extra race of league `%s` is closed, no date reached the %d votes needed.",
+			league.ShortCode, poll.Quorum,
+		)
+		b.notifications <- notif
+		return
+	}
+
+	notif.Printf(
+		"The poll for an extra race of league `%[1]s` is closed, %[2]d players voted for %[3]s (in %[4]s).\n"+
+			"The race can be joined using `!join %[1]s` starting %[5]s before it begins.",
+		league.ShortCode,
+		len(slot.VoterIDs),
+		util.Datetime(slot.StartDate),
+		time.Until(slot.StartDate.Time()).Round(time.Second),
+		util.FormatDuration(-MatchSessionJoinableAfterOffset),
+	)
+
+	b.notifications <- notif
+}
@@ -0,0 +1,157 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Overlay is the state of a Match meant to be shown on a stream, it contains
+// no spoiler: no seed, no spoiler log and no player comment.
+type Overlay struct {
+	League  League
+	Session MatchSession
+	Match   Match // Entries are indexed like Runners
+	Runners [2]OverlayRunner
+}
+
+type OverlayRunner struct {
+	Player Player
+	Rating PlayerRating
+	Entry  MatchEntry
+}
+
+// HasMatch returns false if the overlay was requested for a player who never
+// raced.
+func (o Overlay) HasMatch() bool {
+	return !o.Match.ID.IsZero()
+}
+
+// HasEnded returns true once both runners ended their race.
+func (o Overlay) HasEnded() bool {
+	return o.HasMatch() && o.Match.HasEnded()
+}
+
+// TimerEnd returns when the race timer stops: at the first finish once the
+// Match has ended or at the end of the Match if nobody finished. It returns
+// the zero time while the Match is running.
+func (o Overlay) TimerEnd() time.Time {
+	if !o.HasEnded() {
+		return time.Time{}
+	}
+
+	var ret time.Time
+	for _, v := range o.Match.Entries {
+		if v.Status != MatchEntryStatusFinished {
+			continue
+		}
+		if end := v.EndedAt.Time.Time(); ret.IsZero() || end.Before(ret) {
+			ret = end
+		}
+	}
+	if ret.IsZero() {
+		return o.Match.EndedAt.Time.Time()
+	}
+
+	return ret
+}
+
+// GetOverlayForMatch returns the Overlay of a single Match.
+func (b *Back) GetOverlayForMatch(id util.UUIDAsBlob) (ret Overlay, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		match, err := getMatchByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		ret, err = getOverlay(tx, match, util.UUIDAsBlob{})
+		return err
+	})
+}
+
+// GetOverlayForPlayer returns the Overlay of the latest Match of a player,
+// the player is always the first runner. If the player never raced, only
+// the player of the first runner is set.
+func (b *Back) GetOverlayForPlayer(name string) (ret Overlay, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		player, err := getPlayerByName(tx, name)
+		if err != nil {
+			return err
+		}
+
+		var match Match
+		if err := tx.Get(&match, `
+            SELECT Match.* FROM Match
+            INNER JOIN MatchEntry ON (MatchEntry.MatchID = Match.ID)
+            WHERE MatchEntry.PlayerID = ?
+            ORDER BY Match.CreatedAt DESC
+            LIMIT 1`,
+			player.ID,
+		); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ret.Runners[0].Player = player
+				return nil
+			}
+			return fmt.Errorf("could not fetch match: %w", err)
+		}
+
+		if err := injectEntries(tx, &match); err != nil {
+			return err
+		}
+
+		ret, err = getOverlay(tx, match, player.ID)
+		return err
+	})
+}
+
+// getOverlay returns the Overlay of a Match, if set, the given player is
+// the first runner.
+func getOverlay(tx *sqlx.Tx, match Match, firstPlayerID util.UUIDAsBlob) (Overlay, error) {
+	if len(match.Entries) != 2 {
+		return Overlay{}, fmt.Errorf("invalid Match %s: not exactly 2 MatchEntry", match.ID)
+	}
+	if match.Entries[1].PlayerID == firstPlayerID {
+		match.Entries[0], match.Entries[1] = match.Entries[1], match.Entries[0]
+	}
+
+	league, err := getLeagueByID(tx, match.LeagueID)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	session, err := getMatchSessionByID(tx, match.MatchSessionID)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	ret := Overlay{League: league, Session: session}
+	for k := range match.Entries {
+		match.Entries[k].Comment = ""
+		entry := match.Entries[k]
+
+		player, err := getPlayerByID(tx, entry.PlayerID)
+		if err != nil {
+			return Overlay{}, err
+		}
+
+		rating, err := getPlayerRating(tx, entry.PlayerID, match.LeagueID)
+		if err != nil {
+			return Overlay{}, err
+		}
+
+		ret.Runners[k] = OverlayRunner{Player: player, Rating: rating, Entry: entry}
+	}
+
+	// The seed is only available after the race through the spoiler log.
+	match.Seed = ""
+	match.SpoilerLog = nil
+	match.GeneratorState = nil
+	match.SeedPatch = nil
+	ret.Match = match
+
+	return ret, nil
+}
@@ -0,0 +1,37 @@
+package back // nolint:testpackage
+
+import "testing"
+
+func TestOverlay(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+
+	overlay, err := back.GetOverlayForPlayer("Zelda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !overlay.HasEnded() || overlay.Runners[0].Player.Name != "Zelda" {
+		t.Fatalf("expected the ended latest match of Zelda first, got %#v", overlay.Runners)
+	}
+	if overlay.Match.Seed != "" || overlay.Runners[0].Entry.Comment != "" || overlay.Runners[1].Entry.Comment != "" {
+		t.Error("overlay leaked a spoiler")
+	}
+	if overlay.TimerEnd() != overlay.Runners[1].Entry.EndedAt.Time.Time() {
+		t.Errorf("expected the timer to stop on the opponent finish, got %s", overlay.TimerEnd())
+	}
+
+	byMatch, err := back.GetOverlayForMatch(overlay.Match.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byMatch.Match.ID != overlay.Match.ID || byMatch.Runners[0].Rating.Rating == 0 {
+		t.Errorf("expected the same rated match, got %#v", byMatch.Runners)
+	}
+
+	overlay, err = back.GetOverlayForPlayer("Impa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overlay.HasMatch() || overlay.Runners[0].Player.Name != "Impa" {
+		t.Errorf("expected an empty overlay for a player who never raced, got %#v", overlay)
+	}
+}
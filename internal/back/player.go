@@ -7,7 +7,6 @@ import (
 
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
-	glicko "github.com/zelenin/go-glicko2"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -29,7 +28,7 @@ func (a byRating) Len() int {
 }
 
 func (a byRating) Less(i, j int) bool {
-	return a[i].Rating.GlickoRating().R() < a[j].Rating.GlickoRating().R()
+	return a[i].Rating.Rating < a[j].Rating.Rating
 }
 
 func (a byRating) Swap(i, j int) {
@@ -44,10 +43,6 @@ func NewPlayer(name string) Player {
 	}
 }
 
-func (p *Player) GlickoRating() *glicko.Rating {
-	return p.Rating.GlickoRating()
-}
-
 func (p *Player) insert(tx *sqlx.Tx) error {
 	query, args, err := squirrel.Insert("Player").SetMap(squirrel.Eq{
 		"ID":        p.ID,
@@ -115,23 +110,39 @@ func (b *Back) UpdateDiscordPlayerName(discordID string, name string) error {
 			return err
 		}
 
-		if player.Name == name {
-			return util.ErrPublic("that's your name already")
-		}
-
-		if len(name) < 3 || len(name) > 32 {
-			return util.ErrPublic("your name must be between 3 and 32 characters")
-		}
+		return updatePlayerName(tx, player, name)
+	})
+}
 
-		if _, err := getPlayerByName(tx, name); err == nil {
-			return util.ErrPublic("this name is taken already")
+// UpdatePlayerName renames a player, same as UpdateDiscordPlayerName.
+func (b *Back) UpdatePlayerName(playerID util.UUIDAsBlob, name string) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		player, err := getPlayerByID(tx, playerID)
+		if err != nil {
+			return err
 		}
 
-		player.Name = name
-		return player.Update(tx)
+		return updatePlayerName(tx, player, name)
 	})
 }
 
+func updatePlayerName(tx *sqlx.Tx, player Player, name string) error {
+	if player.Name == name {
+		return util.ErrPublic("that's your name already")
+	}
+
+	if len(name) < 3 || len(name) > 32 {
+		return util.ErrPublic("your name must be between 3 and 32 characters")
+	}
+
+	if _, err := getPlayerByName(tx, name); err == nil {
+		return util.ErrPublic("this name is taken already")
+	}
+
+	player.Name = name
+	return player.Update(tx)
+}
+
 func (b *Back) RegisterDiscordPlayer(discordID, name string) error {
 	return b.transaction(func(tx *sqlx.Tx) error {
 		if _, err := getPlayerByDiscordID(tx, discordID); err == nil {
@@ -149,13 +160,34 @@ func (b *Back) RegisterDiscordPlayer(discordID, name string) error {
 }
 
 func (b *Back) UpdateDiscordPlayerStreamURL(discordID, streamURL string) (string, error) {
-	normalizedURL, err := util.NormalizeStreamURL(streamURL)
-	if err != nil {
-		return "", err
+	return b.updatePlayerStreamURL(streamURL, func(tx *sqlx.Tx) (Player, error) {
+		return getPlayerByDiscordID(tx, discordID)
+	})
+}
+
+// UpdatePlayerStreamURL sets the stream URL of a player, same as
+// UpdateDiscordPlayerStreamURL.
+func (b *Back) UpdatePlayerStreamURL(playerID util.UUIDAsBlob, streamURL string) (string, error) {
+	return b.updatePlayerStreamURL(streamURL, func(tx *sqlx.Tx) (Player, error) {
+		return getPlayerByID(tx, playerID)
+	})
+}
+
+func (b *Back) updatePlayerStreamURL(
+	streamURL string,
+	getPlayer func(tx *sqlx.Tx) (Player, error),
+) (string, error) {
+	// An empty URL removes the stream.
+	var normalizedURL string
+	if streamURL != "" {
+		var err error
+		if normalizedURL, err = util.NormalizeStreamURL(streamURL); err != nil {
+			return "", err
+		}
 	}
 
 	if err := b.transaction(func(tx *sqlx.Tx) error {
-		player, err := getPlayerByDiscordID(tx, discordID)
+		player, err := getPlayer(tx)
 		if err != nil {
 			return err
 		}
@@ -171,6 +203,20 @@ func (b *Back) UpdateDiscordPlayerStreamURL(discordID, streamURL string) (string
 	return normalizedURL, nil
 }
 
+func (b *Back) GetPlayerByID(id util.UUIDAsBlob) (player Player, _ error) {
+	return player, b.transaction(func(tx *sqlx.Tx) (err error) {
+		player, err = getPlayerByID(tx, id)
+		return err
+	})
+}
+
+func (b *Back) GetPlayerByName(name string) (player Player, _ error) {
+	return player, b.transaction(func(tx *sqlx.Tx) (err error) {
+		player, err = getPlayerByName(tx, name)
+		return err
+	})
+}
+
 func getPlayersByMatches(tx *sqlx.Tx, matches []Match) (map[util.UUIDAsBlob]Player, error) {
 	ids := make([]util.UUIDAsBlob, 0, len(matches)*2)
 	for k := range matches {
@@ -0,0 +1,213 @@
+package back
+
+import (
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PlayerProfile is the public record of a single player.
+type PlayerProfile struct {
+	Player  Player
+	Leagues []PlayerLeagueProfile // only leagues the player raced in
+	Matches []PlayerMatch         // ended matches, most recent first
+}
+
+// PlayerLeagueProfile is the rating and results of a player in a single league.
+type PlayerLeagueProfile struct {
+	League      League
+	Rating      PlayerRating
+	Provisional bool           // see LeagueLeaderboard.IsProvisional
+	History     []PlayerRating // rating at the end of each period raced, oldest first
+
+	// Forfeits are also counted as losses.
+	Wins, Losses, Draws, Forfeits int
+
+	// Only races the player finished count, zero if none.
+	AverageTime, BestTime time.Duration
+	Finishes              int
+}
+
+// Races returns the number of rated races of the player in the league.
+func (p PlayerLeagueProfile) Races() int {
+	return p.Wins + p.Losses + p.Draws
+}
+
+// PlayerMatch is a Match seen from one of its players.
+type PlayerMatch struct {
+	Match         Match
+	League        League
+	Entry         MatchEntry
+	OpponentEntry MatchEntry
+	Opponent      Player
+}
+
+// GetPlayerProfile returns the public record of a player given their name.
+func (b *Back) GetPlayerProfile(name string) (ret PlayerProfile, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret.Player, err = getPlayerByName(tx, name)
+		if err != nil {
+			return err
+		}
+
+		matches, err := getEndedMatchesByPlayerID(tx, ret.Player.ID)
+		if err != nil {
+			return err
+		}
+
+		leagues := map[util.UUIDAsBlob]int{} // index in ret.Leagues
+		players, err := getPlayersByMatches(tx, matches)
+		if err != nil {
+			return err
+		}
+
+		for k := range matches {
+			entry, opponent, err := matches[k].getPlayerAndOpponentEntries(ret.Player.ID)
+			if err != nil {
+				return err
+			}
+
+			i, ok := leagues[matches[k].LeagueID]
+			if !ok {
+				profile, err := getPlayerLeagueProfile(tx, ret.Player.ID, matches[k].LeagueID)
+				if err != nil {
+					return err
+				}
+
+				i = len(ret.Leagues)
+				leagues[matches[k].LeagueID] = i
+				ret.Leagues = append(ret.Leagues, profile)
+			}
+
+			ret.Leagues[i].addEntry(entry)
+			ret.Matches = append(ret.Matches, PlayerMatch{
+				Match:         matches[k],
+				League:        ret.Leagues[i].League,
+				Entry:         entry,
+				OpponentEntry: opponent,
+				Opponent:      players[opponent.PlayerID],
+			})
+		}
+
+		for k := range ret.Leagues {
+			ret.Leagues[k].finish()
+		}
+
+		return nil
+	})
+}
+
+// getPlayerLeagueProfile returns the rating and history of a player in a
+// league, results are left to addEntry.
+func getPlayerLeagueProfile(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) (PlayerLeagueProfile, error) {
+	var (
+		ret PlayerLeagueProfile
+		err error
+	)
+
+	ret.League, err = getLeagueByID(tx, leagueID)
+	if err != nil {
+		return PlayerLeagueProfile{}, err
+	}
+
+	ret.Rating, err = getPlayerRating(tx, playerID, leagueID)
+	if err != nil {
+		return PlayerLeagueProfile{}, err
+	}
+
+	ret.History, err = getPlayerRatingHistory(tx, playerID, leagueID)
+	if err != nil {
+		return PlayerLeagueProfile{}, err
+	}
+
+	return ret, nil
+}
+
+// addEntry accounts for a single ended MatchEntry of the player.
+func (p *PlayerLeagueProfile) addEntry(entry MatchEntry) {
+	switch entry.Outcome {
+	case MatchEntryOutcomeWin:
+		p.Wins++
+	case MatchEntryOutcomeLoss:
+		p.Losses++
+	case MatchEntryOutcomeDraw:
+		p.Draws++
+	}
+
+	if entry.Status == MatchEntryStatusForfeit {
+		p.Forfeits++
+	}
+
+	if d := entry.Duration(); d > 0 {
+		if p.BestTime == 0 || d < p.BestTime {
+			p.BestTime = d
+		}
+		// Sum for now, averaged in finish.
+		p.AverageTime += d
+		p.Finishes++
+	}
+}
+
+// finish computes what can only be known once every entry has been added.
+func (p *PlayerLeagueProfile) finish() {
+	if p.Finishes > 0 {
+		p.AverageTime = (p.AverageTime / time.Duration(p.Finishes)).Round(time.Second)
+	}
+
+	p.Provisional = p.League.Leaderboard.IsProvisional(p.Rating.Deviation, p.Races())
+}
+
+// getEndedMatchesByPlayerID returns all the ended matches of a player, most
+// recent first.
+func getEndedMatchesByPlayerID(tx *sqlx.Tx, playerID util.UUIDAsBlob) ([]Match, error) {
+	var matches []Match
+	query := `
+        SELECT Match.* FROM Match
+        INNER JOIN MatchEntry ON(MatchEntry.MatchID = Match.ID)
+        WHERE MatchEntry.PlayerID = ? AND Match.EndedAt IS NOT NULL
+        ORDER BY Match.StartedAt DESC`
+	if err := tx.Select(&matches, query, playerID); err != nil {
+		return nil, err
+	}
+
+	for k := range matches {
+		if err := injectEntries(tx, &matches[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+// GetPlayerRatingHistory returns the rating of a player at the end of each
+// period they raced in a league, oldest first.
+func (b *Back) GetPlayerRatingHistory(name, shortcode string) (ret []PlayerRating, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		player, err := getPlayerByName(tx, name)
+		if err != nil {
+			return err
+		}
+
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		ret, err = getPlayerRatingHistory(tx, player.ID, league.ID)
+		return err
+	})
+}
+
+func getPlayerRatingHistory(tx *sqlx.Tx, playerID, leagueID util.UUIDAsBlob) ([]PlayerRating, error) {
+	var ret []PlayerRating
+	query := `
+        SELECT * FROM PlayerRatingHistory
+        WHERE PlayerID = ? AND LeagueID = ?
+        ORDER BY RatingPeriodStartedAt ASC`
+	if err := tx.Select(&ret, query, playerID, leagueID); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
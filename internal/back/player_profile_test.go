@@ -0,0 +1,30 @@
+package back // nolint:testpackage
+
+import "testing"
+
+func TestPlayerProfile(t *testing.T) {
+	back := createRacedTestBack(t, 3) // Zelda forfeits all of her races
+
+	profile, err := back.GetPlayerProfile("Zelda")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(profile.Matches) != 3 {
+		t.Errorf("expected 3 matches, got %d", len(profile.Matches))
+	}
+	if len(profile.Leagues) != 1 {
+		t.Fatalf("expected 1 league, got %d", len(profile.Leagues))
+	}
+
+	league := profile.Leagues[0]
+	if league.Races() != 3 || league.Forfeits != 3 || league.Wins != 0 {
+		t.Errorf("expected 3 forfeits out of 3 races, got %#v", league)
+	}
+	if league.BestTime != 0 || league.AverageTime != 0 {
+		t.Errorf("expected no finish time, got %s/%s", league.BestTime, league.AverageTime)
+	}
+	if len(league.History) == 0 {
+		t.Error("expected a rating history")
+	}
+}
@@ -0,0 +1,209 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// playerRecentRacesCount is the number of races returned by
+// GetPlayerRecentRaces.
+const playerRecentRacesCount = 5
+
+// PlayerRace is a race as seen by one of its players.
+type PlayerRace struct {
+	League  League
+	Session MatchSession
+
+	// Zero until the race starts.
+	Match          Match
+	Self, Opponent MatchEntry
+	OpponentPlayer Player
+}
+
+// HasSession returns false if the player has no active race.
+func (r PlayerRace) HasSession() bool {
+	return !r.Session.ID.IsZero()
+}
+
+// HasMatch returns true once the player has been matched with an opponent.
+func (r PlayerRace) HasMatch() bool {
+	return !r.Match.ID.IsZero()
+}
+
+// WaitlistPosition returns the 1-indexed position of the player on the
+// waitlist of the session, 0 if the player has a spot.
+func (r PlayerRace) WaitlistPosition() int {
+	return r.Session.WaitlistPosition(r.Self.PlayerID.UUID())
+}
+
+// CanCancel returns true if the player can leave the session without
+// penalty.
+func (r PlayerRace) CanCancel() bool {
+	return r.HasSession() && r.Session.CanCancel() == nil
+}
+
+// CanComplete returns true if the player started racing and has yet to
+// complete or forfeit.
+func (r PlayerRace) CanComplete() bool {
+	return r.HasMatch() && r.Self.Status == MatchEntryStatusInProgress
+}
+
+// CanForfeit returns true if the player has a seed and has yet to complete
+// or forfeit.
+func (r PlayerRace) CanForfeit() bool {
+	return r.HasMatch() && !r.Self.HasEnded() && r.Session.CanForfeit() == nil
+}
+
+// HasSpoilerLog returns true if the player can read the spoiler log of the
+// race, that is once they are done racing.
+func (r PlayerRace) HasSpoilerLog() bool {
+	return r.HasMatch() && r.Self.HasEnded()
+}
+
+// GetPlayerRace returns the active race of a player, or the race they are
+// waitlisted for, see getPlayerCurrentSession. The returned PlayerRace has no
+// session if the player is neither racing nor waiting.
+func (b *Back) GetPlayerRace(playerID util.UUIDAsBlob) (ret PlayerRace, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		session, err := getPlayerCurrentSession(tx, playerID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		ret, err = getPlayerRace(tx, playerID, session)
+		return err
+	})
+}
+
+// GetPlayerRecentRaces returns the latest matches of a player, most recent
+// first, including the ones still running.
+func (b *Back) GetPlayerRecentRaces(playerID util.UUIDAsBlob) (ret []PlayerRace, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		var sessionIDs []util.UUIDAsBlob
+		if err := tx.Select(&sessionIDs, `
+            SELECT Match.MatchSessionID FROM Match
+            INNER JOIN MatchEntry ON (MatchEntry.MatchID = Match.ID)
+            INNER JOIN MatchSession ON (MatchSession.ID = Match.MatchSessionID)
+            WHERE MatchEntry.PlayerID = ?
+            ORDER BY DATETIME(MatchSession.StartDate) DESC, Match.CreatedAt DESC
+            LIMIT ?`,
+			playerID, playerRecentRacesCount,
+		); err != nil {
+			return err
+		}
+
+		ret = make([]PlayerRace, 0, len(sessionIDs))
+		for _, id := range sessionIDs {
+			session, err := getMatchSessionByID(tx, id)
+			if err != nil {
+				return err
+			}
+
+			race, err := getPlayerRace(tx, playerID, session)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, race)
+		}
+
+		return nil
+	})
+}
+
+// GetPlayerRaceSeed returns the Match of a player with its seed, the seed is
+// available once the player has been matched.
+func (b *Back) GetPlayerRaceSeed(playerID, matchID util.UUIDAsBlob) (ret Match, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		match, _, err := getPlayerMatch(tx, playerID, matchID)
+		if err != nil {
+			return err
+		}
+
+		if len(match.GeneratorState) == 0 && len(match.SeedPatch) == 0 {
+			return util.ErrPublic("your seed is not ready yet, try again in a minute")
+		}
+
+		match.SpoilerLog = nil
+		ret = match
+		return nil
+	})
+}
+
+// GetPlayerRaceSpoilerLog returns the Match of a player with its spoiler log,
+// the spoiler log is available once the player has completed or forfeited
+// like after "!done" or "!forfeit".
+func (b *Back) GetPlayerRaceSpoilerLog(playerID, matchID util.UUIDAsBlob) (ret Match, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		match, self, err := getPlayerMatch(tx, playerID, matchID)
+		if err != nil {
+			return err
+		}
+
+		if !self.HasEnded() {
+			return util.ErrPublic("you can't see the spoiler log before ending your race")
+		}
+
+		ret = match
+		return nil
+	})
+}
+
+// getPlayerMatch returns a Match the given player is part of and their entry.
+func getPlayerMatch(tx *sqlx.Tx, playerID, matchID util.UUIDAsBlob) (Match, MatchEntry, error) {
+	match, err := getMatchByID(tx, matchID)
+	if err != nil {
+		return Match{}, MatchEntry{}, err
+	}
+
+	self, _, err := match.getPlayerAndOpponentEntries(playerID)
+	if err != nil {
+		// Don't tell others whose match this is.
+		return Match{}, MatchEntry{}, fmt.Errorf("%w: %s", sql.ErrNoRows, err)
+	}
+
+	return match, self, nil
+}
+
+// getPlayerRace returns the race of a player in a session, without Match if
+// the player has not been matched yet.
+func getPlayerRace(tx *sqlx.Tx, playerID util.UUIDAsBlob, session MatchSession) (PlayerRace, error) {
+	league, err := getLeagueByID(tx, session.LeagueID)
+	if err != nil {
+		return PlayerRace{}, err
+	}
+
+	ret := PlayerRace{League: league, Session: session}
+	ret.Self.PlayerID = playerID
+
+	match, err := getMatchByPlayerAndSession(tx, playerID, session.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ret, nil
+		}
+		return PlayerRace{}, err
+	}
+
+	ret.Self, ret.Opponent, err = match.getPlayerAndOpponentEntries(playerID)
+	if err != nil {
+		return PlayerRace{}, err
+	}
+
+	ret.OpponentPlayer, err = getPlayerByID(tx, ret.Opponent.PlayerID)
+	if err != nil {
+		return PlayerRace{}, err
+	}
+
+	// Served through GetPlayerRaceSeed and GetPlayerRaceSpoilerLog only.
+	match.SpoilerLog = nil
+	match.SeedPatch = nil
+	ret.Match = match
+
+	return ret, nil
+}
@@ -0,0 +1,80 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"testing"
+)
+
+// Impa is kicked and Zelda forfeits before the race starts, see
+// startTestSession.
+func TestPlayerRace(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	if _, err := startTestSession(back); err != nil {
+		t.Fatal(err)
+	}
+
+	race := getTestPlayerRace(t, back, "Darunia")
+	if !race.HasMatch() || race.OpponentPlayer.Name == "" || race.Self.Status != MatchEntryStatusInProgress {
+		t.Fatalf("expected Darunia to be racing, got %#v", race)
+	}
+	if race.Match.SpoilerLog != nil {
+		t.Error("player race leaked the spoiler log")
+	}
+
+	for _, name := range []string{"Zelda", "Impa"} {
+		if race := getTestPlayerRace(t, back, name); race.HasSession() {
+			t.Errorf("expected %s to have no race, got %#v", name, race.Session)
+		}
+	}
+}
+
+func TestPlayerRaceSeed(t *testing.T) {
+	back := createRacedTestBack(t, 1)
+	if _, err := startTestSession(back); err != nil {
+		t.Fatal(err)
+	}
+
+	race := getTestPlayerRace(t, back, "Darunia")
+	if _, err := back.GetPlayerRaceSpoilerLog(race.Self.PlayerID, race.Match.ID); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when getting the spoiler log while racing, got %v", err)
+	}
+	if _, err := back.GetPlayerRaceSeed(race.Self.PlayerID, race.Match.ID); err != nil {
+		t.Error(err)
+	}
+
+	// Sessions of the test all start in the same second, their order is
+	// undefined.
+	recent, err := back.GetPlayerRecentRaces(race.OpponentPlayer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, v := range recent {
+		found = found || v.Match.ID == race.Match.ID
+		if v.Match.SeedPatch != nil || v.Self.PlayerID != race.Opponent.PlayerID {
+			t.Errorf("unexpected recent race: %s", v.Match.ID)
+		}
+	}
+	if !found {
+		t.Error("expected the current match in the recent races")
+	}
+}
+
+func getTestPlayerRace(t *testing.T, back *Back, name string) PlayerRace {
+	t.Helper()
+
+	player, err := back.GetPlayerByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	race, err := back.GetPlayerRace(player.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return race
+}
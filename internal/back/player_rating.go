@@ -2,18 +2,14 @@ package back
 
 import (
 	"database/sql"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
-	glicko "github.com/zelenin/go-glicko2"
 )
 
-// TODO seems like an OK cutoff right now, but will need to be change
-// later I've seen a RD of 50 being the average for active players.
-const DeviationThreshold = 220
-
 type PlayerRating struct {
 	PlayerID  util.UUIDAsBlob
 	LeagueID  util.UUIDAsBlob
@@ -22,32 +18,42 @@ type PlayerRating struct {
 	// Used only on PlayerRatingHistory table
 	RatingPeriodStartedAt util.TimeAsTimestamp
 
-	// Glicko-2
+	// See rating.Rating, Rating and Deviation have the same meaning in all
+	// rating systems.
 	Rating     float64
 	Deviation  float64
 	Volatility float64
+	State      []byte
 }
 
-func (r PlayerRating) GlickoRating() *glicko.Rating {
-	return glicko.NewRating(r.Rating, r.Deviation, r.Volatility)
+func (r PlayerRating) SystemRating() rating.Rating {
+	return rating.Rating{
+		Rating:     r.Rating,
+		Deviation:  r.Deviation,
+		Volatility: r.Volatility,
+		State:      r.State,
+	}
 }
 
-func (r *PlayerRating) SetRating(g *glicko.Rating) {
-	r.Rating = g.R()
-	r.Deviation = g.Rd()
-	r.Volatility = g.Sigma()
+func (r *PlayerRating) SetRating(v rating.Rating) {
+	r.Rating = v.Rating
+	r.Deviation = v.Deviation
+	r.Volatility = v.Volatility
+	r.State = v.State
 }
 
+// NewPlayerRating returns the default rating of a player, ratings are on the
+// same scale in every system so this is valid whatever the system of the
+// league.
 func NewPlayerRating(playerID, leagueID util.UUIDAsBlob) PlayerRating {
-	return PlayerRating{
+	ret := PlayerRating{
 		PlayerID:  playerID,
 		LeagueID:  leagueID,
 		CreatedAt: util.TimeAsTimestamp(time.Now()),
-
-		Rating:     glicko.RATING_BASE_R,
-		Deviation:  glicko.RATING_BASE_RD,
-		Volatility: glicko.RATING_BASE_SIGMA,
 	}
+	ret.SetRating(rating.NewGlicko2().New())
+
+	return ret
 }
 
 // getPlayerRating get the current rating for a player in a league or creates
@@ -68,29 +74,6 @@ func getPlayerRating(
 	return ret, nil
 }
 
-// getGlickoRatingsForLeague returns players indexed by Player ID.
-func getGlickoPlayersForLeague(
-	tx *sqlx.Tx,
-	leagueID util.UUIDAsBlob,
-	periodStart util.TimeAsTimestamp,
-) (map[util.UUIDAsBlob]*glicko.Player, error) {
-	query := `
-        SELECT * FROM PlayerRatingHistory
-        WHERE LeagueID = ? AND RatingPeriodStartedAt = ?`
-
-	var ratings []PlayerRating
-	if err := tx.Select(&ratings, query, leagueID, periodStart); err != nil {
-		return nil, err
-	}
-
-	ret := make(map[util.UUIDAsBlob]*glicko.Player, len(ratings))
-	for k := range ratings {
-		ret[ratings[k].PlayerID] = glicko.NewPlayer(ratings[k].GlickoRating())
-	}
-
-	return ret, nil
-}
-
 func (r PlayerRating) upsert(tx *sqlx.Tx) error {
 	query, args, err := squirrel.Insert("PlayerRating").SetMap(squirrel.Eq{
 		"PlayerID":   r.PlayerID,
@@ -99,6 +82,7 @@ func (r PlayerRating) upsert(tx *sqlx.Tx) error {
 		"Rating":     r.Rating,
 		"Deviation":  r.Deviation,
 		"Volatility": r.Volatility,
+		"State":      r.State,
 	}).ToSql()
 	if err != nil {
 		return err
@@ -107,7 +91,8 @@ func (r PlayerRating) upsert(tx *sqlx.Tx) error {
 	query += ` ON CONFLICT(PlayerID, LeagueID) DO UPDATE SET
         Rating=excluded.Rating,
         Deviation=excluded.Deviation,
-        Volatility=excluded.Volatility
+        Volatility=excluded.Volatility,
+        State=excluded.State
     `
 
 	if _, err := tx.Exec(query, args...); err != nil {
@@ -126,6 +111,7 @@ func (r PlayerRating) upsertHistory(tx *sqlx.Tx, ratingPeriodStartedAt util.Time
 		"Rating":                r.Rating,
 		"Deviation":             r.Deviation,
 		"Volatility":            r.Volatility,
+		"State":                 r.State,
 	}).ToSql()
 	if err != nil {
 		return err
@@ -136,7 +122,8 @@ func (r PlayerRating) upsertHistory(tx *sqlx.Tx, ratingPeriodStartedAt util.Time
         DO UPDATE SET
             Rating=excluded.Rating,
             Deviation=excluded.Deviation,
-            Volatility=excluded.Volatility
+            Volatility=excluded.Volatility,
+            State=excluded.State
     `
 
 	if _, err := tx.Exec(query, args...); err != nil {
@@ -145,3 +132,80 @@ func (r PlayerRating) upsertHistory(tx *sqlx.Tx, ratingPeriodStartedAt util.Time
 
 	return nil
 }
+
+// getLatestRatings returns the last rating of each player of a league in the
+// rating periods starting in the [from, to) range.
+func getLatestRatings(
+	tx *sqlx.Tx,
+	leagueID util.UUIDAsBlob,
+	from, to util.TimeAsTimestamp,
+) ([]PlayerRating, error) {
+	query := `
+        SELECT PlayerRatingHistory.* FROM PlayerRatingHistory
+        INNER JOIN (
+            SELECT PlayerID, MAX(RatingPeriodStartedAt) AS RatingPeriodStartedAt
+            FROM PlayerRatingHistory
+            WHERE LeagueID = ? AND RatingPeriodStartedAt >= ? AND RatingPeriodStartedAt < ?
+            GROUP BY PlayerID
+        ) Latest ON(
+            Latest.PlayerID = PlayerRatingHistory.PlayerID
+            AND Latest.RatingPeriodStartedAt = PlayerRatingHistory.RatingPeriodStartedAt
+        )
+        WHERE PlayerRatingHistory.LeagueID = ?`
+
+	var ret []PlayerRating
+	if err := tx.Select(&ret, query, leagueID, from, to, leagueID); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// getPlayerRatingsByPlayerID returns the current ratings of a league indexed
+// by player ID.
+func getPlayerRatingsByPlayerID(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (map[util.UUIDAsBlob]PlayerRating, error) {
+	var ratings []PlayerRating
+	if err := tx.Select(&ratings, `SELECT * FROM PlayerRating WHERE LeagueID = ?`, leagueID); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[util.UUIDAsBlob]PlayerRating, len(ratings))
+	for _, v := range ratings {
+		ret[v.PlayerID] = v
+	}
+
+	return ret, nil
+}
+
+// RatingChange is the rating of a player before and after a ranking update.
+type RatingChange struct {
+	Before, After float64
+}
+
+func (c RatingChange) Delta() float64 {
+	return c.After - c.Before
+}
+
+// getRatingChanges compares two snapshots of the ratings of a league for the
+// given players, players absent from before had the default rating.
+func getRatingChanges(
+	playerIDs []util.UUIDAsBlob,
+	before, after map[util.UUIDAsBlob]PlayerRating,
+) map[util.UUIDAsBlob]RatingChange {
+	ret := make(map[util.UUIDAsBlob]RatingChange, len(playerIDs))
+	for _, id := range playerIDs {
+		next, ok := after[id]
+		if !ok {
+			continue
+		}
+
+		prev, ok := before[id]
+		if !ok {
+			prev = NewPlayerRating(id, next.LeagueID)
+		}
+
+		ret[id] = RatingChange{Before: prev.Rating, After: next.Rating}
+	}
+
+	return ret
+}
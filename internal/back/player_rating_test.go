@@ -0,0 +1,44 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"reflect"
+	"testing"
+)
+
+func TestGetRatingChanges(t *testing.T) {
+	id := func(b byte) util.UUIDAsBlob {
+		var ret util.UUIDAsBlob
+		ret[0] = b
+		return ret
+	}
+	newRating := func(playerID util.UUIDAsBlob, v float64) PlayerRating {
+		ret := NewPlayerRating(playerID, id(100))
+		ret.Rating = v
+		return ret
+	}
+
+	before := map[util.UUIDAsBlob]PlayerRating{
+		id(1): newRating(id(1), 1600),
+		id(3): newRating(id(3), 1700), // did not race
+		id(4): newRating(id(4), 1800), // not rated anymore
+	}
+	after := map[util.UUIDAsBlob]PlayerRating{
+		id(1): newRating(id(1), 1650),
+		id(2): newRating(id(2), 1480), // first race
+		id(3): newRating(id(3), 1690),
+	}
+
+	expected := map[util.UUIDAsBlob]RatingChange{
+		id(1): {Before: 1600, After: 1650},
+		id(2): {Before: rating.BaseRating, After: 1480},
+	}
+	actual := getRatingChanges([]util.UUIDAsBlob{id(1), id(2), id(4)}, before, after)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("changes do not match\nexpected: %#v\nactual  : %#v", expected, actual)
+	}
+	if actual[id(2)].Delta() != 1480-rating.BaseRating {
+		t.Errorf("unexpected delta: %.0f", actual[id(2)].Delta())
+	}
+}
@@ -0,0 +1,49 @@
+package back // nolint:testpackage
+
+import (
+	"errors"
+	"kaepora/internal/util"
+	"testing"
+)
+
+func TestPlayerSettings(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	player, err := back.GetPlayerByName("Impa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := player.ID
+
+	if err := back.UpdatePlayerName(id, "Saria"); !errors.Is(err, util.ErrPublic("")) {
+		t.Errorf("expected a public error when taking a name, got %v", err)
+	}
+	if err := back.UpdatePlayerName(id, "Impa2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := back.UpdatePlayerStreamURL(id, "www.twitch.tv/impa"); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := back.GetPlayerByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Impa2" || updated.StreamURL != "https://twitch.tv/impa" {
+		t.Errorf("unexpected player after update: %#v", updated)
+	}
+
+	if _, err := back.UpdatePlayerStreamURL(id, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.UpdatePlayerName(id, "Impa"); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err = back.GetPlayerByID(id); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Impa" || updated.StreamURL != "" {
+		t.Errorf("unexpected player after reverting: %#v", updated)
+	}
+}
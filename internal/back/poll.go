@@ -0,0 +1,212 @@
+package back
+
+import (
+	"kaepora/internal/util"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// A Poll lets the players of a League vote on the date of an extra race.
+// Players can vote for as many slots as they want, the first slot reaching
+// the Quorum closes the poll and gets its MatchSession created.
+// A league has at most one open poll at a time.
+type Poll struct {
+	ID        util.UUIDAsBlob
+	LeagueID  util.UUIDAsBlob
+	CreatedAt util.TimeAsTimestamp
+	ClosedAt  util.NullTimeAsTimestamp
+	Quorum    int
+
+	Slots []PollSlot `db:"-"` // sorted by start date
+}
+
+// A PollSlot is a candidate date for the race of a Poll.
+type PollSlot struct {
+	ID        util.UUIDAsBlob
+	PollID    util.UUIDAsBlob
+	StartDate util.TimeAsDateTimeTZ
+	VoterIDs  util.UUIDArrayAsJSON
+	Chosen    bool
+}
+
+func NewPoll(leagueID util.UUIDAsBlob, quorum int, starts []time.Time) Poll {
+	ret := Poll{
+		ID:        util.NewUUIDAsBlob(),
+		LeagueID:  leagueID,
+		CreatedAt: util.TimeAsTimestamp(time.Now()),
+		Quorum:    quorum,
+		Slots:     make([]PollSlot, 0, len(starts)),
+	}
+
+	for _, v := range starts {
+		ret.Slots = append(ret.Slots, PollSlot{
+			ID:        util.NewUUIDAsBlob(),
+			PollID:    ret.ID,
+			StartDate: util.TimeAsDateTimeTZ(v),
+			VoterIDs:  util.UUIDArrayAsJSON{},
+		})
+	}
+
+	return ret
+}
+
+// ChosenSlot returns the slot that reached the quorum, if any.
+func (p *Poll) ChosenSlot() (PollSlot, bool) {
+	for _, v := range p.Slots {
+		if v.Chosen {
+			return v, true
+		}
+	}
+
+	return PollSlot{}, false
+}
+
+// HasVoter returns true if the given player voted for the slot.
+func (s *PollSlot) HasVoter(needle uuid.UUID) bool {
+	for _, v := range s.VoterIDs {
+		if v == needle {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsVotable returns false if the slot is too close for its race to be
+// joined by the players once created.
+func (s *PollSlot) IsVotable() bool {
+	return time.Until(s.StartDate.Time()) >= adminMatchSessionMinNotice
+}
+
+func (s *PollSlot) setVote(playerID uuid.UUID, vote bool) {
+	kept := make(util.UUIDArrayAsJSON, 0, len(s.VoterIDs)+1)
+	for _, v := range s.VoterIDs {
+		if v != playerID {
+			kept = append(kept, v)
+		}
+	}
+
+	if vote {
+		kept = append(kept, playerID)
+	}
+
+	s.VoterIDs = kept
+}
+
+func (p *Poll) insert(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Insert("Poll").SetMap(squirrel.Eq{
+		"ID":        p.ID,
+		"LeagueID":  p.LeagueID,
+		"CreatedAt": p.CreatedAt,
+		"ClosedAt":  p.ClosedAt,
+		"Quorum":    p.Quorum,
+	}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, v := range p.Slots {
+		query, args, err := squirrel.Insert("PollSlot").SetMap(squirrel.Eq{
+			"ID":        v.ID,
+			"PollID":    v.PollID,
+			"StartDate": v.StartDate,
+			"VoterIDs":  v.VoterIDs,
+			"Chosen":    v.Chosen,
+		}).ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// update saves the poll and the votes and choice of its slots.
+func (p *Poll) update(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Update("Poll").SetMap(squirrel.Eq{
+		"ClosedAt": p.ClosedAt,
+		"Quorum":   p.Quorum,
+	}).Where("Poll.ID = ?", p.ID).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, v := range p.Slots {
+		query, args, err := squirrel.Update("PollSlot").SetMap(squirrel.Eq{
+			"VoterIDs": v.VoterIDs,
+			"Chosen":   v.Chosen,
+		}).Where("PollSlot.ID = ?", v.ID).ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Poll) loadSlots(tx *sqlx.Tx) error {
+	p.Slots = nil
+	query := `SELECT * FROM PollSlot WHERE PollSlot.PollID = ? ORDER BY DATETIME(StartDate) ASC`
+	return tx.Select(&p.Slots, query, p.ID)
+}
+
+func getPollByID(tx *sqlx.Tx, id util.UUIDAsBlob) (Poll, error) {
+	var ret Poll
+	query := `SELECT * FROM Poll WHERE Poll.ID = ? LIMIT 1`
+	if err := tx.Get(&ret, query, id); err != nil {
+		return Poll{}, err
+	}
+	if err := ret.loadSlots(tx); err != nil {
+		return Poll{}, err
+	}
+
+	return ret, nil
+}
+
+// getLeagueOpenPoll returns the open poll of a league or sql.ErrNoRows.
+func getLeagueOpenPoll(tx *sqlx.Tx, leagueID util.UUIDAsBlob) (Poll, error) {
+	var ret Poll
+	query := `SELECT * FROM Poll WHERE Poll.LeagueID = ? AND Poll.ClosedAt IS NULL LIMIT 1`
+	if err := tx.Get(&ret, query, leagueID); err != nil {
+		return Poll{}, err
+	}
+	if err := ret.loadSlots(tx); err != nil {
+		return Poll{}, err
+	}
+
+	return ret, nil
+}
+
+func getOpenPolls(tx *sqlx.Tx) ([]Poll, error) {
+	var ret []Poll
+	if err := tx.Select(&ret, `SELECT * FROM Poll WHERE Poll.ClosedAt IS NULL ORDER BY CreatedAt ASC`); err != nil {
+		return nil, err
+	}
+
+	for k := range ret {
+		if err := ret[k].loadSlots(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,175 @@
+package back
+
+import (
+	"fmt"
+	"kaepora/internal/util"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RatingPeriod is the length of the rating periods of a League, ratings are
+// computed once per period using every match that started during it.
+type RatingPeriod string
+
+const (
+	// Each session with at least one match is its own period, starting with
+	// the first match of the session.
+	RatingPeriodSession RatingPeriod = "session"
+	RatingPeriodDaily   RatingPeriod = "daily"   // 00:00 UTC
+	RatingPeriodWeekly  RatingPeriod = "weekly"  // monday 00:00 UTC
+	RatingPeriodMonthly RatingPeriod = "monthly" // first day of the month 00:00 UTC
+)
+
+// RatingPeriods lists every available period length, default first.
+var RatingPeriods = []RatingPeriod{ // nolint:gochecknoglobals
+	RatingPeriodWeekly, RatingPeriodSession, RatingPeriodDaily, RatingPeriodMonthly,
+}
+
+func (p RatingPeriod) valid() bool {
+	for _, v := range RatingPeriods {
+		if p == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ratingPeriodNames() string {
+	names := make([]string, 0, len(RatingPeriods))
+	for _, v := range RatingPeriods {
+		names = append(names, string(v))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// ratingCalendar splits time in the rating periods of a league.
+type ratingCalendar struct {
+	period RatingPeriod
+
+	// Start of every session period, sorted, RatingPeriodSession only.
+	sessions []time.Time
+}
+
+// getRatingCalendar returns the calendar of a league, session periods are
+// read from the matches already started.
+func getRatingCalendar(tx *sqlx.Tx, league League) (ratingCalendar, error) {
+	ret := ratingCalendar{period: league.RatingPeriod}
+	if ret.period == "" {
+		ret.period = RatingPeriodWeekly
+	}
+	if !ret.period.valid() {
+		return ratingCalendar{}, fmt.Errorf("league %s: unknown rating period: %s", league.ShortCode, ret.period)
+	}
+	if ret.period != RatingPeriodSession {
+		return ret, nil
+	}
+
+	var starts []util.TimeAsTimestamp
+	if err := tx.Select(&starts, `
+        SELECT MIN(StartedAt) AS StartedAt FROM Match
+        WHERE LeagueID = ? AND StartedAt IS NOT NULL
+        GROUP BY MatchSessionID
+        ORDER BY StartedAt ASC`,
+		league.ID,
+	); err != nil {
+		return ratingCalendar{}, fmt.Errorf("unable to fetch session periods: %w", err)
+	}
+
+	ret.sessions = make([]time.Time, 0, len(starts))
+	for _, v := range starts {
+		ret.sessions = append(ret.sessions, v.Time().UTC())
+	}
+
+	return ret, nil
+}
+
+// start returns the start of the period containing the given date.
+func (c ratingCalendar) start(t time.Time) time.Time {
+	t = t.UTC()
+
+	switch c.period {
+	case RatingPeriodSession:
+		// Index of the first session starting after t.
+		i := sort.Search(len(c.sessions), func(i int) bool { return c.sessions[i].After(t) })
+		if i == 0 {
+			return time.Unix(0, 0).UTC()
+		}
+		return c.sessions[i-1]
+	case RatingPeriodDaily:
+		return t.Truncate(24 * time.Hour)
+	case RatingPeriodMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return currentPeriodStart(t)
+	}
+}
+
+// next returns the start of the period following the one containing the
+// given date. The last session period never ends until another one starts.
+func (c ratingCalendar) next(t time.Time) time.Time {
+	t = t.UTC()
+
+	switch c.period {
+	case RatingPeriodSession:
+		i := sort.Search(len(c.sessions), func(i int) bool { return c.sessions[i].After(t) })
+		if i == len(c.sessions) {
+			return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return c.sessions[i]
+	case RatingPeriodDaily:
+		return c.start(t).AddDate(0, 0, 1)
+	case RatingPeriodMonthly:
+		return c.start(t).AddDate(0, 1, 0)
+	default:
+		return nextPeriodStart(t)
+	}
+}
+
+// align moves a date to the start of its period, session periods are not
+// known in advance and dates are kept as is.
+func (c ratingCalendar) align(t time.Time) time.Time {
+	if c.period == RatingPeriodSession {
+		return t
+	}
+
+	return c.start(t)
+}
+
+// count returns the number of periods starting in the (from, to] range.
+func (c ratingCalendar) count(from, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+	from, to = c.start(from), c.start(to)
+
+	switch c.period {
+	case RatingPeriodSession:
+		first := sort.Search(len(c.sessions), func(i int) bool { return c.sessions[i].After(from) })
+		last := sort.Search(len(c.sessions), func(i int) bool { return c.sessions[i].After(to) })
+		return last - first
+	case RatingPeriodDaily:
+		return int(to.Sub(from) / (24 * time.Hour))
+	case RatingPeriodMonthly:
+		return (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	default:
+		return int(to.Sub(from) / (7 * 24 * time.Hour))
+	}
+}
+
+// forEachPeriodSince calls cb with the start of every rating period from the
+// one containing the given date to the current one.
+func (c ratingCalendar) forEachPeriodSince(first time.Time, cb func(time.Time) error) error {
+	end := c.next(time.Now())
+	for i := c.start(first); i.Before(end); i = c.next(i) {
+		if err := cb(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
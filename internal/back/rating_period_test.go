@@ -0,0 +1,137 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRatingCalendar(t *testing.T) {
+	date := func(s string) time.Time {
+		ret, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+
+	sessions := ratingCalendar{
+		period:   RatingPeriodSession,
+		sessions: []time.Time{date("2020-05-01 20:00"), date("2020-05-03 20:00"), date("2020-05-08 20:00")},
+	}
+
+	type entry struct {
+		calendar          ratingCalendar
+		input             string
+		start, next, from string
+		count             int
+	}
+
+	list := []entry{
+		{ratingCalendar{period: RatingPeriodDaily}, "2020-05-15 02:00", "2020-05-15 00:00", "2020-05-16 00:00", "2020-05-12 23:00", 3},
+		{ratingCalendar{period: RatingPeriodWeekly}, "2020-05-15 02:00", "2020-05-11 00:00", "2020-05-18 00:00", "2020-04-27 00:00", 2},
+		{ratingCalendar{period: RatingPeriodMonthly}, "2020-05-15 02:00", "2020-05-01 00:00", "2020-06-01 00:00", "2019-12-31 00:00", 5},
+		{sessions, "2020-05-04 02:00", "2020-05-03 20:00", "2020-05-08 20:00", "2020-04-01 00:00", 2},
+		{sessions, "2020-05-09 02:00", "2020-05-08 20:00", "9999-01-01 00:00", "2020-05-01 20:00", 2},
+		{sessions, "2020-04-09 02:00", "1970-01-01 00:00", "2020-05-01 20:00", "2020-04-01 00:00", 0},
+	}
+
+	for _, v := range list {
+		input := date(v.input)
+		if actual := v.calendar.start(input); !actual.Equal(date(v.start)) {
+			t.Errorf("%s start of %s: expected %s, got %s", v.calendar.period, input, v.start, actual)
+		}
+		if actual := v.calendar.next(input); !actual.Equal(date(v.next)) {
+			t.Errorf("%s next of %s: expected %s, got %s", v.calendar.period, input, v.next, actual)
+		}
+		if actual := v.calendar.count(date(v.from), input); actual != v.count {
+			t.Errorf("%s periods from %s to %s: expected %d, got %d", v.calendar.period, v.from, input, v.count, actual)
+		}
+	}
+}
+
+// Players who do not race must see their deviation grow once per period they
+// skipped, even without any history in the previous period.
+func TestInactiveDeviationGrowth(t *testing.T) {
+	back := createFixturedTestBack(t)
+	current := currentPeriodStart(time.Now())
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+
+		for name, period := range map[string]time.Time{
+			"Darunia": current.AddDate(0, 0, -7),
+			"Nabooru": current.AddDate(0, 0, -28),
+		} {
+			player, err := getPlayerByName(tx, name)
+			if err != nil {
+				return err
+			}
+
+			r := NewPlayerRating(player.ID, league.ID)
+			r.Rating, r.Deviation = 1600, 60
+			if err := r.upsertHistory(tx, util.TimeAsTimestamp(period)); err != nil {
+				return err
+			}
+		}
+
+		calendar, err := getRatingCalendar(tx, league)
+		if err != nil {
+			return err
+		}
+
+		return back.updateLeagueRankings(tx, league, rating.NewGlicko2(), calendar, current)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	system := rating.NewGlicko2()
+	expected := map[string]float64{
+		"Darunia": system.Inflate(rating.Rating{Rating: 1600, Deviation: 60}, 1).Deviation,
+		"Nabooru": system.Inflate(rating.Rating{Rating: 1600, Deviation: 60}, 4).Deviation,
+	}
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+
+		for name, deviation := range expected {
+			player, err := getPlayerByName(tx, name)
+			if err != nil {
+				return err
+			}
+
+			r, err := getPlayerRating(tx, player.ID, league.ID)
+			if err != nil {
+				return err
+			}
+			if r.Rating != 1600 || math.Abs(r.Deviation-deviation) > 0.001 {
+				t.Errorf("%s: expected 1600/%.1f, got %.1f/%.1f", name, deviation, r.Rating, r.Deviation)
+			}
+		}
+
+		history, err := getLatestRatings(
+			tx, league.ID,
+			util.TimeAsTimestamp(current), util.TimeAsTimestamp(current.AddDate(0, 0, 7)),
+		)
+		if err != nil {
+			return err
+		}
+		if len(history) != 0 {
+			t.Errorf("expected no history for players without matches, got %d entries", len(history))
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
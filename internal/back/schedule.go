@@ -4,12 +4,20 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
-	"log"
+	"kaepora/internal/util"
 	"sort"
 	"strings"
 	"time"
 )
 
+// Schedule holds the dates at which a league races.
+//
+// The weekday fields hold weekly "HH:MM Area/City" entries. Rules holds
+// "RRULE HH:MM Area/City" entries for other recurrences, see
+// parseScheduleRule for the supported RRULE subset. Dates holds one-off
+// "YYYY-MM-DD HH:MM Area/City" races, and Blackouts holds "YYYY-MM-DD" days
+// or inclusive "YYYY-MM-DD/YYYY-MM-DD" ranges during which neither the
+// weekly entries nor the rules race. Blackouts do not apply to one-off dates.
 type Schedule struct {
 	Mon []string
 	Tue []string
@@ -18,6 +26,11 @@ type Schedule struct {
 	Fri []string
 	Sat []string
 	Sun []string
+
+	// Omitted when empty to keep the JSON of plain weekly schedules as is.
+	Rules     []string `json:",omitempty"`
+	Dates     []string `json:",omitempty"`
+	Blackouts []string `json:",omitempty"`
 }
 
 func NewSchedule() Schedule {
@@ -42,79 +55,300 @@ func (s *Schedule) SetAll(hours []string) {
 	s.Sun = hours
 }
 
-func (s *Schedule) NextBetween(t time.Time, max time.Time) time.Time {
-	t, max = t.UTC(), max.UTC()
-	if t.After(max) {
-		return time.Time{}
+// NextBetween returns the first scheduled date in the (t, max) range, or a
+// zero time if there is none.
+func (s *Schedule) NextBetween(t time.Time, max time.Time) (time.Time, error) {
+	dates, err := s.between(t, max)
+	if err != nil || len(dates) == 0 {
+		return time.Time{}, err
 	}
 
-	min := max
-	for location, perLoc := range s.prepareTzMap() {
-		local := t.In(location)
-		dow := local.Format("Mon")
+	return dates[0], nil
+}
 
-		for _, nextHourStr := range perLoc[dow] {
-			nextHour, err := time.ParseInLocation("15:04", nextHourStr, location)
-			if err != nil {
-				log.Printf("error: ignored schedule, can't parse time '%s': %v", nextHourStr, err)
-				continue // HACK, silently ignore misconfiguration
+// Add adds an entry to the given list of the schedule, see parseScheduleList.
+func (s *Schedule) Add(list, entry string) error {
+	lists, err := s.parseScheduleList(list)
+	if err != nil {
+		return err
+	}
+
+	entry = strings.Join(strings.Fields(entry), " ")
+	for _, entries := range lists {
+		for _, v := range *entries {
+			if v == entry {
+				return util.ErrPublic(fmt.Sprintf("'%s' is already scheduled on %s", entry, list))
 			}
+		}
 
-			localNext := time.Date(
-				local.Year(), local.Month(), local.Day(),
-				nextHour.Hour(), nextHour.Minute(),
-				0, 0, location,
-			)
+		*entries = append(*entries, entry)
+		sort.Strings(*entries)
+	}
+
+	return s.Validate()
+}
+
+// Remove removes an entry added by Add.
+func (s *Schedule) Remove(list, entry string) error {
+	lists, err := s.parseScheduleList(list)
+	if err != nil {
+		return err
+	}
+
+	entry = strings.Join(strings.Fields(entry), " ")
+	for _, entries := range lists {
+		kept := make([]string, 0, len(*entries))
+		for _, v := range *entries {
+			if v != entry {
+				kept = append(kept, v)
+			}
+		}
+
+		if len(kept) == len(*entries) {
+			return util.ErrPublic(fmt.Sprintf("'%s' is not scheduled on %s", entry, list))
+		}
+		*entries = kept
+	}
+
+	return nil
+}
+
+// parseScheduleList returns the entries of a day given as its three letters
+// English abbreviation, of every day for "all", or the Rules, Dates, or
+// Blackouts for "rule", "date", and "blackout".
+func (s *Schedule) parseScheduleList(list string) ([]*[]string, error) {
+	all := map[string]*[]string{
+		"mon": &s.Mon, "tue": &s.Tue, "wed": &s.Wed, "thu": &s.Thu,
+		"fri": &s.Fri, "sat": &s.Sat, "sun": &s.Sun,
+		"rule": &s.Rules, "date": &s.Dates, "blackout": &s.Blackouts,
+	}
+
+	list = strings.ToLower(list)
+	if list == "all" {
+		return []*[]string{&s.Mon, &s.Tue, &s.Wed, &s.Thu, &s.Fri, &s.Sat, &s.Sun}, nil
+	}
+
+	entries, ok := all[list]
+	if !ok {
+		return nil, util.ErrPublic(fmt.Sprintf(
+			"invalid day '%s', expected Mon to Sun, all, rule, date, or blackout", list,
+		))
+	}
+
+	return []*[]string{entries}, nil
+}
+
+// Validate returns an util.ErrPublic describing the first invalid entry of
+// the schedule, if any.
+func (s *Schedule) Validate() error {
+	_, err := s.parse()
+	return err
+}
+
+// Returns the next scheduled date in a week span.
+func (s *Schedule) Next() (time.Time, error) {
+	return s.NextBetween(time.Now(), time.Now().AddDate(0, 0, 7))
+}
+
+// Between returns every scheduled date in the [from, to) range, in the
+// location they were scheduled in.
+func (s *Schedule) Between(from, to time.Time) ([]time.Time, error) {
+	return s.between(from.Add(-time.Second), to)
+}
+
+// between returns every scheduled date in the (after, before) range sorted
+// by date, a date scheduled twice is only returned once.
+func (s *Schedule) between(after, before time.Time) ([]time.Time, error) {
+	parsed, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if !after.Before(before) {
+		return nil, nil
+	}
+
+	var ret []time.Time
+	for _, slot := range parsed.slots {
+		first, last := civilDate(after.In(slot.location)), civilDate(before.In(slot.location))
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			if !slot.rule.matches(day) || parsed.isBlackedOut(day) {
+				continue
+			}
 
-			if localNext.After(t) && localNext.Before(min) {
-				min = localNext
+			t := time.Date(
+				day.Year(), day.Month(), day.Day(),
+				slot.hour, slot.minute, 0, 0, slot.location,
+			)
+			if t.After(after) && t.Before(before) {
+				ret = append(ret, t)
 			}
 		}
 	}
 
-	if min != max {
-		return min
+	for _, t := range parsed.dates {
+		if t.After(after) && t.Before(before) {
+			ret = append(ret, t)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Before(ret[j])
+	})
+
+	deduped := make([]time.Time, 0, len(ret))
+	for _, v := range ret {
+		if len(deduped) > 0 && deduped[len(deduped)-1].Equal(v) {
+			continue
+		}
+		deduped = append(deduped, v)
 	}
 
-	// Found nothing, roll over to next day at midnight until max is reached
-	next := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, 1)
-	return s.NextBetween(next, max)
+	return deduped, nil
 }
 
-// prepareTzMap parses the per-day schedule into a per-location map.
-func (s *Schedule) prepareTzMap() map[*time.Location]map[string][]string {
-	// tzMap[location][dow] => 15:04
-	tzMap := map[*time.Location]map[string][]string{}
+// parsedSchedule is the validated form of a Schedule.
+type parsedSchedule struct {
+	slots     []scheduleSlot
+	dates     []time.Time
+	blackouts [][2]time.Time // inclusive civil dates, see civilDate
+}
+
+// scheduleSlot is a recurring race at a given local hour.
+type scheduleSlot struct {
+	rule         scheduleRule
+	hour, minute int
+	location     *time.Location
+}
+
+func (p parsedSchedule) isBlackedOut(day time.Time) bool {
+	for _, v := range p.blackouts {
+		if !day.Before(v[0]) && !day.After(v[1]) {
+			return true
+		}
+	}
 
-	for _, wd := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
-		for _, v := range s.hoursForWeekday(wd) {
-			parts := strings.SplitN(v, " ", 2)
-			if len(parts) < 2 {
-				log.Printf("error: ignored schedule, bad format: '%s'", v)
-				continue // HACK, silently ignore misconfiguration
+	return false
+}
+
+// nolint:funlen
+func (s *Schedule) parse() (parsedSchedule, error) {
+	var ret parsedSchedule
+
+	for wd, day := range []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"} {
+		for _, v := range s.hoursForWeekday(day) {
+			parts := strings.Fields(v)
+			if len(parts) != 2 {
+				return parsedSchedule{}, util.ErrPublic(fmt.Sprintf(
+					"invalid schedule entry '%s' on %s, expected 'HH:MM Area/City'", v, day,
+				))
 			}
 
-			location, err := time.LoadLocation(parts[1])
+			slot, err := parseScheduleSlot(parts[0], parts[1])
 			if err != nil {
-				log.Printf("error: ignored schedule, bad location '%s': %v", parts[1], err)
-				continue // HACK, silently ignore misconfiguration
+				return parsedSchedule{}, util.ErrPublic(fmt.Sprintf("%s on %s", err, day))
 			}
 
-			if _, ok := tzMap[location]; !ok {
-				tzMap[location] = map[string][]string{}
+			slot.rule = scheduleRule{
+				freq:     "WEEKLY",
+				interval: 1,
+				byDay:    []ruleWeekday{{weekday: time.Weekday(wd)}},
 			}
+			ret.slots = append(ret.slots, slot)
+		}
+	}
+
+	for _, v := range s.Rules {
+		parts := strings.Fields(v)
+		if len(parts) != 3 {
+			return parsedSchedule{}, util.ErrPublic(fmt.Sprintf(
+				"invalid schedule rule '%s', expected 'RRULE HH:MM Area/City'", v,
+			))
+		}
+
+		rule, err := parseScheduleRule(parts[0])
+		if err != nil {
+			return parsedSchedule{}, util.ErrPublic(fmt.Sprintf("invalid schedule rule '%s': %s", v, err))
+		}
+
+		slot, err := parseScheduleSlot(parts[1], parts[2])
+		if err != nil {
+			return parsedSchedule{}, util.ErrPublic(fmt.Sprintf("%s in rule '%s'", err, v))
+		}
 
-			tzMap[location][wd] = append(tzMap[location][wd], parts[0])
-			sort.Strings(tzMap[location][wd])
+		slot.rule = rule
+		ret.slots = append(ret.slots, slot)
+	}
+
+	for _, v := range s.Dates {
+		parts := strings.Fields(v)
+		if len(parts) != 3 {
+			return parsedSchedule{}, util.ErrPublic(fmt.Sprintf(
+				"invalid schedule date '%s', expected 'YYYY-MM-DD HH:MM Area/City'", v,
+			))
+		}
+
+		t, err := util.ParseDatetime(parts[0], parts[1], parts[2])
+		if err != nil {
+			return parsedSchedule{}, err
+		}
+		ret.dates = append(ret.dates, t)
+	}
+
+	for _, v := range s.Blackouts {
+		from, to, err := parseDateRange(v)
+		if err != nil {
+			return parsedSchedule{}, err
 		}
+		ret.blackouts = append(ret.blackouts, [2]time.Time{from, to})
 	}
 
-	return tzMap
+	return ret, nil
 }
 
-// Returns the next scheduled date in a week span.
-func (s *Schedule) Next() time.Time {
-	return s.NextBetween(time.Now(), time.Now().AddDate(0, 0, 7))
+// parseScheduleSlot parses the "HH:MM" and "Area/City" parts of an entry, the
+// returned error is meant to be wrapped in an util.ErrPublic.
+func parseScheduleSlot(clock, zone string) (scheduleSlot, error) {
+	hour, err := time.Parse("15:04", clock)
+	if err != nil {
+		return scheduleSlot{}, fmt.Errorf("invalid hour '%s', expected HH:MM", clock)
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil || zone == "" || zone == "Local" {
+		return scheduleSlot{}, fmt.Errorf("unknown timezone '%s'", zone)
+	}
+
+	return scheduleSlot{
+		hour:     hour.Hour(),
+		minute:   hour.Minute(),
+		location: location,
+	}, nil
+}
+
+// parseDateRange parses a "YYYY-MM-DD" day or an inclusive
+// "YYYY-MM-DD/YYYY-MM-DD" range of days.
+func parseDateRange(str string) (from, to time.Time, _ error) {
+	parts := strings.SplitN(str, "/", 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+
+	from, err := parseCivilDate(parts[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, util.ErrPublic(fmt.Sprintf(
+			"invalid blackout '%s', expected YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD", str,
+		))
+	}
+
+	to, err = parseCivilDate(parts[1])
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, util.ErrPublic(fmt.Sprintf(
+			"invalid blackout '%s', expected YYYY-MM-DD or YYYY-MM-DD/YYYY-MM-DD", str,
+		))
+	}
+
+	return from, to, nil
 }
 
 func (s *Schedule) hoursForWeekday(day string) []string {
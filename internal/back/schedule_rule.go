@@ -0,0 +1,257 @@
+package back
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleRule is a parsed RRULE, dates are civil dates, see civilDate.
+type scheduleRule struct {
+	freq       string // DAILY, WEEKLY, or MONTHLY
+	interval   int
+	byDay      []ruleWeekday
+	byMonthDay []int
+	start      time.Time // zero if unset
+	until      time.Time // zero if unset
+}
+
+// ruleWeekday is a BYDAY value, nth is the optional ordinal of the weekday
+// in the month (eg. 1 for the first, -1 for the last), or 0 for every one.
+type ruleWeekday struct {
+	nth     int
+	weekday time.Weekday
+}
+
+var ruleWeekdays = map[string]time.Weekday{ // nolint:gochecknoglobals
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseScheduleRule parses the subset of RFC 5545 RRULE we support:
+//
+//	FREQ        DAILY, WEEKLY, or MONTHLY, required.
+//	INTERVAL    every Nth day, week, or month counting from DTSTART.
+//	BYDAY       comma-separated MO to SU, MONTHLY rules can prefix a day with
+//	            its ordinal in the month, eg. 1SA for the first Saturday or
+//	            -1FR for the last Friday.
+//	BYMONTHDAY  comma-separated days of the month, negative values count from
+//	            the end of the month, MONTHLY only.
+//	DTSTART     YYYY-MM-DD, first day of the rule, required with INTERVAL.
+//	UNTIL       YYYY-MM-DD, last day of the rule.
+//
+// eg. "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;DTSTART=2020-05-02" races every other
+// Saturday. The returned error is meant to be wrapped in an util.ErrPublic.
+// nolint:funlen,gocyclo
+func parseScheduleRule(str string) (scheduleRule, error) {
+	ret := scheduleRule{interval: 1}
+	for _, part := range strings.Split(strings.ToUpper(str), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return scheduleRule{}, fmt.Errorf("expected KEY=VALUE, got '%s'", part)
+		}
+
+		var err error
+		switch kv[0] {
+		case "FREQ":
+			ret.freq = kv[1]
+			if ret.freq != "DAILY" && ret.freq != "WEEKLY" && ret.freq != "MONTHLY" {
+				return scheduleRule{}, fmt.Errorf("unsupported FREQ '%s', expected DAILY, WEEKLY, or MONTHLY", kv[1])
+			}
+		case "INTERVAL":
+			ret.interval, err = strconv.Atoi(kv[1])
+			if err != nil || ret.interval < 1 {
+				return scheduleRule{}, fmt.Errorf("invalid INTERVAL '%s'", kv[1])
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(kv[1], ",") {
+				day, err := parseRuleWeekday(v)
+				if err != nil {
+					return scheduleRule{}, err
+				}
+				ret.byDay = append(ret.byDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(kv[1], ",") {
+				day, err := strconv.Atoi(v)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return scheduleRule{}, fmt.Errorf("invalid BYMONTHDAY '%s'", v)
+				}
+				ret.byMonthDay = append(ret.byMonthDay, day)
+			}
+		case "DTSTART":
+			if ret.start, err = parseCivilDate(kv[1]); err != nil {
+				return scheduleRule{}, fmt.Errorf("invalid DTSTART '%s', expected YYYY-MM-DD", kv[1])
+			}
+		case "UNTIL":
+			if ret.until, err = parseCivilDate(kv[1]); err != nil {
+				return scheduleRule{}, fmt.Errorf("invalid UNTIL '%s', expected YYYY-MM-DD", kv[1])
+			}
+		default:
+			return scheduleRule{}, fmt.Errorf("unsupported key '%s'", kv[0])
+		}
+	}
+
+	if err := ret.validate(); err != nil {
+		return scheduleRule{}, err
+	}
+
+	return ret, nil
+}
+
+func (r *scheduleRule) validate() error {
+	if r.freq == "" {
+		return errors.New("missing FREQ")
+	}
+	if r.interval > 1 && r.start.IsZero() {
+		return errors.New("INTERVAL requires a DTSTART")
+	}
+	if !r.start.IsZero() && !r.until.IsZero() && r.until.Before(r.start) {
+		return errors.New("UNTIL is before DTSTART")
+	}
+	if r.freq != "MONTHLY" && len(r.byMonthDay) > 0 {
+		return errors.New("BYMONTHDAY is only supported by MONTHLY rules")
+	}
+
+	for _, v := range r.byDay {
+		if v.nth != 0 && r.freq != "MONTHLY" {
+			return errors.New("BYDAY ordinals are only supported by MONTHLY rules")
+		}
+	}
+
+	// Default to the day of DTSTART like RFC 5545 does.
+	switch {
+	case r.freq == "WEEKLY" && len(r.byDay) == 0:
+		if r.start.IsZero() {
+			return errors.New("WEEKLY rules require a BYDAY or a DTSTART")
+		}
+		r.byDay = []ruleWeekday{{weekday: r.start.Weekday()}}
+	case r.freq == "MONTHLY" && len(r.byDay) == 0 && len(r.byMonthDay) == 0:
+		if r.start.IsZero() {
+			return errors.New("MONTHLY rules require a BYDAY, a BYMONTHDAY, or a DTSTART")
+		}
+		r.byMonthDay = []int{r.start.Day()}
+	}
+
+	return nil
+}
+
+// parseRuleWeekday parses a BYDAY value, eg. "SA", "1SA", or "-1FR".
+func parseRuleWeekday(str string) (ruleWeekday, error) {
+	if len(str) < 2 {
+		return ruleWeekday{}, fmt.Errorf("invalid BYDAY '%s'", str)
+	}
+
+	weekday, ok := ruleWeekdays[str[len(str)-2:]]
+	if !ok {
+		return ruleWeekday{}, fmt.Errorf("invalid BYDAY '%s', expected MO to SU", str)
+	}
+
+	var nth int
+	if prefix := str[:len(str)-2]; prefix != "" {
+		var err error
+		nth, err = strconv.Atoi(prefix)
+		if err != nil || nth == 0 || nth < -5 || nth > 5 {
+			return ruleWeekday{}, fmt.Errorf("invalid BYDAY ordinal '%s', expected 1 to 5 or -1 to -5", str)
+		}
+	}
+
+	return ruleWeekday{nth: nth, weekday: weekday}, nil
+}
+
+// matches returns true if the rule races on the given civil date.
+func (r scheduleRule) matches(day time.Time) bool {
+	if (!r.start.IsZero() && day.Before(r.start)) || (!r.until.IsZero() && day.After(r.until)) {
+		return false
+	}
+
+	switch r.freq {
+	case "DAILY":
+		if r.interval > 1 && daysBetween(r.start, day)%r.interval != 0 {
+			return false
+		}
+		return len(r.byDay) == 0 || r.matchesWeekday(day)
+	case "WEEKLY":
+		if r.interval > 1 && daysBetween(weekStart(r.start), weekStart(day))/7%r.interval != 0 {
+			return false
+		}
+		return r.matchesWeekday(day)
+	case "MONTHLY":
+		months := (day.Year()-r.start.Year())*12 + int(day.Month()-r.start.Month())
+		if r.interval > 1 && months%r.interval != 0 {
+			return false
+		}
+		return r.matchesMonthDay(day)
+	default:
+		return false
+	}
+}
+
+func (r scheduleRule) matchesWeekday(day time.Time) bool {
+	for _, v := range r.byDay {
+		if v.weekday == day.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesMonthDay returns true if the date matches both BYMONTHDAY and BYDAY,
+// when set.
+func (r scheduleRule) matchesMonthDay(day time.Time) bool {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	fromEnd := daysInMonth - day.Day() + 1
+
+	if len(r.byMonthDay) > 0 {
+		found := false
+		for _, v := range r.byMonthDay {
+			if v == day.Day() || -v == fromEnd {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.byDay) > 0 {
+		for _, v := range r.byDay {
+			if v.weekday != day.Weekday() {
+				continue
+			}
+
+			if v.nth == 0 ||
+				(v.nth > 0 && (day.Day()-1)/7+1 == v.nth) ||
+				(v.nth < 0 && (fromEnd-1)/7+1 == -v.nth) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// civilDate returns the date of t in its own location as a UTC midnight, so
+// dates can be compared and counted regardless of DST.
+func civilDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func parseCivilDate(str string) (time.Time, error) {
+	return time.Parse("2006-01-02", str)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// weekStart returns the Monday of the week of a civil date.
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
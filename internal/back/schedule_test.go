@@ -1,7 +1,10 @@
 package back_test
 
 import (
+	"errors"
 	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -78,6 +81,183 @@ func TestScheduleStd(t *testing.T) {
 	})
 }
 
+func TestScheduleBetween(t *testing.T) {
+	s := back.NewSchedule()
+	s.Mon = []string{"05:00 Europe/Paris"}
+	s.Fri = []string{"10:00 UTC", "21:00 America/New_York"}
+
+	from, err := time.Parse(scheduleTestFormat, "2020-04-06 05:00:00+02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScheduleBetween(t, s, from, from.AddDate(0, 0, 7), []string{
+		"2020-04-06 05:00:00+02:00 CEST",
+		"2020-04-10 10:00:00+00:00 UTC",
+		"2020-04-10 21:00:00-04:00 EDT",
+	})
+}
+
+func TestScheduleRules(t *testing.T) {
+	s := back.NewSchedule()
+	s.Rules = []string{
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=SA;DTSTART=2020-04-04 20:00 Europe/Paris",
+		"FREQ=MONTHLY;BYDAY=1SU,-1FR 18:00 UTC",
+		"FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=2020-04-30 12:00 UTC",
+		"freq=daily;interval=10;dtstart=2020-04-01 09:00 UTC",
+	}
+
+	from, err := time.Parse(scheduleTestFormat, "2020-04-01 00:00:00+00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScheduleBetween(t, s, from, from.AddDate(0, 1, 14), []string{
+		"2020-04-01 09:00:00+00:00 UTC",
+		"2020-04-04 20:00:00+02:00 CEST",
+		"2020-04-05 18:00:00+00:00 UTC",
+		"2020-04-11 09:00:00+00:00 UTC",
+		"2020-04-18 20:00:00+02:00 CEST",
+		"2020-04-21 09:00:00+00:00 UTC",
+		"2020-04-24 18:00:00+00:00 UTC",
+		"2020-04-30 12:00:00+00:00 UTC",
+		"2020-05-01 09:00:00+00:00 UTC",
+		"2020-05-02 20:00:00+02:00 CEST",
+		"2020-05-03 18:00:00+00:00 UTC",
+		"2020-05-11 09:00:00+00:00 UTC",
+	})
+}
+
+func TestScheduleDatesAndBlackouts(t *testing.T) {
+	s := back.NewSchedule()
+	s.Sat = []string{"20:00 Europe/Paris", "20:00 Europe/Paris"}
+	s.Rules = []string{"FREQ=MONTHLY;BYDAY=2SA 20:00 Europe/Paris"}
+	s.Dates = []string{"2020-04-13 21:00 Europe/Paris", "2020-04-25 18:00 UTC"}
+	s.Blackouts = []string{"2020-04-11", "2020-04-20/2020-04-26"}
+
+	from, err := time.Parse(scheduleTestFormat, "2020-04-06 00:00:00+02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScheduleBetween(t, s, from, from.AddDate(0, 0, 28), []string{
+		"2020-04-13 21:00:00+02:00 CEST",
+		"2020-04-18 20:00:00+02:00 CEST",
+		"2020-04-25 18:00:00+00:00 UTC",
+		"2020-05-02 20:00:00+02:00 CEST",
+	})
+}
+
+func TestScheduleJSON(t *testing.T) {
+	old := `{"Mon":["05:00 UTC"],"Tue":[],"Wed":[],"Thu":[],"Fri":[],"Sat":[],"Sun":[]}`
+
+	var s back.Schedule
+	if err := s.Scan(old); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Value(); err != nil || string(v.([]byte)) != old {
+		t.Errorf("expected %s, got %s (%v)", old, v, err)
+	}
+
+	s.Blackouts = []string{"2020-04-11"}
+	v, err := s.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual back.Schedule
+	if err := actual.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, actual) {
+		t.Errorf("expected %#v, got %#v", s, actual)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	s := back.NewSchedule()
+	s.Mon = []string{"05:00 Europe/Paris", "21:30 America/New_York"}
+	s.Fri = []string{"10:00 UTC"}
+	if err := s.Validate(); err != nil {
+		t.Errorf("expected a valid schedule, got %s", err)
+	}
+
+	for _, v := range []string{"05:00", "5h Europe/Paris", "25:00 UTC", "05:00 Europe/Nowhere", "05:00 "} {
+		s := back.NewSchedule()
+		s.Wed = []string{v}
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected an error for %q", v)
+		}
+	}
+
+	for _, v := range []back.Schedule{
+		{Rules: []string{"FREQ=YEARLY 20:00 UTC"}},
+		{Rules: []string{"FREQ=WEEKLY 20:00 UTC"}},
+		{Rules: []string{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SA 20:00 UTC"}},
+		{Rules: []string{"FREQ=WEEKLY;BYDAY=1SA 20:00 UTC"}},
+		{Rules: []string{"FREQ=MONTHLY;BYDAY=6SA 20:00 UTC"}},
+		{Rules: []string{"FREQ=MONTHLY;BYMONTHDAY=1;COUNT=3 20:00 UTC"}},
+		{Rules: []string{"FREQ=DAILY;DTSTART=2020-04-02;UNTIL=2020-04-01 20:00 UTC"}},
+		{Rules: []string{"FREQ=DAILY 20:00"}},
+		{Dates: []string{"2020-04-31 20:00 UTC"}},
+		{Dates: []string{"2020-04-01 20:00"}},
+		{Blackouts: []string{"2020-04-01/2020-03-01"}},
+		{Blackouts: []string{"04/01/2020"}},
+	} {
+		if err := v.Validate(); !errors.Is(err, util.ErrPublic("")) {
+			t.Errorf("expected a public error for %#v, got %v", v, err)
+		}
+		if _, err := v.Next(); err == nil {
+			t.Errorf("expected Next to fail for %#v", v)
+		}
+	}
+}
+
+func TestScheduleAddRemove(t *testing.T) {
+	s := back.NewSchedule()
+	if err := s.Add("all", "21:00  Europe/Paris"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("Mon", "05:00 UTC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("sun", "21:00 Europe/Paris"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s.Mon, []string{"05:00 UTC", "21:00 Europe/Paris"}) ||
+		!reflect.DeepEqual(s.Sat, []string{"21:00 Europe/Paris"}) || len(s.Sun) != 0 {
+		t.Errorf("unexpected schedule: %#v", s)
+	}
+
+	if err := s.Add("rule", "FREQ=MONTHLY;BYDAY=1SA  20:00 UTC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("blackout", "2020-12-25"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Rules, []string{"FREQ=MONTHLY;BYDAY=1SA 20:00 UTC"}) ||
+		!reflect.DeepEqual(s.Blackouts, []string{"2020-12-25"}) {
+		t.Errorf("unexpected schedule: %#v", s)
+	}
+
+	for name, err := range map[string]error{
+		"duplicate":      s.Add("Mon", "05:00 UTC"),
+		"invalid day":    s.Add("Lun", "05:00 UTC"),
+		"not found":      s.Remove("Sun", "21:00 Europe/Paris"),
+		"rule not found": s.Remove("rule", "FREQ=DAILY 20:00 UTC"),
+		// Last as invalid entries are kept and invalidate the schedule.
+		"invalid hour": s.Add("Tue", "5h UTC"),
+		"invalid rule": s.Add("rule", "FREQ=HOURLY 20:00 UTC"),
+		"invalid date": s.Add("date", "2020-13-01 20:00 UTC"),
+	} {
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+const scheduleTestFormat = "2006-01-02 15:04:05-07:00"
+
 type scheduleTestData struct {
 	now      string
 	expected string
@@ -86,16 +266,37 @@ type scheduleTestData struct {
 func testSchedule(t *testing.T, s back.Schedule, tests []scheduleTestData) {
 	t.Helper()
 
-	format := "2006-01-02 15:04:05-07:00"
 	for _, v := range tests {
-		now, err := time.Parse(format, v.now)
+		now, err := time.Parse(scheduleTestFormat, v.now)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		actual := s.NextBetween(now, now.AddDate(0, 0, 7)).Format(format)
+		next, err := s.NextBetween(now, now.AddDate(0, 0, 7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := next.Format(scheduleTestFormat)
 		if actual != v.expected {
 			t.Errorf("now: %s,\texpected %s, got %s", now, v.expected, actual)
 		}
 	}
 }
+
+func testScheduleBetween(t *testing.T, s back.Schedule, from, to time.Time, expected []string) {
+	t.Helper()
+
+	dates, err := s.Between(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, v := range dates {
+		actual = append(actual, v.Format(scheduleTestFormat+" MST"))
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
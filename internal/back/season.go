@@ -0,0 +1,458 @@
+package back
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+type SeasonResetMode int
+
+const (
+	SeasonResetNone SeasonResetMode = 0 // ratings carry over
+	SeasonResetHard SeasonResetMode = 1 // everyone starts from scratch
+	SeasonResetSoft SeasonResetMode = 2 // ratings shrink toward the base rating
+)
+
+// A Season is a time range of a League with its own final leaderboard.
+// Seasons start and end on rating period boundaries (except for session
+// periods), the ratings reset happens when computing the first period of the
+// season so that reranking a league yields the same results.
+type Season struct {
+	ID        util.UUIDAsBlob
+	LeagueID  util.UUIDAsBlob
+	CreatedAt util.TimeAsTimestamp
+	Name      string
+	StartDate util.TimeAsTimestamp
+	EndDate   util.TimeAsTimestamp // excluded
+
+	ResetMode          SeasonResetMode
+	ResetFactor        float64 // soft reset: fraction of the distance to the base rating that is kept
+	ResetDeviationBump float64 // soft reset: added to the rating deviation
+
+	ClosedAt util.NullTimeAsTimestamp
+}
+
+// NewSeason creates a season, dates are moved to the start of their rating
+// period when the season is added to its league.
+func NewSeason(leagueID util.UUIDAsBlob, name string, start, end time.Time) Season {
+	return Season{
+		ID:        util.NewUUIDAsBlob(),
+		LeagueID:  leagueID,
+		CreatedAt: util.TimeAsTimestamp(time.Now()),
+		Name:      name,
+		StartDate: util.TimeAsTimestamp(start),
+		EndDate:   util.TimeAsTimestamp(end),
+	}
+}
+
+func (s *Season) IsClosed() bool {
+	return s.ClosedAt.Valid
+}
+
+// IsRunning returns true if the given date is inside the season.
+func (s *Season) IsRunning(t time.Time) bool {
+	return !t.Before(s.StartDate.Time()) && t.Before(s.EndDate.Time())
+}
+
+// bounds returns the [from, to) range covered by the season, the zero Season
+// covers all time.
+func (s *Season) bounds() (util.TimeAsTimestamp, util.TimeAsTimestamp) {
+	if s.ID.IsZero() {
+		return util.TimeAsTimestamp(time.Unix(0, 0)),
+			util.TimeAsTimestamp(time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	return s.StartDate, s.EndDate
+}
+
+func (s *Season) insert(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Insert("Season").SetMap(squirrel.Eq{
+		"ID":                 s.ID,
+		"LeagueID":           s.LeagueID,
+		"CreatedAt":          s.CreatedAt,
+		"Name":               s.Name,
+		"StartDate":          s.StartDate,
+		"EndDate":            s.EndDate,
+		"ResetMode":          s.ResetMode,
+		"ResetFactor":        s.ResetFactor,
+		"ResetDeviationBump": s.ResetDeviationBump,
+		"ClosedAt":           s.ClosedAt,
+	}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Season) update(tx *sqlx.Tx) error {
+	query, args, err := squirrel.Update("Season").SetMap(squirrel.Eq{
+		"Name":               s.Name,
+		"StartDate":          s.StartDate,
+		"EndDate":            s.EndDate,
+		"ResetMode":          s.ResetMode,
+		"ResetFactor":        s.ResetFactor,
+		"ResetDeviationBump": s.ResetDeviationBump,
+		"ClosedAt":           s.ClosedAt,
+	}).Where("Season.ID = ?", s.ID).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getSeasonsByLeagueID returns the seasons of a league, most recent first.
+func getSeasonsByLeagueID(tx *sqlx.Tx, leagueID util.UUIDAsBlob) ([]Season, error) {
+	var ret []Season
+	query := `SELECT * FROM Season WHERE Season.LeagueID = ? ORDER BY Season.StartDate DESC`
+	if err := tx.Select(&ret, query, leagueID); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func getSeasonByID(tx *sqlx.Tx, id util.UUIDAsBlob) (Season, error) {
+	var ret Season
+	query := `SELECT * FROM Season WHERE Season.ID = ? LIMIT 1`
+	if err := tx.Get(&ret, query, id); err != nil {
+		return Season{}, err
+	}
+
+	return ret, nil
+}
+
+// resetRatings applies the season reset to the ratings the season starts
+// with.
+func (s *Season) resetRatings(system rating.System, ratings map[util.UUIDAsBlob]rating.Rating) {
+	for playerID, v := range ratings {
+		ratings[playerID] = s.reset(system, v)
+	}
+}
+
+// reset applies the season reset to a single rating.
+func (s *Season) reset(system rating.System, r rating.Rating) rating.Rating {
+	switch s.ResetMode {
+	case SeasonResetHard:
+		return system.New()
+	case SeasonResetSoft:
+		return system.Shrink(r, s.ResetFactor, s.ResetDeviationBump)
+	default:
+		return r
+	}
+}
+
+// freezeLeaderboard writes the final leaderboard of the season, using the
+// last rating of each player who raced during the season.
+func (s *Season) freezeLeaderboard(tx *sqlx.Tx) error {
+	league, err := getLeagueByID(tx, s.LeagueID)
+	if err != nil {
+		return err
+	}
+
+	ratings, err := getLatestRatings(tx, s.LeagueID, s.StartDate, s.EndDate)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		PlayerID                      util.UUIDAsBlob
+		Wins, Losses, Draws, Forfeits int
+	}
+	var rows []row
+	if err := tx.Select(&rows, `
+        SELECT
+            MatchEntry.PlayerID AS PlayerID,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Wins,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Losses,
+            SUM(CASE WHEN MatchEntry.Outcome = ? THEN 1 ELSE 0 END) AS Draws,
+            SUM(CASE WHEN MatchEntry.Status = ? THEN 1 ELSE 0 END) AS Forfeits
+        FROM MatchEntry
+        INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+        WHERE Match.LeagueID = ? AND Match.StartedAt >= ? AND Match.StartedAt < ?
+          AND MatchEntry.Status IN (?, ?)
+        GROUP BY MatchEntry.PlayerID`,
+		MatchEntryOutcomeWin, MatchEntryOutcomeLoss, MatchEntryOutcomeDraw,
+		MatchEntryStatusForfeit,
+		s.LeagueID, s.StartDate, s.EndDate,
+		MatchEntryStatusFinished, MatchEntryStatusForfeit,
+	); err != nil {
+		return err
+	}
+	counts := make(map[util.UUIDAsBlob]row, len(rows))
+	for _, v := range rows {
+		counts[v.PlayerID] = v
+	}
+
+	type entry struct {
+		rating   PlayerRating
+		division Division
+	}
+	entries := make([]entry, 0, len(ratings))
+	for _, v := range ratings {
+		count := counts[v.PlayerID]
+		if league.Leaderboard.IsProvisional(v.Deviation, count.Wins+count.Losses+count.Draws) {
+			continue
+		}
+
+		division, err := getPlayerDivision(tx, v.PlayerID, s.LeagueID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		entries = append(entries, entry{v, division})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].division.Tier != entries[j].division.Tier {
+			return entries[i].division.Tier < entries[j].division.Tier
+		}
+		return entries[i].rating.Rating > entries[j].rating.Rating
+	})
+
+	rank := 0
+	for k, v := range entries {
+		rank++
+		if k > 0 && entries[k-1].division.ID != v.division.ID {
+			rank = 1
+		}
+
+		count := counts[v.rating.PlayerID]
+		query, args, err := squirrel.Insert("SeasonLeaderboard").SetMap(squirrel.Eq{
+			"SeasonID":     s.ID,
+			"PlayerID":     v.rating.PlayerID,
+			"DivisionName": v.division.Name,
+			"DivisionRank": rank,
+			"Rating":       v.rating.Rating,
+			"Deviation":    v.rating.Deviation,
+			"Wins":         count.Wins,
+			"Losses":       count.Losses,
+			"Draws":        count.Draws,
+			"Forfeits":     count.Forfeits,
+		}).ToSql()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSeasonLeaderboard(tx *sqlx.Tx, seasonID util.UUIDAsBlob) ([]LeaderboardEntry, error) {
+	var ret []LeaderboardEntry
+	query := `
+        SELECT
+            Player.Name AS PlayerName,
+            Player.StreamURL AS PlayerStreamURL,
+            SeasonLeaderboard.Rating AS Rating,
+            SeasonLeaderboard.Deviation AS Deviation,
+            SeasonLeaderboard.DivisionName AS DivisionName,
+            SeasonLeaderboard.DivisionRank AS DivisionRank,
+            SeasonLeaderboard.Wins AS Wins,
+            SeasonLeaderboard.Losses AS Losses,
+            SeasonLeaderboard.Draws AS Draws,
+            SeasonLeaderboard.Forfeits AS Forfeits
+        FROM SeasonLeaderboard
+        INNER JOIN Player ON(Player.ID = SeasonLeaderboard.PlayerID)
+        WHERE SeasonLeaderboard.SeasonID = ?
+        ORDER BY SeasonLeaderboard.rowid ASC`
+	if err := tx.Select(&ret, query, seasonID); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// getSeasonRatings returns the leaderboard ratings of a season: the frozen
+// ones if it is closed, the last rating of each player during the season
+// otherwise. The zero Season returns the current ratings.
+func getSeasonRatings(tx *sqlx.Tx, league League, season Season) ([]PlayerRating, error) {
+	var ret []PlayerRating
+
+	switch {
+	case season.ID.IsZero():
+		if err := tx.Select(
+			&ret,
+			`SELECT * FROM PlayerRating
+            WHERE LeagueID = ? AND Deviation < ? AND `+ratedRacesSubquery+` >= ?`,
+			league.ID, league.Leaderboard.Threshold(), league.Leaderboard.MinRaces,
+		); err != nil {
+			return nil, err
+		}
+	case season.IsClosed():
+		if err := tx.Select(
+			&ret,
+			`SELECT PlayerID, Rating, Deviation FROM SeasonLeaderboard WHERE SeasonID = ?`,
+			season.ID,
+		); err != nil {
+			return nil, err
+		}
+	default:
+		ratings, err := getLatestRatings(tx, league.ID, season.StartDate, season.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ratings {
+			if v.Deviation < league.Leaderboard.Threshold() {
+				ret = append(ret, v)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// closeSeasons freezes the leaderboard of the seasons that ended.
+func (b *Back) closeSeasons() error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		leagues, err := getLeagues(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, league := range leagues {
+			if err := b.closeLeagueSeasons(tx, league.ID, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// closeLeagueSeasons freezes the leaderboard of the seasons of a league that
+// ended at the given date. Divisions are read as they are now, this must run
+// before the divisions are reshuffled at the end of the last rating period
+// of a season.
+func (b *Back) closeLeagueSeasons(tx *sqlx.Tx, leagueID util.UUIDAsBlob, now time.Time) error {
+	var seasons []Season
+	if err := tx.Select(
+		&seasons,
+		`SELECT * FROM Season WHERE LeagueID = ? AND ClosedAt IS NULL AND EndDate <= ?`,
+		leagueID, util.TimeAsTimestamp(now),
+	); err != nil {
+		return err
+	}
+
+	for k := range seasons {
+		log.Printf("info: closing season %s (%s)", seasons[k].Name, seasons[k].ID)
+		if err := seasons[k].freezeLeaderboard(tx); err != nil {
+			return fmt.Errorf("unable to freeze leaderboard: %w", err)
+		}
+
+		seasons[k].ClosedAt = util.NewNullTimeAsTimestamp(time.Now())
+		if err := seasons[k].update(tx); err != nil {
+			return err
+		}
+
+		if err := b.sendSeasonEndNotification(tx, seasons[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddSeason creates a new season for a league, dates are rounded down to the
+// start of their rating period in the league.
+func (b *Back) AddSeason(shortcode string, season Season) error {
+	return b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return util.ErrPublic(fmt.Sprintf("invalid shortcode '%s'", shortcode))
+			}
+			return err
+		}
+
+		calendar, err := getRatingCalendar(tx, league)
+		if err != nil {
+			return err
+		}
+
+		season = NewSeasonFrom(league.ID, season)
+		season.StartDate = util.TimeAsTimestamp(calendar.align(season.StartDate.Time()))
+		season.EndDate = util.TimeAsTimestamp(calendar.align(season.EndDate.Time()))
+		if season.Name == "" {
+			return util.ErrPublic("you need to give the season a name")
+		}
+		if !season.EndDate.Time().After(season.StartDate.Time()) {
+			return util.ErrPublic("a season must last at least one rating period")
+		}
+		if season.ResetFactor < 0 || season.ResetFactor > 1 || season.ResetDeviationBump < 0 {
+			return util.ErrPublic("the reset factor must be between 0 and 1 and the deviation bump positive")
+		}
+
+		seasons, err := getSeasonsByLeagueID(tx, league.ID)
+		if err != nil {
+			return err
+		}
+		for _, v := range seasons {
+			if season.StartDate.Time().Before(v.EndDate.Time()) && v.StartDate.Time().Before(season.EndDate.Time()) {
+				return util.ErrPublic(fmt.Sprintf("the season overlaps with season '%s'", v.Name))
+			}
+		}
+
+		return season.insert(tx)
+	})
+}
+
+// NewSeasonFrom returns a copy of the given season with a new identity in the
+// given league.
+func NewSeasonFrom(leagueID util.UUIDAsBlob, s Season) Season {
+	ret := NewSeason(leagueID, s.Name, s.StartDate.Time(), s.EndDate.Time())
+	ret.ResetMode = s.ResetMode
+	ret.ResetFactor = s.ResetFactor
+	ret.ResetDeviationBump = s.ResetDeviationBump
+
+	return ret
+}
+
+// GetSeasons returns the seasons of a league, most recent first.
+func (b *Back) GetSeasons(shortcode string) (ret []Season, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		ret, err = getSeasonsByLeagueID(tx, league.ID)
+		return err
+	})
+}
+
+func (b *Back) GetSeason(id util.UUIDAsBlob) (ret Season, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret, err = getSeasonByID(tx, id)
+		return err
+	})
+}
+
+// GetSeasonLeaderboard returns the frozen leaderboard of a closed season.
+func (b *Back) GetSeasonLeaderboard(seasonID util.UUIDAsBlob) (ret []LeaderboardEntry, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) (err error) {
+		ret, err = getSeasonLeaderboard(tx, seasonID)
+		return err
+	})
+}
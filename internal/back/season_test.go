@@ -0,0 +1,236 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/rating"
+	"kaepora/internal/util"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestSeasonResetRatings(t *testing.T) {
+	newRatings := func() map[util.UUIDAsBlob]rating.Rating {
+		var id util.UUIDAsBlob
+		id[0] = 1
+		return map[util.UUIDAsBlob]rating.Rating{
+			id: {Rating: 1900, Deviation: 50, Volatility: 0.05},
+		}
+	}
+
+	cases := []struct {
+		season            Season
+		rating, deviation float64
+	}{
+		{Season{ResetMode: SeasonResetNone}, 1900, 50},
+		{Season{ResetMode: SeasonResetHard}, rating.BaseRating, rating.BaseDeviation},
+		{Season{ResetMode: SeasonResetSoft, ResetFactor: 0.5, ResetDeviationBump: 100}, 1700, 150},
+		{Season{ResetMode: SeasonResetSoft, ResetFactor: 1, ResetDeviationBump: 1000}, 1900, rating.BaseDeviation},
+	}
+
+	for k, v := range cases {
+		ratings := newRatings()
+		v.season.resetRatings(rating.NewGlicko2(), ratings)
+		for _, r := range ratings {
+			if math.Abs(r.Rating-v.rating) > 0.001 || math.Abs(r.Deviation-v.deviation) > 0.001 {
+				t.Errorf(
+					"case #%d: expected %.0f/%.0f, got %.0f/%.0f",
+					k, v.rating, v.deviation, r.Rating, r.Deviation,
+				)
+			}
+		}
+	}
+}
+
+// nolint:funlen
+func TestSeasons(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	start := currentPeriodStart(time.Now()).AddDate(0, 0, -28)
+	end := start.AddDate(0, 0, 14)
+
+	if err := back.AddSeason("testa", NewSeason(util.UUIDAsBlob{}, "S1", start, end)); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.AddSeason("testa", NewSeason(util.UUIDAsBlob{}, "S0", start.AddDate(0, 0, -7), end)); err == nil {
+		t.Error("expected an error when adding an overlapping season")
+	}
+	if err := back.AddSeason("testa", NewSeason(util.UUIDAsBlob{}, "S2", end, end)); err == nil {
+		t.Error("expected an error when adding an empty season")
+	}
+
+	// Ratings inside the season, Rauru has too much deviation to be ranked
+	// and Ruto raced after the end of the season.
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+
+		history := []struct {
+			name              string
+			period            time.Time
+			rating, deviation float64
+		}{
+			{"Darunia", start, 1600, 100},
+			{"Darunia", start.AddDate(0, 0, 7), 1400, 90},
+			{"Nabooru", start.AddDate(0, 0, 7), 1700, 80},
+			{"Rauru", start, 1800, 300},
+			{"Ruto", end, 2000, 50},
+		}
+		for _, v := range history {
+			player, err := getPlayerByName(tx, v.name)
+			if err != nil {
+				return err
+			}
+
+			rating := NewPlayerRating(player.ID, league.ID)
+			rating.Rating, rating.Deviation = v.rating, v.deviation
+			if err := rating.upsertHistory(tx, util.TimeAsTimestamp(v.period)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.closeSeasons(); err != nil {
+		t.Fatal(err)
+	}
+
+	seasons, err := back.GetSeasons("testa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seasons) != 1 || !seasons[0].IsClosed() {
+		t.Fatalf("expected a single closed season, got %#v", seasons)
+	}
+
+	leaderboard, err := back.GetSeasonLeaderboard(seasons[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Nabooru", "Darunia"}
+	if len(leaderboard) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(leaderboard))
+	}
+	for k, v := range expected {
+		if leaderboard[k].PlayerName != v || leaderboard[k].DivisionRank != k+1 {
+			t.Errorf("expected %s at rank %d, got %s at rank %d",
+				v, k+1, leaderboard[k].PlayerName, leaderboard[k].DivisionRank)
+		}
+	}
+	if leaderboard[1].Rating != 1400 {
+		t.Errorf("expected the last rating of the season to be used, got %.0f", leaderboard[1].Rating)
+	}
+}
+
+// Seasons are archived with the divisions they ended with, before the
+// players are promoted and relegated at the end of the last rating period.
+// nolint:funlen
+func TestSeasonDivisions(t *testing.T) {
+	back := createFixturedTestBack(t)
+	discardNotifications(back)
+
+	end := currentPeriodStart(time.Now())
+	start := end.AddDate(0, 0, -14)
+	if err := back.AddSeason("testa", NewSeason(util.UUIDAsBlob{}, "S1", start, end)); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.AddDivision("testa", "Gold", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.AddDivision("testa", "Silver", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rauru ends the season above the Gold players and will be promoted.
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+
+		for name, v := range map[string]float64{
+			"Darunia": 1800, "Nabooru": 1700, "Rauru": 1600, "Ruto": 1500,
+		} {
+			player, err := getPlayerByName(tx, name)
+			if err != nil {
+				return err
+			}
+
+			rating := NewPlayerRating(player.ID, league.ID)
+			rating.Rating, rating.Deviation = v, 50
+			if err := rating.upsert(tx); err != nil {
+				return err
+			}
+		}
+		if err := seedDivisions(tx, league.ID); err != nil {
+			return err
+		}
+
+		rauru, err := getPlayerByName(tx, "Rauru")
+		if err != nil {
+			return err
+		}
+		rating := NewPlayerRating(rauru.ID, league.ID)
+		rating.Rating, rating.Deviation = 2000, 50
+		if err := rating.upsert(tx); err != nil {
+			return err
+		}
+		if err := rating.upsertHistory(tx, util.TimeAsTimestamp(start)); err != nil {
+			return err
+		}
+
+		league.RatingPeriodStartedAt = util.NewNullTimeAsTimestamp(end.AddDate(0, 0, -7))
+		return league.update(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := back.closeRatingPeriods(); err != nil {
+		t.Fatal(err)
+	}
+
+	seasons, err := back.GetSeasons("testa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seasons) != 1 || !seasons[0].IsClosed() {
+		t.Fatalf("expected a single closed season, got %#v", seasons)
+	}
+	leaderboard, err := back.GetSeasonLeaderboard(seasons[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaderboard) != 1 || leaderboard[0].PlayerName != "Rauru" ||
+		leaderboard[0].DivisionName != "Silver" || leaderboard[0].DivisionRank != 1 {
+		t.Errorf("expected Rauru first of the Silver division, got %#v", leaderboard)
+	}
+
+	if err := back.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, "testa")
+		if err != nil {
+			return err
+		}
+		rauru, err := getPlayerByName(tx, "Rauru")
+		if err != nil {
+			return err
+		}
+		division, err := getPlayerDivision(tx, rauru.ID, league.ID)
+		if err != nil {
+			return err
+		}
+		if division.Name != "Gold" {
+			t.Errorf("expected Rauru to be promoted once the season is archived, got %s", division.Name)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
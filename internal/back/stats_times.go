@@ -0,0 +1,197 @@
+package back
+
+import (
+	"kaepora/internal/util"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FinishTime is the time of a player who completed a race.
+type FinishTime struct {
+	MatchID    util.UUIDAsBlob
+	PlayerName string
+	StartedAt  util.TimeAsTimestamp
+	EndedAt    util.TimeAsTimestamp
+}
+
+func (t FinishTime) Duration() time.Duration {
+	return t.EndedAt.Time().Sub(t.StartedAt.Time())
+}
+
+// FinishTimes is a list of FinishTime sorted by start date.
+type FinishTimes []FinishTime
+
+// WeeklyMedian is the median finish time of a league for a single week.
+type WeeklyMedian struct {
+	Week   time.Time // monday 00:00 UTC
+	Median time.Duration
+	Count  int
+}
+
+// PersonalBest is the fastest finish time of a player.
+type PersonalBest struct {
+	Best     FinishTime
+	Finishes int
+
+	// Improvement is the time gained between the first finish of the player
+	// and their best.
+	Improvement time.Duration
+}
+
+// Records returns the n fastest finish times, fastest first.
+func (f FinishTimes) Records(n int) []FinishTime {
+	ret := make([]FinishTime, len(f))
+	copy(ret, f)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Duration() < ret[j].Duration()
+	})
+
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+
+	return ret
+}
+
+// WeeklyMedians returns the median finish time of each week with at least
+// one finish, oldest first.
+func (f FinishTimes) WeeklyMedians() []WeeklyMedian {
+	var (
+		ret   []WeeklyMedian
+		times []time.Duration
+	)
+
+	flush := func() {
+		if len(times) > 0 {
+			ret[len(ret)-1].Median = medianDuration(times)
+			ret[len(ret)-1].Count = len(times)
+			times = times[:0]
+		}
+	}
+
+	for _, v := range f {
+		week := currentPeriodStart(v.StartedAt.Time())
+		if len(ret) == 0 || !ret[len(ret)-1].Week.Equal(week) {
+			flush()
+			ret = append(ret, WeeklyMedian{Week: week})
+		}
+
+		times = append(times, v.Duration())
+	}
+	flush()
+
+	return ret
+}
+
+// PersonalBests returns the personal best of every player, fastest first.
+func (f FinishTimes) PersonalBests() []PersonalBest {
+	byName := map[string]int{} // index in ret
+	var ret []PersonalBest
+
+	for _, v := range f {
+		i, ok := byName[v.PlayerName]
+		if !ok {
+			byName[v.PlayerName] = len(ret)
+			ret = append(ret, PersonalBest{Best: v, Finishes: 1})
+			continue
+		}
+
+		if d := v.Duration(); d < ret[i].Best.Duration() {
+			ret[i].Improvement += ret[i].Best.Duration() - d
+			ret[i].Best = v
+		}
+		ret[i].Finishes++
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Best.Duration() < ret[j].Best.Duration()
+	})
+
+	return ret
+}
+
+// medianDuration returns the median of the given durations, it sorts them
+// in place.
+func medianDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	if len(d)%2 == 1 {
+		return d[len(d)/2]
+	}
+
+	return (d[len(d)/2-1] + d[len(d)/2]) / 2
+}
+
+// GetFinishTimes returns the finish times of every ended match of a league
+// played during the given season, use the zero Season for all time stats.
+func (b *Back) GetFinishTimes(shortcode string, season Season) (ret FinishTimes, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		from, to := season.bounds()
+		ret, err = getFinishTimes(tx, league.ID, util.UUIDAsBlob{}, from, to)
+		return err
+	})
+}
+
+// GetPlayerFinishTimes returns all the finish times of a player in a league.
+func (b *Back) GetPlayerFinishTimes(name, shortcode string) (ret FinishTimes, _ error) {
+	return ret, b.transaction(func(tx *sqlx.Tx) error {
+		player, err := getPlayerByName(tx, name)
+		if err != nil {
+			return err
+		}
+
+		league, err := getLeagueByShortCode(tx, shortcode)
+		if err != nil {
+			return err
+		}
+
+		var allTime Season
+		from, to := allTime.bounds()
+		ret, err = getFinishTimes(tx, league.ID, player.ID, from, to)
+		return err
+	})
+}
+
+// getFinishTimes returns the finish times of the ended matches of a league
+// created in the [from, to) range, optionally restricted to a single player.
+func getFinishTimes(
+	tx *sqlx.Tx,
+	leagueID, playerID util.UUIDAsBlob,
+	from, to util.TimeAsTimestamp,
+) (FinishTimes, error) {
+	query := `
+        SELECT
+            Match.ID AS MatchID,
+            Player.Name AS PlayerName,
+            MatchEntry.StartedAt AS StartedAt,
+            MatchEntry.EndedAt AS EndedAt
+        FROM MatchEntry
+        INNER JOIN Match ON(Match.ID = MatchEntry.MatchID)
+        INNER JOIN Player ON(Player.ID = MatchEntry.PlayerID)
+        WHERE Match.LeagueID = ? AND MatchEntry.Status = ?
+          AND Match.CreatedAt >= ? AND Match.CreatedAt < ?
+          AND Match.EndedAt IS NOT NULL`
+	args := []interface{}{leagueID, MatchEntryStatusFinished, from, to}
+
+	if !playerID.IsZero() {
+		query += ` AND MatchEntry.PlayerID = ?`
+		args = append(args, playerID)
+	}
+
+	var ret FinishTimes
+	if err := tx.Select(&ret, query+` ORDER BY MatchEntry.StartedAt ASC`, args...); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
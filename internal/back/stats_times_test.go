@@ -0,0 +1,60 @@
+package back // nolint:testpackage
+
+import (
+	"kaepora/internal/util"
+	"testing"
+	"time"
+)
+
+func TestFinishTimes(t *testing.T) {
+	monday := time.Date(2020, 5, 11, 20, 0, 0, 0, time.UTC)
+	entry := func(name string, day int, minutes int) FinishTime {
+		start := monday.AddDate(0, 0, day)
+		return FinishTime{
+			PlayerName: name,
+			StartedAt:  util.TimeAsTimestamp(start),
+			EndedAt:    util.TimeAsTimestamp(start.Add(time.Duration(minutes) * time.Minute)),
+		}
+	}
+
+	times := FinishTimes{
+		entry("Saria", 0, 120),
+		entry("Ruto", 1, 100),
+		entry("Saria", 2, 90),
+		entry("Ruto", 7, 110),
+		entry("Saria", 8, 95),
+	}
+
+	records := times.Records(2)
+	if len(records) != 2 || records[0].Duration() != 90*time.Minute || records[1].Duration() != 95*time.Minute {
+		t.Errorf("unexpected records: %v", records)
+	}
+
+	medians := times.WeeklyMedians()
+	expectedMedians := []WeeklyMedian{
+		{Week: time.Date(2020, 5, 11, 0, 0, 0, 0, time.UTC), Median: 100 * time.Minute, Count: 3},
+		{Week: time.Date(2020, 5, 18, 0, 0, 0, 0, time.UTC), Median: 102*time.Minute + 30*time.Second, Count: 2},
+	}
+	if len(medians) != len(expectedMedians) {
+		t.Fatalf("expected %d weeks, got %d", len(expectedMedians), len(medians))
+	}
+	for k := range medians {
+		if !medians[k].Week.Equal(expectedMedians[k].Week) ||
+			medians[k].Median != expectedMedians[k].Median ||
+			medians[k].Count != expectedMedians[k].Count {
+			t.Errorf("week %d: expected %v, got %v", k, expectedMedians[k], medians[k])
+		}
+	}
+
+	bests := times.PersonalBests()
+	if len(bests) != 2 {
+		t.Fatalf("expected 2 players, got %d", len(bests))
+	}
+	if bests[0].Best.PlayerName != "Saria" || bests[0].Finishes != 3 ||
+		bests[0].Best.Duration() != 90*time.Minute || bests[0].Improvement != 30*time.Minute {
+		t.Errorf("unexpected personal best: %#v", bests[0])
+	}
+	if bests[1].Best.PlayerName != "Ruto" || bests[1].Improvement != 0 {
+		t.Errorf("unexpected personal best: %#v", bests[1])
+	}
+}
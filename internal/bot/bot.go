@@ -31,11 +31,14 @@ type Bot struct {
 	token     string
 	dg        *discordgo.Session
 
+	// Secret key shared with the website to sign login tokens.
+	tokenKey []byte
+
 	handlers      map[string]commandHandler
 	notifications <-chan back.Notification
 }
 
-func New(back *back.Back, token string) (*Bot, error) {
+func New(back *back.Back, token, tokenKey string) (*Bot, error) {
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
@@ -50,6 +53,7 @@ func New(back *back.Back, token string) (*Bot, error) {
 		back:          back,
 		config:        conf,
 		token:         token,
+		tokenKey:      []byte(tokenKey),
 		dg:            dg,
 		startedAt:     time.Now(),
 		notifications: back.GetNotificationsChan(),
@@ -59,17 +63,20 @@ func New(back *back.Back, token string) (*Bot, error) {
 
 	bot.handlers = map[string]commandHandler{
 		"!dev":          bot.cmdDev,
+		"!h2h":          bot.cmdHeadToHead,
 		"!help":         bot.cmdHelp,
 		"!leaderboard":  bot.cmdLeaderboards,
 		"!leaderboards": bot.cmdLeaderboards,
 		"!leagues":      bot.cmdLeagues,
 		"!no":           bot.cmdHelp,
+		"!poll":         bot.cmdPoll,
 		"!recap":        bot.cmdRecap,
 		"!register":     bot.cmdRegister,
 		"!rename":       bot.cmdRename,
 		"!setstream":    bot.cmdSetStream,
 		"!seed":         bot.cmdSendSeed,
 		"!spoilers":     bot.cmdSpoilers,
+		"!weblogin":     bot.cmdWebLogin,
 		"!yes":          bot.cmdAllRight,
 
 		"!cancel":   bot.cmdCancel,
@@ -88,13 +95,7 @@ func New(back *back.Back, token string) (*Bot, error) {
 // isAdmin returns true if the given Discord user ID is a Kaepora admin,
 // meaning he has access to extra data and dangerous commands.
 func (bot *Bot) isAdmin(discordID string) bool {
-	for _, v := range bot.config.DiscordAdminUserIDs {
-		if discordID == v {
-			return true
-		}
-	}
-
-	return false
+	return bot.config.IsDiscordAdmin(discordID)
 }
 
 // Serve runs the Discord bot until the done channel is closed.
@@ -261,15 +262,18 @@ func (bot *Bot) cmdHelp(m *discordgo.Message, _ []string, w io.Writer) error {
 	fmt.Fprintf(w, `**Available commands**:
 %[1]s
 # Management
+!h2h NAME               # show your record against another player
 !help                   # display this help message
 !leaderboard SHORTCODE  # show leaderboards for the given league
 !leagues                # list leagues
+!poll SHORTCODE [N]     # show the poll on the date of an extra race of a league, or toggle your vote for its date N
 !recap SHORTCODE        # show the 1v1 results for the current session
 !register               # create your account and link it to your Discord account
 !register NAME          # same as "!register" but use another name
 !rename NAME            # set your display name to NAME
 !seed SHORTCODE [SEED]  # generate a seed valid for the given league
 !setstream URL          # set your stream URL
+!weblogin               # receive a link to log in on the website
 
 # Racing
 !cancel            # cancel joining the next race without penalty until T%[3]s
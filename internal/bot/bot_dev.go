@@ -3,8 +3,10 @@ package bot
 import (
 	"fmt"
 	"io"
+	"kaepora/internal/back"
 	"kaepora/internal/generator/oot"
 	"kaepora/internal/generator/oot/settings"
+	"kaepora/internal/rating"
 	"kaepora/internal/util"
 	"strconv"
 	"strings"
@@ -22,9 +24,44 @@ func (bot *Bot) cmdDev(m *discordgo.Message, args []string, out io.Writer) error
 		fmt.Fprintf(out, `
 **Admin-only commands**:
 %[1]s
+!dev comparerating SHORTCODE SYSTEM # compare the current leaderboard with the one given by another rating system, nothing is saved
+!dev division SHORTCODE add NAME PROMOTE RELEGATE # add a division at the bottom of a league
+!dev division SHORTCODE list # list the divisions of a league, top division first
+!dev division SHORTCODE seed # spread rated players across divisions according to their rating
 !dev error                   # error out
+!dev generator SHORTCODE GENERATOR SETTINGS # change the seed generator of a league, eg. oot-randomizer-api:5.2.0 s3.json
+!dev invite SHORTCODE NAME   # allow a player to join an invite-only league
+!dev league create SHORTCODE GENERATOR SETTINGS NAME # create a league without schedule
+!dev league edit SHORTCODE NEWSHORTCODE NAME # rename a league
+!dev league delete SHORTCODE # delete a league that never had any race
+!dev leaderboard SHORTCODE [KEY VALUE] # show or set a leaderboard setting, KEY is one of
+    # maxdeviation (default 220), minraces, placementraces (first races are played against players with a non-provisional rating)
+!dev maintenance [on [MESSAGE]|off] # show or toggle the maintenance mode, no race is run and joining is refused while enabled
+!dev uninvite SHORTCODE NAME # revoke an invitation
 !dev panic                   # panic and abort
-!dev rerank SHORTCODE        # erase and recompute all the ranking history for a league
+!dev poll open SHORTCODE QUORUM TIMEZONE DATE HH:MM [DATE HH:MM…] # let players vote on the date of an extra race, the first date to get QUORUM votes is created
+!dev poll close SHORTCODE    # close the open poll of a league without creating its race
+!dev pause|resume SHORTCODE  # stop or restart creating and running the races of a league, joining is refused while paused
+!dev requirements SHORTCODE [KEY VALUE] # show or set an entry requirement, KEY is one of
+    # inviteonly (true/false), prerequisite (SHORTCODE), minrating, minraces, maxplayers, minaccountage (days)
+!dev ratingperiod SHORTCODE [PERIOD] # show or change the rating period length of a league (session, daily, weekly, monthly), changing it reranks the league
+!dev ratingsystem SHORTCODE [SYSTEM] # show or change the rating system of a league, changing it reranks the league
+!dev rerank SHORTCODE [SINCE] # recompute the ranking history of a league and show the changes, SINCE (YYYY-MM-DD) only recomputes the rating periods from that date onward
+!dev rerankdiff SHORTCODE [SINCE] # show the changes !dev rerank would make, nothing is saved
+!dev season SHORTCODE add NAME START END [none|hard|soft FACTOR BUMP] # add a season, dates are YYYY-MM-DD
+    # and rounded down to the start of their rating period, a soft reset keeps FACTOR (0-1) of the distance to the base rating and adds BUMP to the deviation
+!dev season SHORTCODE list   # list the seasons of a league
+!dev schedule SHORTCODE add|remove DAY HH:MM TIMEZONE # DAY is Mon to Sun or all, eg. add Mon 20:00 America/New_York
+!dev schedule SHORTCODE add|remove rule RRULE HH:MM TIMEZONE # eg. add rule FREQ=MONTHLY;BYDAY=1SA 20:00 Europe/Paris
+!dev schedule SHORTCODE add|remove date YYYY-MM-DD HH:MM TIMEZONE # a one-off race
+!dev schedule SHORTCODE add|remove blackout YYYY-MM-DD[/YYYY-MM-DD] # no weekly or rule race on these days
+!dev schedule SHORTCODE show # show the schedule of a league and its next races
+!dev session create SHORTCODE DATE HH:MM TIMEZONE # create a one-off race, DATE is YYYY-MM-DD
+!dev session list SHORTCODE  # list the races of a league that are not closed yet
+!dev session postpone ID DATE HH:MM TIMEZONE # move a race that did not start its preparation to a later date
+!dev session cancel ID       # cancel a race that did not start its preparation
+!dev session add|kick ID NAME # register or remove a player before the race preparation
+!dev session reseed ID       # regenerate the seeds of the running matches with the current league generator
 !dev setannounce SHORTCODE   # configure a league to post its announcements in the channel the command was sent in
 !dev uptime                  # display for how long the server has been running
 !dev url                     # display the link to use when adding the bot to a new server
@@ -64,8 +101,54 @@ func (bot *Bot) cmdDev(m *discordgo.Message, args []string, out io.Writer) error
 		return bot.cmdDevAddListen(m, args, out)
 	case "removelisten":
 		return bot.cmdDevRemoveListen(m, args, out)
-	case "rerank":
+	case "rerank", "rerankdiff":
 		return bot.cmdDevRerank(m, args, out)
+	case "comparerating":
+		return bot.cmdDevCompareRatingSystem(m, args[1:], out)
+	case "ratingsystem":
+		return bot.cmdDevRatingSystem(m, args[1:], out)
+	case "ratingperiod":
+		return bot.cmdDevRatingPeriod(m, args[1:], out)
+	case "division":
+		return bot.cmdDevDivision(m, args[1:], out)
+	case "season":
+		return bot.cmdDevSeason(m, args[1:], out)
+	case "requirements":
+		return bot.cmdDevRequirements(m, args[1:], out)
+	case "leaderboard":
+		return bot.cmdDevLeaderboard(m, args[1:], out)
+	case "league":
+		return bot.cmdDevLeague(m, args[1:], out)
+	case "schedule":
+		return bot.cmdDevSchedule(m, args[1:], out)
+	case "generator":
+		return bot.cmdDevGenerator(m, args[1:], out)
+	case "session":
+		return bot.cmdDevSession(m, args[1:], out)
+	case "maintenance":
+		return bot.cmdDevMaintenance(m, args[1:], out)
+	case "poll":
+		return bot.cmdDevPoll(m, args[1:], out)
+	case "pause", "resume":
+		if len(args) != 2 {
+			return util.ErrPublic(fmt.Sprintf("usage: `!dev %s SHORTCODE`", args[0]))
+		}
+		if err := bot.back.SetLeaguePaused(args[1], args[0] == "pause"); err != nil {
+			return err
+		}
+		if args[0] == "pause" {
+			fmt.Fprintf(out, "League `%s` is now paused.", args[1])
+		} else {
+			fmt.Fprintf(out, "League `%s` is running again.", args[1])
+		}
+	case "invite", "uninvite":
+		if len(args) < 3 {
+			return util.ErrPublic("usage: `!dev invite|uninvite SHORTCODE NAME`")
+		}
+		if err := bot.back.InvitePlayer(args[1], argsAsName(args[2:]), args[0] == "invite"); err != nil {
+			return err
+		}
+		fmt.Fprint(out, "Invite list updated.")
 	default:
 		return util.ErrPublic("invalid command")
 	}
@@ -135,7 +218,719 @@ func (bot *Bot) cmdDevRandomSettings(m *discordgo.Message, args []string, w io.W
 	return nil
 }
 
-func (bot *Bot) cmdDevRerank(_ *discordgo.Message, args []string, _ io.Writer) error {
-	shortcode := argsAsName(args[1:])
-	return bot.back.Rerank(shortcode)
+// cmdDevRerank handles "!dev rerank SHORTCODE [SINCE]" and
+// "!dev rerankdiff SHORTCODE [SINCE]".
+func (bot *Bot) cmdDevRerank(_ *discordgo.Message, args []string, out io.Writer) error {
+	dryRun := args[0] == "rerankdiff"
+	if len(args) < 2 || len(args) > 3 {
+		return util.ErrPublic(fmt.Sprintf("usage: `!dev %s SHORTCODE [SINCE]`", args[0]))
+	}
+
+	var since time.Time
+	if len(args) == 3 {
+		var err error
+		since, err = time.Parse("2006-01-02", args[2])
+		if err != nil {
+			return util.ErrPublic("invalid date, expected YYYY-MM-DD")
+		}
+	}
+
+	diff, err := bot.back.Rerank(args[1], since, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(out, "Reranking league `%s` would make the following changes, nothing was saved:\n", args[1])
+	} else {
+		fmt.Fprintf(out, "League `%s` has been reranked with the following changes:\n", args[1])
+	}
+	writeRankingDiff(out, diff)
+
+	return nil
+}
+
+// writeRankingDiff writes the players whose rating or rank changed, up to a
+// Discord-friendly amount.
+func writeRankingDiff(out io.Writer, diff []back.RankingDiff) {
+	const maxLines = 30
+
+	changed := make([]back.RankingDiff, 0, len(diff))
+	for _, v := range diff {
+		if v.Changed() {
+			changed = append(changed, v)
+		}
+	}
+
+	if len(changed) == 0 {
+		fmt.Fprint(out, "No change.")
+		return
+	}
+
+	fmt.Fprint(out, "```\n")
+	for k, v := range changed {
+		if k >= maxLines {
+			fmt.Fprintf(out, "… and %d more\n", len(changed)-maxLines)
+			break
+		}
+		fmt.Fprintln(out, v.String())
+	}
+	fmt.Fprint(out, "```")
+}
+
+// cmdDevCompareRatingSystem handles "!dev comparerating SHORTCODE SYSTEM".
+func (bot *Bot) cmdDevCompareRatingSystem(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return util.ErrPublic("usage: `!dev comparerating SHORTCODE SYSTEM`")
+	}
+
+	shortcode, system := args[0], args[1]
+	comparison, err := bot.back.CompareRatingSystem(shortcode, system)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Top 20 of league `%s` using `%s`, current rating in parenthesis:\n```\n", shortcode, system)
+	for k, v := range comparison {
+		if k >= 20 {
+			break
+		}
+
+		fmt.Fprintf(
+			out, "%2d. %-20s %4.0f ±%3.0f (%4.0f ±%3.0f)\n",
+			k+1, v.PlayerName,
+			v.Candidate.Rating, v.Candidate.Deviation,
+			v.Current.Rating, v.Current.Deviation,
+		)
+	}
+	fmt.Fprint(out, "```")
+
+	return nil
+}
+
+// cmdDevRatingSystem handles "!dev ratingsystem SHORTCODE [SYSTEM]".
+func (bot *Bot) cmdDevRatingSystem(_ *discordgo.Message, args []string, out io.Writer) error {
+	switch len(args) {
+	case 1:
+		league, err := bot.back.GetLeagueByShortcode(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(
+			out, "League `%s` uses the `%s` rating system, available systems: %s.",
+			league.ShortCode, league.RatingSystem, strings.Join(rating.SystemIDs, ", "),
+		)
+	case 2:
+		if err := bot.back.SetLeagueRatingSystem(args[0], args[1]); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "League `%s` now uses the `%s` rating system and has been reranked.", args[0], args[1])
+	default:
+		return util.ErrPublic("usage: `!dev ratingsystem SHORTCODE [SYSTEM]`")
+	}
+
+	return nil
+}
+
+// cmdDevRatingPeriod handles "!dev ratingperiod SHORTCODE [PERIOD]".
+func (bot *Bot) cmdDevRatingPeriod(_ *discordgo.Message, args []string, out io.Writer) error {
+	switch len(args) {
+	case 1:
+		league, err := bot.back.GetLeagueByShortcode(args[0])
+		if err != nil {
+			return err
+		}
+
+		periods := make([]string, 0, len(back.RatingPeriods))
+		for _, v := range back.RatingPeriods {
+			periods = append(periods, string(v))
+		}
+
+		fmt.Fprintf(
+			out, "League `%s` uses `%s` rating periods, available periods: %s.",
+			league.ShortCode, league.RatingPeriod, strings.Join(periods, ", "),
+		)
+	case 2:
+		if err := bot.back.SetLeagueRatingPeriod(args[0], back.RatingPeriod(args[1])); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "League `%s` now uses `%s` rating periods and has been reranked.", args[0], args[1])
+	default:
+		return util.ErrPublic("usage: `!dev ratingperiod SHORTCODE [PERIOD]`")
+	}
+
+	return nil
+}
+
+// cmdDevDivision handles "!dev division SHORTCODE add|list|seed".
+func (bot *Bot) cmdDevDivision(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a shortcode and a subcommand, see `!dev`")
+	}
+	shortcode := args[0]
+
+	switch args[1] {
+	case "add": // NAME PROMOTE RELEGATE
+		if len(args) != 5 {
+			return util.ErrPublic("usage: `!dev division SHORTCODE add NAME PROMOTE RELEGATE`")
+		}
+
+		promote, err := strconv.Atoi(args[3])
+		if err != nil {
+			return util.ErrPublic(err.Error())
+		}
+		relegate, err := strconv.Atoi(args[4])
+		if err != nil {
+			return util.ErrPublic(err.Error())
+		}
+
+		if err := bot.back.AddDivision(shortcode, args[2], promote, relegate); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Division `%s` added to league `%s`.", args[2], shortcode)
+	case "list":
+		divisions, err := bot.back.GetDivisions(shortcode)
+		if err != nil {
+			return err
+		}
+		if len(divisions) == 0 {
+			fmt.Fprintf(out, "League `%s` has no divisions.", shortcode)
+			return nil
+		}
+
+		fmt.Fprintf(out, "Divisions of league `%s`:\n```\n", shortcode)
+		for _, v := range divisions {
+			fmt.Fprintf(out, "%d. %s (promote %d, relegate %d)\n", v.Tier+1, v.Name, v.PromotionCount, v.RelegationCount)
+		}
+		fmt.Fprint(out, "```")
+	case "seed":
+		if err := bot.back.SeedDivisions(shortcode); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Players of league `%s` have been spread across its divisions.", shortcode)
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	return nil
+}
+
+// cmdDevSeason handles "!dev season SHORTCODE add|list".
+func (bot *Bot) cmdDevSeason(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a shortcode and a subcommand, see `!dev`")
+	}
+	shortcode := args[0]
+
+	switch args[1] {
+	case "add": // NAME START END [none|hard|soft FACTOR BUMP]
+		season, err := parseSeason(args[2:])
+		if err != nil {
+			return err
+		}
+
+		if err := bot.back.AddSeason(shortcode, season); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Season `%s` added to league `%s`.", season.Name, shortcode)
+	case "list":
+		seasons, err := bot.back.GetSeasons(shortcode)
+		if err != nil {
+			return err
+		}
+		if len(seasons) == 0 {
+			fmt.Fprintf(out, "League `%s` has no seasons.", shortcode)
+			return nil
+		}
+
+		fmt.Fprintf(out, "Seasons of league `%s`:\n```\n", shortcode)
+		for _, v := range seasons {
+			fmt.Fprintf(
+				out, "%s: %s to %s, %s",
+				v.Name,
+				v.StartDate.Time().Format("2006-01-02"),
+				v.EndDate.Time().Format("2006-01-02"),
+				seasonResetName(v.ResetMode),
+			)
+			if v.ResetMode == back.SeasonResetSoft {
+				fmt.Fprintf(out, " (factor %.2f, deviation +%.0f)", v.ResetFactor, v.ResetDeviationBump)
+			}
+			if v.IsClosed() {
+				fmt.Fprint(out, ", closed")
+			}
+			fmt.Fprint(out, "\n")
+		}
+		fmt.Fprint(out, "```")
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	return nil
+}
+
+var seasonResetNames = map[string]back.SeasonResetMode{
+	"none": back.SeasonResetNone,
+	"hard": back.SeasonResetHard,
+	"soft": back.SeasonResetSoft,
+}
+
+func seasonResetName(mode back.SeasonResetMode) string {
+	for k, v := range seasonResetNames {
+		if v == mode {
+			return k + " reset"
+		}
+	}
+
+	return "invalid reset"
+}
+
+// parseSeason parses "NAME START END [none|hard|soft FACTOR BUMP]".
+func parseSeason(args []string) (back.Season, error) {
+	usage := util.ErrPublic("usage: `!dev season SHORTCODE add NAME START END [none|hard|soft FACTOR BUMP]`")
+	if len(args) != 3 && len(args) != 4 && len(args) != 6 {
+		return back.Season{}, usage
+	}
+
+	start, err := time.Parse("2006-01-02", args[1])
+	if err != nil {
+		return back.Season{}, util.ErrPublic(fmt.Sprintf("invalid start date: %s", err))
+	}
+	end, err := time.Parse("2006-01-02", args[2])
+	if err != nil {
+		return back.Season{}, util.ErrPublic(fmt.Sprintf("invalid end date: %s", err))
+	}
+
+	season := back.NewSeason(util.UUIDAsBlob{}, args[0], start, end)
+	if len(args) == 3 {
+		return season, nil
+	}
+
+	mode, ok := seasonResetNames[args[3]]
+	if !ok {
+		return back.Season{}, usage
+	}
+	season.ResetMode = mode
+
+	if mode != back.SeasonResetSoft {
+		if len(args) != 4 {
+			return back.Season{}, usage
+		}
+		return season, nil
+	}
+
+	if len(args) != 6 {
+		return back.Season{}, usage
+	}
+	if season.ResetFactor, err = strconv.ParseFloat(args[4], 64); err != nil {
+		return back.Season{}, util.ErrPublic(fmt.Sprintf("invalid factor: %s", err))
+	}
+	if season.ResetDeviationBump, err = strconv.ParseFloat(args[5], 64); err != nil {
+		return back.Season{}, util.ErrPublic(fmt.Sprintf("invalid deviation bump: %s", err))
+	}
+
+	return season, nil
+}
+
+// cmdDevRequirements handles "!dev requirements SHORTCODE [KEY VALUE]".
+func (bot *Bot) cmdDevRequirements(_ *discordgo.Message, args []string, out io.Writer) error {
+	switch len(args) {
+	case 1:
+		league, err := bot.back.GetLeagueByShortcode(args[0])
+		if err != nil {
+			return err
+		}
+
+		if str := league.Requirements.String(); str != "" {
+			fmt.Fprintf(out, "Requirements for league `%s`: %s.", league.ShortCode, str)
+		} else {
+			fmt.Fprintf(out, "League `%s` has no requirements.", league.ShortCode)
+		}
+	case 3:
+		requirements, err := bot.back.SetLeagueRequirement(args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Requirements for league `%s` updated: %s.", args[0], requirements.String())
+	default:
+		return util.ErrPublic("usage: `!dev requirements SHORTCODE [KEY VALUE]`")
+	}
+
+	return nil
+}
+
+// cmdDevLeaderboard handles "!dev leaderboard SHORTCODE [KEY VALUE]".
+func (bot *Bot) cmdDevLeaderboard(_ *discordgo.Message, args []string, out io.Writer) error {
+	switch len(args) {
+	case 1:
+		league, err := bot.back.GetLeagueByShortcode(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Players of league `%s` are ranked with a %s.", league.ShortCode, league.Leaderboard.String())
+	case 3:
+		leaderboard, err := bot.back.SetLeagueLeaderboard(args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Leaderboard settings for league `%s` updated: %s.", args[0], leaderboard.String())
+	default:
+		return util.ErrPublic("usage: `!dev leaderboard SHORTCODE [KEY VALUE]`")
+	}
+
+	return nil
+}
+
+// cmdDevLeague handles "!dev league create|edit|delete".
+func (bot *Bot) cmdDevLeague(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a subcommand and a shortcode, see `!dev`")
+	}
+
+	switch args[0] {
+	case "create": // SHORTCODE GENERATOR SETTINGS NAME
+		if len(args) < 5 {
+			return util.ErrPublic("usage: `!dev league create SHORTCODE GENERATOR SETTINGS NAME`")
+		}
+
+		games, err := bot.back.GetGames()
+		if err != nil {
+			return err
+		}
+		switch len(games) {
+		case 0:
+			return util.ErrPublic("there is no game yet, create one on the website first")
+		case 1:
+		default:
+			return util.ErrPublic("there is more than one game, create the league on the website")
+		}
+
+		league := back.NewLeague(argsAsName(args[4:]), args[1], games[0].ID, args[2], args[3])
+		if err := bot.back.CreateLeague(league); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "League `%s` created, use `!dev schedule %[1]s add` to schedule its races.", league.ShortCode)
+	case "edit": // SHORTCODE NEWSHORTCODE NAME
+		if len(args) < 4 {
+			return util.ErrPublic("usage: `!dev league edit SHORTCODE NEWSHORTCODE NAME`")
+		}
+
+		name := argsAsName(args[3:])
+		if err := bot.back.RenameLeague(args[1], args[2], name); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "League `%s` is now `%s` (%s).", args[1], args[2], name)
+	case "delete": // SHORTCODE
+		if err := bot.back.DeleteLeague(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "League `%s` deleted.", args[1])
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	return nil
+}
+
+// cmdDevSchedule handles "!dev schedule SHORTCODE add|remove|show".
+func (bot *Bot) cmdDevSchedule(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a shortcode and a subcommand, see `!dev`")
+	}
+	shortcode := args[0]
+
+	var (
+		schedule back.Schedule
+		err      error
+	)
+	switch args[1] {
+	case "add", "remove": // DAY|rule|date|blackout ENTRY
+		if len(args) < 4 {
+			return util.ErrPublic(fmt.Sprintf("usage: `!dev schedule SHORTCODE %s DAY HH:MM TIMEZONE`, see `!dev`", args[1]))
+		}
+
+		entry := strings.Join(args[3:], " ")
+		if args[1] == "add" {
+			schedule, err = bot.back.AddLeagueSchedule(shortcode, args[2], entry)
+		} else {
+			schedule, err = bot.back.RemoveLeagueSchedule(shortcode, args[2], entry)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Schedule of league `%s` updated:\n", shortcode)
+	case "show":
+		league, err := bot.back.GetLeagueByShortcode(shortcode)
+		if err != nil {
+			return err
+		}
+		schedule = league.Schedule
+		fmt.Fprintf(out, "Schedule of league `%s`:\n", shortcode)
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	writeSchedule(out, schedule)
+	return nil
+}
+
+// writeSchedule writes the entries of a schedule day by day, its rules, dates
+// and blackouts, followed by the races they give in the next week.
+func writeSchedule(out io.Writer, schedule back.Schedule) {
+	fmt.Fprint(out, "```\n")
+	for _, v := range []struct {
+		day   string
+		hours []string
+	}{
+		{"Mon", schedule.Mon}, {"Tue", schedule.Tue}, {"Wed", schedule.Wed},
+		{"Thu", schedule.Thu}, {"Fri", schedule.Fri}, {"Sat", schedule.Sat},
+		{"Sun", schedule.Sun},
+	} {
+		fmt.Fprintf(out, "%s: %s\n", v.day, strings.Join(v.hours, ", "))
+	}
+	for _, v := range []struct {
+		name    string
+		entries []string
+	}{
+		{"Rules", schedule.Rules}, {"Dates", schedule.Dates}, {"Blackouts", schedule.Blackouts},
+	} {
+		if len(v.entries) > 0 {
+			fmt.Fprintf(out, "%s: %s\n", v.name, strings.Join(v.entries, ", "))
+		}
+	}
+	fmt.Fprint(out, "```")
+
+	now := time.Now()
+	next, err := schedule.Between(now, now.AddDate(0, 0, 7))
+	if err != nil {
+		fmt.Fprintf(out, "This schedule is invalid: %s", err)
+		return
+	}
+	if len(next) == 0 {
+		fmt.Fprint(out, "No race in the next 7 days.")
+		return
+	}
+
+	fmt.Fprint(out, "Races in the next 7 days:\n")
+	for _, v := range next {
+		fmt.Fprintf(out, " - %s\n", util.Datetime(v))
+	}
+}
+
+// cmdDevGenerator handles "!dev generator SHORTCODE GENERATOR SETTINGS".
+func (bot *Bot) cmdDevGenerator(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) != 3 {
+		return util.ErrPublic("usage: `!dev generator SHORTCODE GENERATOR SETTINGS`")
+	}
+
+	if err := bot.back.SetLeagueGenerator(args[0], args[1], args[2]); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "League `%s` will now use `%s` with `%s` for its next seeds.", args[0], args[1], args[2])
+
+	return nil
+}
+
+// cmdDevSession handles the "!dev session" subcommands.
+func (bot *Bot) cmdDevSession(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a subcommand and a shortcode or race ID, see `!dev`")
+	}
+
+	if args[0] == "create" || args[0] == "list" {
+		return bot.cmdDevSessionLeague(args, out)
+	}
+
+	uid, err := uuid.Parse(args[1])
+	if err != nil {
+		return util.ErrPublic(fmt.Sprintf("invalid race ID '%s', see `!dev session list`", args[1]))
+	}
+	id := util.UUIDAsBlob(uid)
+
+	var session back.MatchSession
+	switch args[0] {
+	case "postpone": // ID DATE HH:MM TIMEZONE
+		if len(args) != 5 {
+			return util.ErrPublic("usage: `!dev session postpone ID DATE HH:MM TIMEZONE`")
+		}
+		start, err := util.ParseDatetime(args[2], args[3], args[4])
+		if err != nil {
+			return err
+		}
+		if session, err = bot.back.PostponeMatchSession(id, start); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Race postponed to %s.", util.Datetime(session.StartDate))
+	case "cancel":
+		if _, err := bot.back.CancelMatchSession(id); err != nil {
+			return err
+		}
+		fmt.Fprint(out, "Race cancelled.")
+	case "add", "kick": // ID NAME
+		if len(args) < 3 {
+			return util.ErrPublic(fmt.Sprintf("usage: `!dev session %s ID NAME`", args[0]))
+		}
+		name := argsAsName(args[2:])
+		if args[0] == "add" {
+			session, err = bot.back.AddMatchSessionPlayer(id, name)
+		} else {
+			session, err = bot.back.KickMatchSessionPlayer(id, name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(
+			out, "Race updated, %d player(s) registered and %d on the waitlist.",
+			len(session.PlayerIDs), len(session.WaitlistPlayerIDs),
+		)
+	case "reseed":
+		count, err := bot.back.RegenerateMatchSessionSeeds(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Regenerating %d seed(s), they will be sent to the players once ready.", count)
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	return nil
+}
+
+// cmdDevSessionLeague handles "!dev session create|list SHORTCODE".
+func (bot *Bot) cmdDevSessionLeague(args []string, out io.Writer) error {
+	shortcode := args[1]
+	if args[0] == "create" {
+		if len(args) != 5 {
+			return util.ErrPublic("usage: `!dev session create SHORTCODE DATE HH:MM TIMEZONE`")
+		}
+		start, err := util.ParseDatetime(args[2], args[3], args[4])
+		if err != nil {
+			return err
+		}
+		sessions, err := bot.back.CreateMatchSession(shortcode, start)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %d race(s) for league `%s` at %s.\n", len(sessions), shortcode, util.Datetime(start))
+	}
+
+	league, sessions, err := bot.back.GetLeagueOpenMatchSessions(shortcode)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintf(out, "League `%s` has no upcoming or running race.", league.ShortCode)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Races of league `%s`:\n```\n", league.ShortCode)
+	for _, v := range sessions {
+		fmt.Fprintf(
+			out, "%s  %s  %-11s  %d player(s), %d waitlisted\n",
+			v.ID, util.Datetime(v.StartDate), sessionStatusName(v.Status),
+			len(v.PlayerIDs), len(v.WaitlistPlayerIDs),
+		)
+	}
+	fmt.Fprint(out, "```")
+
+	return nil
+}
+
+func sessionStatusName(status back.MatchSessionStatus) string {
+	switch status {
+	case back.MatchSessionStatusWaiting:
+		return "waiting"
+	case back.MatchSessionStatusJoinable:
+		return "joinable"
+	case back.MatchSessionStatusPreparing:
+		return "preparing"
+	case back.MatchSessionStatusInProgress:
+		return "in progress"
+	case back.MatchSessionStatusClosed:
+		return "closed"
+	default:
+		return "invalid"
+	}
+}
+
+// cmdDevMaintenance handles "!dev maintenance [on [MESSAGE]|off]".
+func (bot *Bot) cmdDevMaintenance(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "on":
+			if err := bot.back.SetMaintenance(true, argsAsName(args[1:])); err != nil {
+				return err
+			}
+		case "off":
+			if err := bot.back.SetMaintenance(false, ""); err != nil {
+				return err
+			}
+		default:
+			return util.ErrPublic("usage: `!dev maintenance [on [MESSAGE]|off]`")
+		}
+	}
+
+	maintenance, err := bot.back.GetMaintenance()
+	if err != nil {
+		return err
+	}
+
+	if !maintenance.Enabled {
+		fmt.Fprint(out, "Maintenance mode is disabled.")
+		return nil
+	}
+
+	fmt.Fprintf(out, "Maintenance mode is enabled since %s.", util.Datetime(maintenance.UpdatedAt.Time()))
+	if maintenance.Message != "" {
+		fmt.Fprintf(out, "\nMessage: %s", maintenance.Message)
+	}
+
+	return nil
+}
+
+// cmdDevPoll handles "!dev poll open|close SHORTCODE".
+func (bot *Bot) cmdDevPoll(_ *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return util.ErrPublic("expected a subcommand and a shortcode, see `!dev`")
+	}
+
+	switch args[0] {
+	case "open": // SHORTCODE QUORUM TIMEZONE DATE HH:MM [DATE HH:MM…]
+		if len(args) < 6 || len(args)%2 != 0 {
+			return util.ErrPublic("usage: `!dev poll open SHORTCODE QUORUM TIMEZONE DATE HH:MM [DATE HH:MM…]`")
+		}
+		quorum, err := strconv.Atoi(args[2])
+		if err != nil {
+			return util.ErrPublic(fmt.Sprintf("invalid quorum '%s'", args[2]))
+		}
+
+		var starts []time.Time
+		for k := 4; k < len(args); k += 2 {
+			start, err := util.ParseDatetime(args[k], args[k+1], args[3])
+			if err != nil {
+				return err
+			}
+			starts = append(starts, start)
+		}
+
+		poll, err := bot.back.OpenPoll(args[1], quorum, starts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Poll opened for league `%s` with %d dates, the first to get %d votes wins.", args[1], len(poll.Slots), quorum)
+	case "close":
+		if _, err := bot.back.ClosePoll(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Poll of league `%s` closed.", args[1])
+	default:
+		return util.ErrPublic("invalid command")
+	}
+
+	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -37,7 +39,9 @@ func (bot *Bot) displayLeagues(out io.Writer) error {
 			}
 
 			var nextStr, nextDeltaStr string
-			if next, ok := times[league.ID]; ok {
+			if league.Paused {
+				nextStr = "paused"
+			} else if next, ok := times[league.ID]; ok {
 				nextStr = next.Format("2006-01-02 15:04 MST")
 				delta := next.Sub(now).Truncate(time.Minute)
 				nextDeltaStr = "(in " + strings.TrimSuffix(delta.String(), "0s") + ")"
@@ -54,6 +58,16 @@ func (bot *Bot) displayLeagues(out io.Writer) error {
 		table.Flush()
 
 		fmt.Fprint(out, "```\n")
+
+		for _, league := range leagues {
+			if league.GameID != game.ID {
+				continue
+			}
+
+			if str := league.Requirements.String(); str != "" {
+				fmt.Fprintf(out, "`%s` requirements: %s.\n", league.ShortCode, str)
+			}
+		}
 	}
 
 	return nil
@@ -68,3 +82,62 @@ func (bot *Bot) cmdRecap(m *discordgo.Message, args []string, out io.Writer) err
 
 	return bot.back.SendRecaps(m.Author.ID, shortcode, scope)
 }
+
+// cmdPoll handles "!poll SHORTCODE [N]", it shows the open poll of a
+// league or toggles the vote of the player for one of its dates.
+func (bot *Bot) cmdPoll(m *discordgo.Message, args []string, out io.Writer) error {
+	if len(args) < 1 || len(args) > 2 {
+		return util.ErrPublic("usage: `!poll SHORTCODE [N]`")
+	}
+
+	league, poll, err := bot.back.GetLeagueOpenPoll(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		writePoll(out, league, poll)
+		return nil
+	}
+
+	player, err := bot.back.GetPlayerByDiscordID(m.Author.ID)
+	if err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(args[1])
+	if err != nil || number < 1 || number > len(poll.Slots) {
+		return util.ErrPublic(fmt.Sprintf("invalid date number '%s', see `!poll %s`", args[1], league.ShortCode))
+	}
+	slot := poll.Slots[number-1]
+	vote := !slot.HasVoter(player.ID.UUID())
+
+	if poll, err = bot.back.VotePoll(player, slot.ID, vote); err != nil {
+		return err
+	}
+
+	if _, ok := poll.ChosenSlot(); ok {
+		fmt.Fprintf(out, "Your vote closed the poll, the race will start at %s.", util.Datetime(slot.StartDate))
+	} else if vote {
+		fmt.Fprintf(out, "You voted for %s.\n", util.Datetime(slot.StartDate))
+		writePoll(out, league, poll)
+	} else {
+		fmt.Fprintf(out, "You removed your vote for %s.\n", util.Datetime(slot.StartDate))
+		writePoll(out, league, poll)
+	}
+
+	return nil
+}
+
+// writePoll writes the dates of a poll with their number of votes.
+func writePoll(out io.Writer, league back.League, poll back.Poll) {
+	fmt.Fprintf(out, "Poll for an extra race of league `%s`, the first date to get %d votes wins:\n", league.ShortCode, poll.Quorum)
+	for k, v := range poll.Slots {
+		fmt.Fprintf(out, " %d. %s: %d vote(s)", k+1, util.Datetime(v.StartDate), len(v.VoterIDs))
+		if !v.IsVotable() {
+			fmt.Fprint(out, " (closed)")
+		}
+		fmt.Fprint(out, "\n")
+	}
+	fmt.Fprintf(out, "Vote or remove your vote using `!poll %s N`.", league.ShortCode)
+}
@@ -29,6 +29,16 @@ func (bot *Bot) cmdJoin(m *discordgo.Message, args []string, w io.Writer) error
 		return err
 	}
 
+	if pos := session.WaitlistPosition(player.ID.UUID()); pos > 0 {
+		fmt.Fprintf(
+			w,
+			"The next race in the %s league is full, you have been put on the waitlist at position %d.\n"+
+				"You will be notified if a spot frees up, you can leave the waitlist using `!cancel`.",
+			league.Name, pos,
+		)
+		return nil
+	}
+
 	fmt.Fprintf(w, "You have been registered for the next race in the %s league.\n", league.Name)
 	fmt.Fprint(w, "Please ensure you have read the rules before the race: https://ootrladder.com/en/rules\n")
 
@@ -3,7 +3,10 @@ package bot
 import (
 	"fmt"
 	"io"
+	"kaepora/internal/back"
 	"kaepora/internal/util"
+	"text/tabwriter"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -62,19 +65,150 @@ func (bot *Bot) cmdLeaderboards(m *discordgo.Message, args []string, w io.Writer
 		return nil
 	}
 
-	fmt.Fprintf(w, "Top players for league `%s`:\n```\n", shortcode)
-	for i := range top {
-		fmt.Fprintf(w, " %2.d. %s\n", i+1, top[i].PlayerName)
-	}
-	fmt.Fprint(w, "```\n")
+	fmt.Fprintf(w, "Top players for league `%s`:\n", shortcode)
+	back.WriteTop20(w, top)
 
 	if len(around) > 0 {
 		fmt.Fprint(w, "Players around you:\n```\n")
+		var provisional bool
 		for i := range around {
-			fmt.Fprintf(w, "  - %s\n", around[i].PlayerName)
+			if around[i].Provisional {
+				provisional = true
+				fmt.Fprintf(w, "  - %s (provisional)\n", around[i].PlayerName)
+			} else {
+				fmt.Fprintf(w, "  - %s\n", around[i].PlayerName)
+			}
 		}
 		fmt.Fprint(w, "```")
+		if provisional {
+			fmt.Fprint(w, "Provisional players need more races before appearing in the leaderboard.")
+		}
+	}
+
+	return nil
+}
+
+// cmdHeadToHead handles "!h2h NAME".
+func (bot *Bot) cmdHeadToHead(m *discordgo.Message, args []string, w io.Writer) error {
+	name := argsAsName(args)
+	if name == "" {
+		return util.ErrPublic("usage: `!h2h NAME`")
 	}
 
+	h2h, err := bot.back.GetHeadToHeadForDiscordUser(m.Author.ID, name)
+	if err != nil {
+		return err
+	}
+
+	opponent := h2h.Players[1].Name
+	if len(h2h.Leagues) == 0 {
+		fmt.Fprintf(w, "You never raced against `%s`.", opponent)
+		return nil
+	}
+
+	for _, v := range h2h.Leagues {
+		fmt.Fprintf(
+			w, "**%s**: %d win(s), %d loss(es), %d draw(s) against `%s`.\n",
+			v.League.Name, v.Wins[0], v.Wins[1], v.Draws, opponent,
+		)
+		if v.TimedMatches > 0 {
+			fmt.Fprintf(
+				w, "On average you finished %s %s over %d race(s).\n",
+				durationAbs(v.AverageDelta), aheadOrBehind(v.AverageDelta), v.TimedMatches,
+			)
+		}
+		fmt.Fprintf(
+			w, "Your rating is %.0f (%+.0f in %d days), theirs is %.0f (%+.0f).\n",
+			v.Ratings[0].Rating, v.Trends[0], back.RatingTrendDays, v.Ratings[1].Rating, v.Trends[1],
+		)
+		fmt.Fprintf(w, "Your estimated chance of winning your next race is %.0f %%.\n\n", 100*v.Forecasts[0].WinProbability)
+	}
+
+	const maxMatches = 5
+	fmt.Fprint(w, "Last races:\n```\n")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for k, v := range h2h.Matches {
+		if k >= maxMatches {
+			break
+		}
+
+		result := "draw"
+		switch {
+		case v.Entries[0].HasWon():
+			result = "win"
+		case v.Entries[1].HasWon():
+			result = "loss"
+		}
+
+		fmt.Fprintf(
+			table, "%s\t%s\t%s\t%s\tvs\t%s\t\n",
+			v.Match.StartedAt.Time.Time().Format("2006-01-02"), v.League.ShortCode, result,
+			entryTime(v.Entries[0]), entryTime(v.Entries[1]),
+		)
+	}
+	table.Flush()
+	fmt.Fprint(w, "```")
+
+	return nil
+}
+
+func entryTime(e back.MatchEntry) string {
+	if d := e.Duration(); d > 0 {
+		return d.Round(time.Second).String()
+	}
+
+	return "forfeit"
+}
+
+func durationAbs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+func aheadOrBehind(d time.Duration) string {
+	if d < 0 {
+		return "ahead"
+	}
+
+	return "behind"
+}
+
+// webLoginTokenTTL is how long the link sent by !weblogin stays valid.
+const webLoginTokenTTL = 10 * time.Minute
+
+// cmdWebLogin handles "!weblogin", it sends a link that logs the player in on
+// the website. Commands are always answered in private, the link is never
+// sent on a public channel.
+func (bot *Bot) cmdWebLogin(m *discordgo.Message, _ []string, w io.Writer) error {
+	if len(bot.tokenKey) == 0 {
+		return util.ErrPublic("logging in on the website is disabled")
+	}
+
+	player, err := bot.back.GetPlayerByDiscordID(m.Author.ID)
+	if err != nil {
+		return err
+	}
+
+	// The website only accepts each nonce once, see back.ConsumeWebLogin.
+	expires := time.Now().Add(webLoginTokenTTL)
+	nonce, err := bot.back.CreateWebLogin(player.ID, expires)
+	if err != nil {
+		return err
+	}
+
+	token := util.SignToken(
+		bot.tokenKey, util.TokenPurposeWebLogin,
+		util.TokenSubject(player.ID, nonce), expires,
+	)
+
+	fmt.Fprintf(
+		w, "Open this link in the next %s to log in on the website as `%s`, it only works once, **do not share it**:\n"+
+			"<https://ootrladder.com/en/login?token=%s>",
+		util.FormatDuration(webLoginTokenTTL), player.Name, token,
+	)
+
 	return nil
 }
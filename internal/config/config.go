@@ -26,6 +26,18 @@ func NewFromUserConfigDir() (*Config, error) {
 	return c, nil
 }
 
+// IsDiscordAdmin returns true if the given Discord user ID is in
+// DiscordAdminUserIDs.
+func (c *Config) IsDiscordAdmin(discordID string) bool {
+	for _, v := range c.DiscordAdminUserIDs {
+		if discordID == v {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Config) ReloadFromUserConfigDir() error {
 	path, err := getOrCreateUserConfigPath()
 	if err != nil {
@@ -0,0 +1,186 @@
+package rating
+
+import (
+	"encoding/json"
+	"kaepora/internal/util"
+	"log"
+	"math"
+)
+
+const BayesianID = "bayesian"
+
+// Bayesian is a TrueSkill-like system restricted to 1v1: the skill of a
+// player is a gaussian (Mu, Sigma) updated after each match, in order.
+// Mu and Sigma are kept in the State and converted linearly to the common
+// Rating/Deviation scale.
+type Bayesian struct {
+	Mu, Sigma       float64 // initial skill
+	Beta            float64 // performance variance, skill difference giving ~76% chance to win
+	Tau             float64 // dynamic factor added to Sigma before each match
+	InactivityTau   float64 // dynamic factor added to Sigma for each inactive rating period
+	DrawProbability float64
+}
+
+type bayesianState struct {
+	Mu, Sigma float64
+}
+
+func NewBayesian() Bayesian {
+	return Bayesian{
+		Mu:              25,
+		Sigma:           25.0 / 3,
+		Beta:            25.0 / 6,
+		Tau:             25.0 / 300,
+		InactivityTau:   25.0 / 30,
+		DrawProbability: 0.01, // draws need a double forfeit
+	}
+}
+
+func (Bayesian) ID() string {
+	return BayesianID
+}
+
+// scale converts Mu and Sigma to Rating and Deviation.
+func (s Bayesian) scale() float64 {
+	return BaseDeviation / s.Sigma
+}
+
+func (s Bayesian) New() Rating {
+	var r Rating
+	s.setState(&r, bayesianState{Mu: s.Mu, Sigma: s.Sigma})
+	return r
+}
+
+// state returns the skill of a rating, ratings without a state (eg. created
+// by another system) are converted from their Rating and Deviation.
+func (s Bayesian) state(r Rating) bayesianState {
+	if len(r.State) > 0 {
+		var ret bayesianState
+		err := json.Unmarshal(r.State, &ret)
+		if err == nil {
+			return ret
+		}
+		log.Printf("warning: unable to decode Bayesian state: %s", err)
+	}
+
+	return bayesianState{
+		Mu:    s.Mu + (r.Rating-BaseRating)/s.scale(),
+		Sigma: math.Max(r.Deviation/s.scale(), 0.01),
+	}
+}
+
+func (s Bayesian) setState(r *Rating, state bayesianState) {
+	r.State, _ = json.Marshal(state) // can't fail
+	r.Rating = BaseRating + (state.Mu-s.Mu)*s.scale()
+	r.Deviation = state.Sigma * s.scale()
+}
+
+func (s Bayesian) ComputePeriod(ratings map[util.UUIDAsBlob]Rating, matches []Match) {
+	playersWithMatches(s, ratings, matches)
+
+	drawMargin := math.Sqrt2 * s.Beta * normInvCDF((s.DrawProbability+1)/2)
+
+	for _, v := range matches {
+		p1, p2 := ratings[v.P1], ratings[v.P2]
+		s1, s2 := s.state(p1), s.state(p2)
+
+		s1.Sigma = math.Sqrt(s1.Sigma*s1.Sigma + s.Tau*s.Tau)
+		s2.Sigma = math.Sqrt(s2.Sigma*s2.Sigma + s.Tau*s.Tau)
+
+		// Always see the match from the winner side, draws are symmetrical.
+		winner, loser := &s1, &s2
+		if v.Outcome == OutcomeLoss {
+			winner, loser = &s2, &s1
+		}
+
+		c := math.Sqrt(2*s.Beta*s.Beta + winner.Sigma*winner.Sigma + loser.Sigma*loser.Sigma)
+		t := (winner.Mu - loser.Mu) / c
+		e := drawMargin / c
+
+		var vt, wt float64
+		if v.Outcome == OutcomeDraw {
+			vt, wt = bayesianDrawVW(t, e)
+		} else {
+			vt, wt = bayesianWinVW(t, e)
+		}
+
+		ws2, ls2 := winner.Sigma*winner.Sigma, loser.Sigma*loser.Sigma
+		winner.Mu += ws2 / c * vt
+		loser.Mu -= ls2 / c * vt
+		winner.Sigma = math.Sqrt(ws2 * math.Max(1-ws2/(c*c)*wt, 0.0001))
+		loser.Sigma = math.Sqrt(ls2 * math.Max(1-ls2/(c*c)*wt, 0.0001))
+
+		s.setState(&p1, s1)
+		s.setState(&p2, s2)
+		ratings[v.P1], ratings[v.P2] = p1, p2
+	}
+}
+
+func (s Bayesian) Shrink(r Rating, factor, deviationBump float64) Rating {
+	r = shrink(r, factor, deviationBump)
+	r.State = nil // recomputed from Rating and Deviation
+	s.setState(&r, s.state(r))
+
+	return r
+}
+
+func (s Bayesian) WinProbability(p1, p2 Rating) float64 {
+	s1, s2 := s.state(p1), s.state(p2)
+	c := math.Sqrt(2*s.Beta*s.Beta + s1.Sigma*s1.Sigma + s2.Sigma*s2.Sigma)
+
+	return normCDF((s1.Mu - s2.Mu) / c)
+}
+
+func (s Bayesian) Inflate(r Rating, periods int) Rating {
+	if periods <= 0 {
+		return r
+	}
+
+	state := s.state(r)
+	state.Sigma = math.Min(
+		math.Sqrt(state.Sigma*state.Sigma+float64(periods)*s.InactivityTau*s.InactivityTau),
+		s.Sigma,
+	)
+	s.setState(&r, state)
+
+	return r
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normInvCDF(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// bayesianWinVW returns the additive and multiplicative correction factors
+// of a win with a t skill difference and a e draw margin.
+func bayesianWinVW(t, e float64) (float64, float64) {
+	x := t - e
+	cdf := normCDF(x)
+	if cdf < 1e-12 { // the loser was way better than the winner
+		return -x, 1
+	}
+
+	v := normPDF(x) / cdf
+	return v, v * (v + x)
+}
+
+// bayesianDrawVW returns the correction factors of a draw.
+func bayesianDrawVW(t, e float64) (float64, float64) {
+	a, b := -e-t, e-t
+	denominator := normCDF(b) - normCDF(a)
+	if denominator < 1e-12 {
+		return 0, 1
+	}
+
+	v := (normPDF(a) - normPDF(b)) / denominator
+	w := v*v + (b*normPDF(b)-a*normPDF(a))/denominator
+
+	return v, w
+}
@@ -0,0 +1,111 @@
+package rating
+
+import (
+	"encoding/json"
+	"kaepora/internal/util"
+	"log"
+	"math"
+)
+
+const EloID = "elo"
+
+// Elo is the classic Elo system with a higher K-factor for newcomers.
+// Elo has no notion of uncertainty, the Deviation is derived from the number
+// of races so that new players stay off the leaderboard for a few races.
+type Elo struct {
+	K, ProvisionalK  float64
+	ProvisionalGames int
+
+	// C is the deviation growth per inactive rating period, the deviation
+	// goes back to its value from the number of races on the next race.
+	C float64
+}
+
+type eloState struct {
+	Games int
+}
+
+func NewElo() Elo {
+	return Elo{K: 20, ProvisionalK: 40, ProvisionalGames: 20, C: 34.6}
+}
+
+func (Elo) ID() string {
+	return EloID
+}
+
+func (Elo) New() Rating {
+	return Rating{Rating: BaseRating, Deviation: BaseDeviation}
+}
+
+func (Elo) state(r Rating) eloState {
+	var ret eloState
+	if len(r.State) == 0 {
+		return ret
+	}
+
+	if err := json.Unmarshal(r.State, &ret); err != nil {
+		log.Printf("warning: unable to decode Elo state: %s", err)
+	}
+
+	return ret
+}
+
+func (Elo) setState(r *Rating, state eloState) {
+	r.State, _ = json.Marshal(state) // can't fail
+	r.Deviation = math.Max(BaseDeviation/math.Sqrt(1+float64(state.Games)), 50)
+}
+
+// ComputePeriod compares every player of the period to the ratings they had
+// at its start, as if all matches happened at the same time.
+func (s Elo) ComputePeriod(ratings map[util.UUIDAsBlob]Rating, matches []Match) {
+	players := playersWithMatches(s, ratings, matches)
+
+	pre := make(map[util.UUIDAsBlob]Rating, len(players))
+	for id := range players {
+		pre[id] = ratings[id]
+	}
+
+	for _, v := range matches {
+		p1, p2 := pre[v.P1], pre[v.P2]
+		expected := s.WinProbability(p1, p2)
+		score := v.Outcome.score()
+
+		s.apply(ratings, v.P1, score-expected)
+		s.apply(ratings, v.P2, expected-score)
+	}
+}
+
+func (s Elo) apply(ratings map[util.UUIDAsBlob]Rating, id util.UUIDAsBlob, delta float64) {
+	r := ratings[id]
+	state := s.state(r)
+
+	k := s.K
+	if state.Games < s.ProvisionalGames {
+		k = s.ProvisionalK
+	}
+
+	r.Rating += k * delta
+	state.Games++
+	s.setState(&r, state)
+	ratings[id] = r
+}
+
+func (Elo) WinProbability(p1, p2 Rating) float64 {
+	return 1 / (1 + math.Pow(10, (p2.Rating-p1.Rating)/400))
+}
+
+func (s Elo) Inflate(r Rating, periods int) Rating {
+	if periods <= 0 {
+		return r
+	}
+
+	r.Deviation = math.Min(math.Sqrt(r.Deviation*r.Deviation+float64(periods)*s.C*s.C), BaseDeviation)
+
+	return r
+}
+
+// Shrink keeps the number of races, the bumped deviation only lasts until the
+// next race.
+func (Elo) Shrink(r Rating, factor, deviationBump float64) Rating {
+	return shrink(r, factor, deviationBump)
+}
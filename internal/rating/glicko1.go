@@ -0,0 +1,104 @@
+package rating
+
+import (
+	"kaepora/internal/util"
+	"math"
+)
+
+const Glicko1ID = "glicko1"
+
+// Glicko1 is the original Glicko system, it is Glicko-2 without the
+// volatility: the deviation of active players grows by a constant amount at
+// the start of each period. The State is unused.
+type Glicko1 struct {
+	// C is the deviation growth per rating period, the default value brings
+	// a deviation of 50 back to 350 after 100 inactive periods.
+	C float64
+}
+
+func NewGlicko1() Glicko1 {
+	return Glicko1{C: 34.6}
+}
+
+func (Glicko1) ID() string {
+	return Glicko1ID
+}
+
+func (Glicko1) New() Rating {
+	return Rating{Rating: BaseRating, Deviation: BaseDeviation}
+}
+
+const glicko1Q = math.Ln10 / 400
+
+func glicko1G(rd float64) float64 {
+	return 1 / math.Sqrt(1+3*glicko1Q*glicko1Q*rd*rd/(math.Pi*math.Pi))
+}
+
+func glicko1E(r, rj, rdj float64) float64 {
+	return 1 / (1 + math.Pow(10, -glicko1G(rdj)*(r-rj)/400))
+}
+
+func (s Glicko1) ComputePeriod(ratings map[util.UUIDAsBlob]Rating, matches []Match) {
+	players := playersWithMatches(s, ratings, matches)
+
+	// Step 1: deviation at the start of the period.
+	pre := make(map[util.UUIDAsBlob]Rating, len(players))
+	for id := range players {
+		r := ratings[id]
+		r.Deviation = math.Max(1, math.Min(math.Sqrt(r.Deviation*r.Deviation+s.C*s.C), BaseDeviation))
+		pre[id] = r
+	}
+
+	type opponent struct {
+		r, rd, score float64
+	}
+	opponents := make(map[util.UUIDAsBlob][]opponent, len(players))
+	for _, v := range matches {
+		p1, p2 := pre[v.P1], pre[v.P2]
+		opponents[v.P1] = append(opponents[v.P1], opponent{p2.Rating, p2.Deviation, v.Outcome.score()})
+		opponents[v.P2] = append(opponents[v.P2], opponent{p1.Rating, p1.Deviation, 1 - v.Outcome.score()})
+	}
+
+	// Step 2: new rating and deviation.
+	for id, opps := range opponents {
+		r := pre[id]
+
+		var dInv, sum float64
+		for _, o := range opps {
+			g := glicko1G(o.rd)
+			e := glicko1E(r.Rating, o.r, o.rd)
+			dInv += g * g * e * (1 - e)
+			sum += g * (o.score - e)
+		}
+		dInv *= glicko1Q * glicko1Q
+
+		denominator := 1/(r.Deviation*r.Deviation) + dInv
+		r.Rating += glicko1Q / denominator * sum
+		r.Deviation = math.Sqrt(1 / denominator)
+		ratings[id] = r
+	}
+}
+
+func (Glicko1) WinProbability(p1, p2 Rating) float64 {
+	return glickoWinProbability(p1, p2)
+}
+
+// glickoWinProbability is the expected score of p1 using the deviations of
+// both players, as defined by Glickman for Glicko and Glicko-2.
+func glickoWinProbability(p1, p2 Rating) float64 {
+	return glicko1E(p1.Rating, p2.Rating, math.Sqrt(p1.Deviation*p1.Deviation+p2.Deviation*p2.Deviation))
+}
+
+func (s Glicko1) Inflate(r Rating, periods int) Rating {
+	if periods <= 0 {
+		return r
+	}
+
+	r.Deviation = math.Min(math.Sqrt(r.Deviation*r.Deviation+float64(periods)*s.C*s.C), BaseDeviation)
+
+	return r
+}
+
+func (Glicko1) Shrink(r Rating, factor, deviationBump float64) Rating {
+	return shrink(r, factor, deviationBump)
+}
@@ -0,0 +1,98 @@
+package rating
+
+import (
+	"kaepora/internal/util"
+
+	"math"
+
+	glicko "github.com/zelenin/go-glicko2"
+)
+
+const Glicko2ID = "glicko2"
+
+// Glicko2 is Mark Glickman's Glicko-2, the volatility is stored in the
+// Volatility field and the State is unused.
+type Glicko2 struct{}
+
+func NewGlicko2() Glicko2 {
+	return Glicko2{}
+}
+
+func (Glicko2) ID() string {
+	return Glicko2ID
+}
+
+func (Glicko2) New() Rating {
+	return Rating{
+		Rating:     glicko.RATING_BASE_R,
+		Deviation:  glicko.RATING_BASE_RD,
+		Volatility: glicko.RATING_BASE_SIGMA,
+	}
+}
+
+func (s Glicko2) ComputePeriod(ratings map[util.UUIDAsBlob]Rating, matches []Match) {
+	playersWithMatches(s, ratings, matches)
+
+	players := make(map[util.UUIDAsBlob]*glicko.Player, len(ratings))
+	getPlayer := func(id util.UUIDAsBlob) *glicko.Player {
+		p, ok := players[id]
+		if !ok {
+			r := ratings[id]
+			if r.Volatility == 0 { // rating coming from another system
+				r.Volatility = glicko.RATING_BASE_SIGMA
+			}
+			p = glicko.NewPlayer(glicko.NewRating(r.Rating, r.Deviation, r.Volatility))
+			players[id] = p
+		}
+		return p
+	}
+
+	period := glicko.NewRatingPeriod()
+	for _, v := range matches {
+		p1, p2 := getPlayer(v.P1), getPlayer(v.P2)
+
+		switch v.Outcome {
+		case OutcomeWin:
+			period.AddMatch(p1, p2, glicko.MATCH_RESULT_WIN)
+		case OutcomeDraw:
+			period.AddMatch(p1, p2, glicko.MATCH_RESULT_DRAW)
+		case OutcomeLoss:
+			period.AddMatch(p1, p2, glicko.MATCH_RESULT_LOSS)
+		}
+	}
+	period.Calculate()
+
+	for id, p := range players {
+		r := p.Rating()
+		ratings[id] = Rating{Rating: r.R(), Deviation: r.Rd(), Volatility: r.Sigma()}
+	}
+}
+
+func (Glicko2) WinProbability(p1, p2 Rating) float64 {
+	return glickoWinProbability(p1, p2)
+}
+
+// glicko2Scale converts deviations to the Glicko-2 scale.
+const glicko2Scale = 173.7178
+
+// Inflate applies the pre-period step of Glicko-2 once per inactive period.
+func (Glicko2) Inflate(r Rating, periods int) Rating {
+	if periods <= 0 {
+		return r
+	}
+
+	sigma := r.Volatility
+	if sigma == 0 {
+		sigma = glicko.RATING_BASE_SIGMA
+	}
+
+	phi := r.Deviation / glicko2Scale
+	phi = math.Sqrt(phi*phi + float64(periods)*sigma*sigma)
+	r.Deviation = math.Min(phi*glicko2Scale, BaseDeviation)
+
+	return r
+}
+
+func (Glicko2) Shrink(r Rating, factor, deviationBump float64) Rating {
+	return shrink(r, factor, deviationBump)
+}
@@ -0,0 +1,154 @@
+package rating
+
+import (
+	"fmt"
+	"kaepora/internal/util"
+	"math"
+)
+
+// All systems share the same scale for Rating and Deviation so that
+// leaderboards and deviation thresholds keep their meaning when a league
+// changes its system.
+const (
+	BaseRating    = 1500
+	BaseDeviation = 350
+)
+
+// Rating is the skill estimation of a single player.
+type Rating struct {
+	Rating    float64
+	Deviation float64
+
+	Volatility float64 // Glicko-2 only
+	State      []byte  // system-specific, opaque outside of the System
+}
+
+type Outcome int
+
+const (
+	OutcomeLoss Outcome = -1
+	OutcomeDraw Outcome = 0
+	OutcomeWin  Outcome = 1
+)
+
+// Match is the result of a 1v1 race, Outcome is seen from P1.
+type Match struct {
+	P1, P2  util.UUIDAsBlob
+	Outcome Outcome
+}
+
+// A System computes ratings from match results.
+type System interface {
+	// ID is the name of the system as stored in League.RatingSystem.
+	ID() string
+
+	// New returns the rating of a player that never raced.
+	New() Rating
+
+	// ComputePeriod updates the given ratings, indexed by player ID, with the
+	// results of a rating period. Players that never raced before are added
+	// with the New rating, players without matches are left untouched.
+	ComputePeriod(ratings map[util.UUIDAsBlob]Rating, matches []Match)
+
+	// Shrink moves a rating toward the New rating, keeping factor (0 to 1)
+	// of the distance between the two and adding deviationBump to its
+	// deviation.
+	Shrink(r Rating, factor, deviationBump float64) Rating
+
+	// Inflate returns the rating of a player after the given number of rating
+	// periods without any match, its deviation grows up to BaseDeviation.
+	Inflate(r Rating, periods int) Rating
+
+	// WinProbability returns the probability of a player rated p1 beating a
+	// player rated p2, draws count as half a win.
+	WinProbability(p1, p2 Rating) float64
+}
+
+// Forecast is the expected outcome of a match as seen from one of its
+// players.
+type Forecast struct {
+	WinProbability      float64
+	WinDelta, LossDelta float64 // rating change on a win or a loss
+}
+
+// Predict returns the forecast of a match between p1 and p2 for p1, deltas
+// are computed as if the match was the only one of the rating period.
+func Predict(system System, p1, p2 Rating) Forecast {
+	var id1, id2 util.UUIDAsBlob
+	id2[0] = 1
+
+	delta := func(outcome Outcome) float64 {
+		ratings := map[util.UUIDAsBlob]Rating{id1: p1, id2: p2}
+		system.ComputePeriod(ratings, []Match{{P1: id1, P2: id2, Outcome: outcome}})
+		return ratings[id1].Rating - p1.Rating
+	}
+
+	return Forecast{
+		WinProbability: system.WinProbability(p1, p2),
+		WinDelta:       delta(OutcomeWin),
+		LossDelta:      delta(OutcomeLoss),
+	}
+}
+
+const DefaultSystem = Glicko2ID
+
+// SystemIDs lists every available system, default first.
+var SystemIDs = []string{Glicko2ID, Glicko1ID, EloID, BayesianID}
+
+// New returns the System with the given ID, an empty ID gives the default
+// system.
+func New(id string) (System, error) {
+	switch id {
+	case "", Glicko2ID:
+		return NewGlicko2(), nil
+	case Glicko1ID:
+		return NewGlicko1(), nil
+	case EloID:
+		return NewElo(), nil
+	case BayesianID:
+		return NewBayesian(), nil
+	default:
+		return nil, fmt.Errorf("unknown rating system: %s", id)
+	}
+}
+
+// shrink implements the common part of System.Shrink on Rating and
+// Deviation, systems still have to update their State.
+func shrink(r Rating, factor, deviationBump float64) Rating {
+	r.Rating = BaseRating + (r.Rating-BaseRating)*factor
+	r.Deviation = math.Min(r.Deviation+deviationBump, BaseDeviation)
+
+	return r
+}
+
+// playersWithMatches returns the ID of every player of the given matches,
+// adding the missing ones to ratings.
+func playersWithMatches(
+	system System,
+	ratings map[util.UUIDAsBlob]Rating,
+	matches []Match,
+) map[util.UUIDAsBlob]struct{} {
+	ret := make(map[util.UUIDAsBlob]struct{}, 2*len(matches))
+	for _, v := range matches {
+		for _, id := range []util.UUIDAsBlob{v.P1, v.P2} {
+			ret[id] = struct{}{}
+			if _, ok := ratings[id]; !ok {
+				ratings[id] = system.New()
+			}
+		}
+	}
+
+	return ret
+}
+
+// score returns the score of P1 in a match.
+func (o Outcome) score() float64 {
+	switch o {
+	case OutcomeWin:
+		return 1
+	case OutcomeLoss:
+		return 0
+	default:
+		return 0.5
+	}
+}
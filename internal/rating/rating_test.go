@@ -0,0 +1,105 @@
+package rating
+
+import (
+	"kaepora/internal/util"
+	"math"
+	"testing"
+)
+
+func id(b byte) util.UUIDAsBlob {
+	var ret util.UUIDAsBlob
+	ret[0] = b
+	return ret
+}
+
+// Example from Glickman's "The Glicko system" paper.
+func TestGlicko1(t *testing.T) {
+	ratings := map[util.UUIDAsBlob]Rating{
+		id(0): {Rating: 1500, Deviation: 200},
+		id(1): {Rating: 1400, Deviation: 30},
+		id(2): {Rating: 1550, Deviation: 100},
+		id(3): {Rating: 1700, Deviation: 300},
+	}
+	matches := []Match{
+		{P1: id(0), P2: id(1), Outcome: OutcomeWin},
+		{P1: id(0), P2: id(2), Outcome: OutcomeLoss},
+		{P1: id(3), P2: id(0), Outcome: OutcomeWin},
+	}
+
+	Glicko1{C: 0}.ComputePeriod(ratings, matches)
+
+	actual := ratings[id(0)]
+	if math.Abs(actual.Rating-1464) > 1 || math.Abs(actual.Deviation-151.4) > 0.5 {
+		t.Errorf("expected 1464/151.4, got %.1f/%.1f", actual.Rating, actual.Deviation)
+	}
+}
+
+func TestSystems(t *testing.T) {
+	for _, systemID := range SystemIDs {
+		system, err := New(systemID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if system.ID() != systemID {
+			t.Errorf("%s: expected ID %s, got %s", systemID, systemID, system.ID())
+		}
+
+		ratings := map[util.UUIDAsBlob]Rating{id(3): system.New()}
+		system.ComputePeriod(ratings, []Match{
+			{P1: id(0), P2: id(1), Outcome: OutcomeWin},
+			{P1: id(2), P2: id(0), Outcome: OutcomeLoss},
+			{P1: id(1), P2: id(2), Outcome: OutcomeDraw},
+		})
+
+		if len(ratings) != 4 {
+			t.Fatalf("%s: expected 4 ratings, got %d", systemID, len(ratings))
+		}
+		if ratings[id(3)].Rating != BaseRating || ratings[id(3)].Deviation != BaseDeviation {
+			t.Errorf("%s: a player without matches was updated", systemID)
+		}
+		if !(ratings[id(0)].Rating > BaseRating) || !(ratings[id(1)].Rating < BaseRating) {
+			t.Errorf("%s: expected the winner to gain rating and the losers to lose some", systemID)
+		}
+		if !(ratings[id(0)].Deviation < BaseDeviation) {
+			t.Errorf("%s: expected the deviation to decrease after races", systemID)
+		}
+
+		// Updating the ratings again must use the serialized state.
+		before := ratings[id(0)].Rating
+		system.ComputePeriod(ratings, []Match{{P1: id(0), P2: id(1), Outcome: OutcomeWin}})
+		if !(ratings[id(0)].Rating > before) {
+			t.Errorf("%s: expected the winner to gain rating in the second period", systemID)
+		}
+
+		shrunk := system.Shrink(ratings[id(0)], 0.5, 50)
+		if math.Abs(shrunk.Rating-(BaseRating+(ratings[id(0)].Rating-BaseRating)/2)) > 0.001 {
+			t.Errorf("%s: expected the rating to be halfway to the base rating, got %.1f", systemID, shrunk.Rating)
+		}
+
+		active := ratings[id(0)]
+		once, many := system.Inflate(active, 1), system.Inflate(active, 5)
+		if !(once.Deviation > active.Deviation) || !(many.Deviation > once.Deviation) {
+			t.Errorf("%s: expected the deviation to grow with inactivity", systemID)
+		}
+		if math.Abs(once.Rating-active.Rating) > 0.001 {
+			t.Errorf("%s: expected inactivity to keep the rating", systemID)
+		}
+		if v := system.Inflate(active, 10000).Deviation; math.Abs(v-BaseDeviation) > 0.001 {
+			t.Errorf("%s: expected the deviation to be capped to %d, got %.1f", systemID, BaseDeviation, v)
+		}
+		if system.Inflate(active, 0).Deviation != active.Deviation {
+			t.Errorf("%s: expected no change without inactive periods", systemID)
+		}
+
+		if p := system.WinProbability(system.New(), system.New()); math.Abs(p-0.5) > 0.001 {
+			t.Errorf("%s: expected even odds between new players, got %.3f", systemID, p)
+		}
+		forecast := Predict(system, ratings[id(0)], ratings[id(1)])
+		if !(forecast.WinProbability > 0.5) || !(forecast.WinDelta > 0) || !(forecast.LossDelta < 0) {
+			t.Errorf("%s: unexpected forecast for the best player: %+v", systemID, forecast)
+		}
+		if !(-forecast.LossDelta > forecast.WinDelta) {
+			t.Errorf("%s: expected the favorite to lose more on a loss than it gains on a win: %+v", systemID, forecast)
+		}
+	}
+}
@@ -55,6 +55,13 @@ type NullUUIDAsBlob struct {
 	Valid bool // Valid is true if UUIDAsBlob is not NULL
 }
 
+func NewNullUUIDAsBlob(id UUIDAsBlob) NullUUIDAsBlob {
+	return NullUUIDAsBlob{
+		UUID:  id,
+		Valid: !id.IsZero(),
+	}
+}
+
 // Scan implements the Scanner interface.
 func (ns *NullUUIDAsBlob) Scan(value interface{}) error {
 	if value == nil {
@@ -73,7 +80,7 @@ func (ns NullUUIDAsBlob) Value() (driver.Value, error) {
 		return nil, nil
 	}
 
-	return ns.UUID, nil
+	return ns.UUID.Value()
 }
 
 func (ns NullUUIDAsBlob) MarshalJSON() ([]byte, error) {
@@ -40,3 +40,19 @@ func Datetime(iface interface{}) string {
 
 	return t.Format("2006-01-02 15h04 MST")
 }
+
+// ParseDatetime parses a "2006-01-02" date and a "15:04" time given by an
+// user in the given IANA time zone. Errors are safe to show to the user.
+func ParseDatetime(date, clock, zone string) (time.Time, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil || zone == "" || zone == "Local" {
+		return time.Time{}, ErrPublic(fmt.Sprintf("unknown time zone '%s', use a name like 'Europe/Paris' or 'UTC'", zone))
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, loc)
+	if err != nil {
+		return time.Time{}, ErrPublic(fmt.Sprintf("invalid date '%s %s', use the YYYY-MM-DD HH:MM format", date, clock))
+	}
+
+	return t, nil
+}
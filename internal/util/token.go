@@ -0,0 +1,99 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of the tokens signed with SignToken, a token signed for a purpose
+// is invalid for any other.
+const (
+	TokenPurposeWebLogin   = "weblogin"
+	TokenPurposeWebSession = "websession"
+	TokenPurposeWebCSRF    = "webcsrf"
+)
+
+// ErrInvalidToken is returned by VerifyToken for expired, malformed, or
+// forged tokens.
+var ErrInvalidToken = errors.New("invalid token")
+
+// SignToken returns an URL-safe token carrying the given subject for the
+// given purpose until the expiration date, authenticated with HMAC-SHA256.
+func SignToken(key []byte, purpose, subject string, expires time.Time) string {
+	payload := subject + "|" + strconv.FormatInt(expires.Unix(), 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(tokenMAC(key, purpose, payload))
+}
+
+// VerifyToken returns the subject of a token created by SignToken if it was
+// signed with the same key and purpose and has not expired.
+func VerifyToken(key []byte, purpose, token string, now time.Time) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("empty token key")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal(mac, tokenMAC(key, purpose, string(payload))) {
+		return "", ErrInvalidToken
+	}
+
+	sep := strings.LastIndexByte(string(payload), '|')
+	if sep < 0 {
+		return "", ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(string(payload[sep+1:]), 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return "", ErrInvalidToken
+	}
+
+	return string(payload[:sep]), nil
+}
+
+// TokenSubject returns the subject of login and session tokens: the ID of
+// the player and a login nonce or a session version.
+func TokenSubject(playerID UUIDAsBlob, extra string) string {
+	return playerID.String() + "|" + extra
+}
+
+// ParseTokenSubject returns the player ID and the extra data of a subject
+// created by TokenSubject.
+func ParseTokenSubject(subject string) (UUIDAsBlob, string, error) {
+	parts := strings.SplitN(subject, "|", 2)
+	if len(parts) != 2 {
+		return UUIDAsBlob{}, "", ErrInvalidToken
+	}
+
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return UUIDAsBlob{}, "", ErrInvalidToken
+	}
+
+	return UUIDAsBlob(id), parts[1], nil
+}
+
+func tokenMAC(key []byte, purpose, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose + "\x00" + payload)) // nolint:errcheck, never fails
+
+	return h.Sum(nil)
+}
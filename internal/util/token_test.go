@@ -0,0 +1,65 @@
+package util_test
+
+import (
+	"kaepora/internal/util"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	key := []byte("secret")
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := util.SignToken(key, util.TokenPurposeWebLogin, "some|subject", now.Add(time.Minute))
+
+	subject, err := util.VerifyToken(key, util.TokenPurposeWebLogin, token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "some|subject" {
+		t.Errorf("expected subject %q, got %q", "some|subject", subject)
+	}
+
+	invalid := map[string]func() (string, error){
+		"expired": func() (string, error) {
+			return util.VerifyToken(key, util.TokenPurposeWebLogin, token, now.Add(time.Minute))
+		},
+		"other purpose": func() (string, error) {
+			return util.VerifyToken(key, util.TokenPurposeWebSession, token, now)
+		},
+		"other key": func() (string, error) {
+			return util.VerifyToken([]byte("other"), util.TokenPurposeWebLogin, token, now)
+		},
+		"empty key": func() (string, error) {
+			return util.VerifyToken(nil, util.TokenPurposeWebLogin, token, now)
+		},
+		"tampered": func() (string, error) {
+			return util.VerifyToken(key, util.TokenPurposeWebLogin, "x"+token, now)
+		},
+		"malformed": func() (string, error) {
+			return util.VerifyToken(key, util.TokenPurposeWebLogin, "garbage", now)
+		},
+	}
+
+	for name, verify := range invalid {
+		if subject, err := verify(); err == nil {
+			t.Errorf("%s: expected an error, got subject %q", name, subject)
+		}
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	id := util.NewUUIDAsBlob()
+	playerID, extra, err := util.ParseTokenSubject(util.TokenSubject(id, "nonce|with|pipes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playerID != id || extra != "nonce|with|pipes" {
+		t.Errorf("unexpected subject: %s %q", playerID, extra)
+	}
+
+	for _, v := range []string{"", id.String(), "garbage|nonce"} {
+		if _, _, err := util.ParseTokenSubject(v); err == nil {
+			t.Errorf("expected an error for subject %q", v)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	sessionCookieName = "session"
+	sessionDuration   = 30 * 24 * time.Hour
+	csrfFormField     = "csrf"
+	csrfTokenDuration = 24 * time.Hour
+)
+
+// loginPayload is shown by login.html, Token is set when the player has to
+// confirm the login.
+type loginPayload struct {
+	Invalid bool
+	Token   string
+	Player  back.Player
+}
+
+// login asks to confirm a login token sent by the bot (see "!weblogin"),
+// without token it tells how to get one. Tokens are only used up by
+// postLogin so link previews cannot log in in place of the player.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	w.Header().Set("Cache-Control", "private,no-store")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		if _, ok := r.Context().Value(ctxKeyPlayer).(back.Player); ok {
+			http.Redirect(w, r, "/"+locale+"/account", http.StatusSeeOther)
+			return
+		}
+
+		s.response(w, r, http.StatusOK, "login.html", loginPayload{})
+		return
+	}
+
+	player, _, err := s.getTokenPlayer(util.TokenPurposeWebLogin, token)
+	if err != nil {
+		log.Printf("warning: invalid login token: %s", err)
+		s.response(w, r, http.StatusUnauthorized, "login.html", loginPayload{Invalid: true})
+		return
+	}
+
+	s.response(w, r, http.StatusOK, "login.html", loginPayload{Token: token, Player: player})
+}
+
+// postLogin trades a login token confirmed on the login page for a session
+// cookie.
+func (s *Server) postLogin(w http.ResponseWriter, r *http.Request) {
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	w.Header().Set("Cache-Control", "private,no-store")
+
+	player, nonce, err := s.getTokenPlayer(util.TokenPurposeWebLogin, r.PostFormValue("token"))
+	if err == nil {
+		err = s.back.ConsumeWebLogin(player.ID, nonce)
+	}
+	if err != nil {
+		log.Printf("warning: login failed: %s", err)
+		s.response(w, r, http.StatusUnauthorized, "login.html", loginPayload{Invalid: true})
+		return
+	}
+
+	version, err := s.back.GetWebSessionVersion(player.ID)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	expires := time.Now().Add(sessionDuration)
+	session := util.SignToken(
+		s.tokenKey, util.TokenPurposeWebSession,
+		util.TokenSubject(player.ID, strconv.Itoa(version)), expires,
+	)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Printf("info: player %s logged in on the website", player.ID)
+	http.Redirect(w, r, "/"+locale+"/account", http.StatusSeeOther)
+}
+
+// logout revokes every session of the player and removes the session
+// cookie.
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	if err := s.back.RevokeWebSessions(player.ID); err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("info: player %s logged out of the website", player.ID)
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/"+r.Context().Value(ctxKeyLocale).(string), http.StatusSeeOther)
+}
+
+// account shows the current race of the logged in player and the actions
+// available to them.
+func (s *Server) account(w http.ResponseWriter, r *http.Request) {
+	s.accountResponse(w, r, http.StatusOK, nil)
+}
+
+// Messages shown after a successful accountAction, indexed by the "ok"
+// query parameter.
+var accountActionMessages = map[string]string{
+	"joined":     "You have been registered for the race, please ensure you have read the rules.",
+	"waitlisted": "The race is full, you have been put on the waitlist. You will be notified if a spot frees up.",
+	"cancelled":  "You have cancelled your participation, this will not affect your rankings.",
+	"completed":  "You have completed your race! The results will be shown when your opponent ends their race.",
+	"forfeited":  "You have forfeited your race.",
+	"voted":      "Your vote has been saved.",
+}
+
+// accountAction returns a handler that runs a race action for the logged in
+// player, like the bot commands. On success it redirects to the account page
+// with the given accountActionMessages key, unless the action returns its
+// own key.
+func (s *Server) accountAction(ok string, action func(*http.Request, back.Player) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		player := r.Context().Value(ctxKeyPlayer).(back.Player)
+		message, err := action(r, player)
+		if err != nil {
+			s.accountResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if message == "" {
+			message = ok
+		}
+
+		locale := r.Context().Value(ctxKeyLocale).(string)
+		http.Redirect(w, r, "/"+locale+"/account?ok="+message, http.StatusSeeOther)
+	}
+}
+
+func (s *Server) accountJoin(r *http.Request, player back.Player) (string, error) {
+	session, _, err := s.back.JoinCurrentMatchSessionByShortcode(player, r.PostFormValue("shortcode"))
+	if err != nil {
+		return "", err
+	}
+
+	if session.WaitlistPosition(player.ID.UUID()) > 0 {
+		return "waitlisted", nil
+	}
+
+	return "", nil
+}
+
+func (s *Server) accountCancel(_ *http.Request, player back.Player) (string, error) {
+	_, err := s.back.CancelActiveMatchSession(player)
+	return "", err
+}
+
+func (s *Server) accountComplete(_ *http.Request, player back.Player) (string, error) {
+	_, err := s.back.CompleteActiveMatch(player)
+	return "", err
+}
+
+func (s *Server) accountForfeit(_ *http.Request, player back.Player) (string, error) {
+	_, err := s.back.ForfeitActiveMatch(player)
+	return "", err
+}
+
+func (s *Server) accountVote(r *http.Request, player back.Player) (string, error) {
+	id, err := uuid.Parse(r.PostFormValue("slot"))
+	if err != nil {
+		return "", util.ErrPublic("unknown poll date")
+	}
+
+	_, err = s.back.VotePoll(player, util.UUIDAsBlob(id), r.PostFormValue("vote") == "1")
+	return "", err
+}
+
+func (s *Server) accountResponse(w http.ResponseWriter, r *http.Request, code int, err error) {
+	public, err := publicError(err)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	race, err := s.back.GetPlayerRace(player.ID)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	recent, err := s.back.GetPlayerRecentRaces(player.ID)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	next, err := s.back.GetNextMatchSessions()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	polls, pollLeagues, err := s.back.GetOpenPolls()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.response(w, r, code, "account.html", struct {
+		Player      back.Player
+		Race        back.PlayerRace
+		Recent      []back.PlayerRace
+		Next        map[util.UUIDAsBlob]back.MatchSession
+		Polls       []back.Poll
+		PollLeagues map[util.UUIDAsBlob]back.League
+		Error       string
+		Message     string
+		CSRF        string
+	}{
+		Player:      player,
+		Race:        race,
+		Recent:      recent,
+		Next:        next,
+		Polls:       polls,
+		PollLeagues: pollLeagues,
+		Error:       public,
+		Message:     accountActionMessages[r.URL.Query().Get("ok")],
+		CSRF:        s.csrfToken(player),
+	})
+}
+
+// accountSeed sends the seed of a match of the logged in player, either by
+// redirecting to the generator or as a patch file.
+func (s *Server) accountSeed(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	match, err := s.back.GetPlayerRaceSeed(player.ID, id)
+	if err != nil {
+		s.accountResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if url := s.tplMatchSeedURL(match); url != "#" {
+		http.Redirect(w, r, url, http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zlib")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		`attachment; filename="seed_%s.zpf"`,
+		match.CreatedAt.Time().Format("2006-01-02_15h04"),
+	))
+	if _, err := w.Write(match.SeedPatch); err != nil {
+		log.Printf("warning: %s", err)
+	}
+}
+
+// accountSpoilerLog sends the spoiler log of a match of the logged in player
+// once they ended their race.
+func (s *Server) accountSpoilerLog(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	match, err := s.back.GetPlayerRaceSpoilerLog(player.ID, id)
+	if err != nil {
+		s.accountResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	league, err := s.back.GetLeague(match.LeagueID)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	raw, err := ioutil.ReadAll(match.SpoilerLog.Uncompressed())
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.sendRawSpoilerLog(w, league, match, raw)
+}
+
+// accountSettings shows the form to change the name and stream URL of the
+// logged in player.
+func (s *Server) accountSettings(w http.ResponseWriter, r *http.Request) {
+	s.accountSettingsResponse(w, r, http.StatusOK, nil, false)
+}
+
+// postAccountSettings updates the name and stream URL of the logged in player.
+func (s *Server) postAccountSettings(w http.ResponseWriter, r *http.Request) {
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	streamURL := strings.TrimSpace(r.PostFormValue("stream"))
+
+	if name != player.Name {
+		if err := s.back.UpdatePlayerName(player.ID, name); err != nil {
+			s.accountSettingsResponse(w, r, http.StatusBadRequest, err, false)
+			return
+		}
+	}
+
+	if streamURL != player.StreamURL {
+		if _, err := s.back.UpdatePlayerStreamURL(player.ID, streamURL); err != nil {
+			s.accountSettingsResponse(w, r, http.StatusBadRequest, err, false)
+			return
+		}
+	}
+
+	s.accountSettingsResponse(w, r, http.StatusOK, nil, true)
+}
+
+func (s *Server) accountSettingsResponse(
+	w http.ResponseWriter, r *http.Request,
+	code int, err error, saved bool,
+) {
+	// Reload the player to show what was actually saved.
+	player, getErr := s.back.GetPlayerByID(r.Context().Value(ctxKeyPlayer).(back.Player).ID)
+	if getErr != nil {
+		s.error(w, r, getErr, http.StatusInternalServerError)
+		return
+	}
+
+	public, err := publicError(err)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.response(w, r, code, "account_settings.html", struct {
+		Player back.Player
+		Error  string
+		Saved  bool
+		CSRF   string
+	}{player, public, saved, s.csrfToken(player)})
+}
+
+// publicError returns the message of an util.ErrPublic to show to the
+// player, other errors are returned as is.
+func publicError(err error) (string, error) {
+	if err == nil {
+		return "", nil
+	}
+
+	if !errors.Is(err, util.ErrPublic("")) {
+		return "", err
+	}
+
+	return err.Error(), nil
+}
+
+// authenticate puts the logged in back.Player in the request context, if any.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		player, version, err := s.getTokenPlayer(util.TokenPurposeWebSession, cookie.Value)
+		if err == nil {
+			err = s.checkWebSessionVersion(player, version)
+		}
+		if err != nil {
+			log.Printf("warning: invalid session: %s", err)
+			clearSessionCookie(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ctxKeyPlayer, player)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requirePlayer redirects to the login page when nobody is logged in, pages
+// behind it are personal and must never be cached.
+func (s *Server) requirePlayer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(ctxKeyPlayer).(back.Player); !ok {
+			http.Redirect(w, r, "/"+r.Context().Value(ctxKeyLocale).(string)+"/login", http.StatusSeeOther)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private,no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkCSRF rejects POST requests without the CSRF token given by csrfToken.
+// Requires requirePlayer.
+func (s *Server) checkCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		player := r.Context().Value(ctxKeyPlayer).(back.Player)
+		subject, err := util.VerifyToken(
+			s.tokenKey, util.TokenPurposeWebCSRF,
+			r.PostFormValue(csrfFormField), time.Now(),
+		)
+		if err != nil || subject != player.ID.String() {
+			s.error(w, r, errors.New("invalid CSRF token"), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfToken returns the token to put in the csrfFormField of every form
+// posted by the given player.
+func (s *Server) csrfToken(player back.Player) string {
+	return util.SignToken(
+		s.tokenKey, util.TokenPurposeWebCSRF,
+		player.ID.String(), time.Now().Add(csrfTokenDuration),
+	)
+}
+
+// getTokenPlayer returns the player of a token subject created by
+// util.TokenSubject and the extra data that comes with it.
+func (s *Server) getTokenPlayer(purpose, token string) (back.Player, string, error) {
+	subject, err := util.VerifyToken(s.tokenKey, purpose, token, time.Now())
+	if err != nil {
+		return back.Player{}, "", err
+	}
+
+	id, extra, err := util.ParseTokenSubject(subject)
+	if err != nil {
+		return back.Player{}, "", err
+	}
+
+	player, err := s.back.GetPlayerByID(id)
+	if err != nil {
+		return back.Player{}, "", err
+	}
+
+	return player, extra, nil
+}
+
+// checkWebSessionVersion returns an error if the session was revoked.
+func (s *Server) checkWebSessionVersion(player back.Player, version string) error {
+	current, err := s.back.GetWebSessionVersion(player.ID)
+	if err != nil {
+		return err
+	}
+
+	if version != strconv.Itoa(current) {
+		return fmt.Errorf("revoked session of player %s", player.ID)
+	}
+
+	return nil
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
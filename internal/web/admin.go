@@ -0,0 +1,587 @@
+package web
+
+import (
+	"errors"
+	"kaepora/internal/back"
+	"kaepora/internal/generator/oot"
+	"kaepora/internal/util"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// Messages shown on the admin pages after a successful action, indexed by
+// the "ok" query parameter.
+var adminActionMessages = map[string]string{
+	"game":   "The game has been saved.",
+	"league": "The league has been saved.",
+	"race":   "The race has been saved.",
+	"reseed": "The seeds are being regenerated and will be sent to the players once ready.",
+}
+
+// adminSessionsDays is how far back and ahead the admin sessions page goes.
+const adminSessionsDays = 7
+
+// requireAdmin restricts the routes behind it to the players whose Discord
+// account is one of the configured admins.
+// Requires requirePlayer.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		player := r.Context().Value(ctxKeyPlayer).(back.Player)
+		if !player.DiscordID.Valid || !s.config.IsDiscordAdmin(player.DiscordID.String) {
+			s.error(w, r, errors.New("admin page requested by a non-admin"), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admin lists the games and leagues.
+func (s *Server) admin(w http.ResponseWriter, r *http.Request) {
+	s.adminResponse(w, r, http.StatusOK, nil)
+}
+
+func (s *Server) adminResponse(w http.ResponseWriter, r *http.Request, code int, err error) {
+	public, err := publicError(err)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	games, err := s.back.GetGames()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	leagues, err := s.back.GetLeagues()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	gameNames := make(map[util.UUIDAsBlob]string, len(games))
+	for _, v := range games {
+		gameNames[v.ID] = v.Name
+	}
+
+	// An invalid schedule must not prevent fixing it from the admin.
+	next := make(map[util.UUIDAsBlob]time.Time, len(leagues))
+	scheduleErrors := map[util.UUIDAsBlob]string{}
+	for _, v := range leagues {
+		if next[v.ID], err = v.Schedule.Next(); err != nil {
+			scheduleErrors[v.ID] = err.Error()
+		}
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	s.response(w, r, code, "admin.html", struct {
+		Games          []back.Game
+		GameNames      map[util.UUIDAsBlob]string
+		Leagues        []back.League
+		Next           map[util.UUIDAsBlob]time.Time
+		ScheduleErrors map[util.UUIDAsBlob]string
+		Error          string
+		Message        string
+		CSRF           string
+	}{
+		Games:          games,
+		GameNames:      gameNames,
+		Leagues:        leagues,
+		Next:           next,
+		ScheduleErrors: scheduleErrors,
+		Error:          public,
+		Message:        adminActionMessages[r.URL.Query().Get("ok")],
+		CSRF:           s.csrfToken(player),
+	})
+}
+
+func (s *Server) postAdminGame(w http.ResponseWriter, r *http.Request) {
+	_, err := s.back.CreateGame(r.PostFormValue("name"))
+	if err != nil {
+		s.adminResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	s.redirectAdmin(w, r, "game")
+}
+
+func (s *Server) postAdminGameRename(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	if err := s.back.RenameGame(id, r.PostFormValue("name")); err != nil {
+		s.adminResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	s.redirectAdmin(w, r, "game")
+}
+
+// adminNewLeague shows an empty league form.
+func (s *Server) adminNewLeague(w http.ResponseWriter, r *http.Request) {
+	league := back.NewLeague("", "", util.UUIDAsBlob{}, oot.RandomizerAPIName, "")
+	s.adminLeagueResponse(w, r, http.StatusOK, "", league, nil)
+}
+
+func (s *Server) postAdminNewLeague(w http.ResponseWriter, r *http.Request) {
+	league := back.NewLeague("", "", util.UUIDAsBlob{}, "", "")
+	if err := parseLeagueForm(r, &league); err != nil {
+		s.adminLeagueResponse(w, r, http.StatusBadRequest, "", league, err)
+		return
+	}
+
+	if err := s.back.CreateLeague(league); err != nil {
+		s.adminLeagueResponse(w, r, http.StatusBadRequest, "", league, err)
+		return
+	}
+
+	log.Printf("info: league %s created from the website", league.ShortCode)
+	s.redirectAdmin(w, r, "league")
+}
+
+// adminLeague shows the form to edit a league.
+func (s *Server) adminLeague(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	league, err := s.back.GetLeagueByShortcode(shortcode)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.adminLeagueResponse(w, r, http.StatusOK, shortcode, league, nil)
+}
+
+func (s *Server) postAdminLeague(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	league, err := s.back.GetLeagueByShortcode(shortcode)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := parseLeagueForm(r, &league); err != nil {
+		s.adminLeagueResponse(w, r, http.StatusBadRequest, shortcode, league, err)
+		return
+	}
+
+	if err := s.back.UpdateLeague(shortcode, league); err != nil {
+		s.adminLeagueResponse(w, r, http.StatusBadRequest, shortcode, league, err)
+		return
+	}
+
+	log.Printf("info: league %s updated from the website", league.ShortCode)
+	s.redirectAdmin(w, r, "league")
+}
+
+// adminScheduleDay is a day of the schedule editor, one "15:04 Area/City"
+// entry per line.
+type adminScheduleDay struct {
+	Key   string // Schedule field name, eg. "Mon"
+	Name  string // English msgid, eg. "Monday"
+	Help  string // English msgid, only for the lists other than the weekdays
+	Hours string
+}
+
+// adminLeagueResponse renders the league form, shortcode is the current
+// shortcode of the edited league or empty for a new league.
+func (s *Server) adminLeagueResponse(
+	w http.ResponseWriter, r *http.Request,
+	code int, shortcode string, league back.League, err error,
+) {
+	public, err := publicError(err)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	games, err := s.back.GetGames()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := getSettingsFiles()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	preview, scheduleErr := league.Schedule.Between(now, now.AddDate(0, 0, 7))
+	if scheduleErr != nil && public == "" {
+		public = scheduleErr.Error()
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	s.response(w, r, code, "admin_league.html", struct {
+		ShortCode  string
+		League     back.League
+		Games      []back.Game
+		Days       []adminScheduleDay
+		Lists      []adminScheduleDay
+		Preview    []time.Time
+		Generators []string
+		Settings   []string
+		Error      string
+		CSRF       string
+	}{
+		ShortCode: shortcode,
+		League:    league,
+		Games:     games,
+		Days:      scheduleDays(&league.Schedule),
+		Lists:     scheduleLists(&league.Schedule),
+		Preview:   preview,
+		Generators: []string{
+			oot.RandomizerAPIName, oot.RandomizerName,
+			oot.SettingsRandomizerAPIName, oot.SettingsRandomizerName,
+		},
+		Settings: settings,
+		Error:    public,
+		CSRF:     s.csrfToken(player),
+	})
+}
+
+// parseLeagueForm sets the fields of the league form on the given league,
+// the result is validated by the back.
+func parseLeagueForm(r *http.Request, league *back.League) error {
+	league.Name = strings.TrimSpace(r.PostFormValue("name"))
+	league.ShortCode = strings.TrimSpace(r.PostFormValue("shortcode"))
+	league.Generator = strings.TrimSpace(r.PostFormValue("generator"))
+	league.Settings = strings.TrimSpace(r.PostFormValue("settings"))
+	league.AnnounceDiscordChannelID = util.NullString(strings.TrimSpace(r.PostFormValue("announce")))
+
+	for _, v := range append(scheduleDays(&league.Schedule), scheduleLists(&league.Schedule)...) {
+		*scheduleHours(&league.Schedule, v.Key) = parseLines(r.PostFormValue("schedule-" + v.Key))
+	}
+
+	gameID, err := uuid.Parse(r.PostFormValue("game"))
+	if err != nil {
+		return util.ErrPublic("unknown game")
+	}
+	league.GameID = util.UUIDAsBlob(gameID)
+
+	return nil
+}
+
+func scheduleDays(schedule *back.Schedule) []adminScheduleDay {
+	days := []adminScheduleDay{
+		{Key: "Mon", Name: "Monday"},
+		{Key: "Tue", Name: "Tuesday"},
+		{Key: "Wed", Name: "Wednesday"},
+		{Key: "Thu", Name: "Thursday"},
+		{Key: "Fri", Name: "Friday"},
+		{Key: "Sat", Name: "Saturday"},
+		{Key: "Sun", Name: "Sunday"},
+	}
+
+	for k := range days {
+		days[k].Hours = strings.Join(*scheduleHours(schedule, days[k].Key), "\n")
+	}
+
+	return days
+}
+
+// scheduleLists returns the schedule entries that are not tied to a weekday.
+func scheduleLists(schedule *back.Schedule) []adminScheduleDay {
+	lists := []adminScheduleDay{
+		{
+			Key: "Rules", Name: "Recurrences",
+			Help: "One RRULE per line followed by HH:MM and a timezone, eg. FREQ=MONTHLY;BYDAY=1SA 20:00 Europe/Paris.",
+		},
+		{
+			Key: "Dates", Name: "One-off races",
+			Help: "One race per line as YYYY-MM-DD HH:MM followed by a timezone.",
+		},
+		{
+			Key: "Blackouts", Name: "Blackouts",
+			Help: "One YYYY-MM-DD day or YYYY-MM-DD/YYYY-MM-DD range per line, weekly and recurring races are skipped on these days but one-off races are not.",
+		},
+	}
+
+	for k := range lists {
+		lists[k].Hours = strings.Join(*scheduleHours(schedule, lists[k].Key), "\n")
+	}
+
+	return lists
+}
+
+func scheduleHours(schedule *back.Schedule, day string) *[]string {
+	switch day {
+	case "Mon":
+		return &schedule.Mon
+	case "Tue":
+		return &schedule.Tue
+	case "Wed":
+		return &schedule.Wed
+	case "Thu":
+		return &schedule.Thu
+	case "Fri":
+		return &schedule.Fri
+	case "Sat":
+		return &schedule.Sat
+	case "Sun":
+		return &schedule.Sun
+	case "Rules":
+		return &schedule.Rules
+	case "Dates":
+		return &schedule.Dates
+	case "Blackouts":
+		return &schedule.Blackouts
+	default:
+		panic("invalid day: " + day)
+	}
+}
+
+// parseLines returns the non-empty lines of a textarea, never nil.
+func parseLines(str string) []string {
+	ret := []string{}
+	for _, v := range strings.Split(str, "\n") {
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret
+}
+
+// getSettingsFiles returns the names of the settings files available to the
+// OoT generators.
+func getSettingsFiles() ([]string, error) {
+	dir, err := oot.GetBaseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(paths))
+	for _, v := range paths {
+		if name := filepath.Base(v); !strings.HasPrefix(name, "settings_documentation") {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret, nil
+}
+
+// postAdminRerank recomputes the ranking history of a league, see back.Rerank.
+func (s *Server) postAdminRerank(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	dryRun := r.PostFormValue("dryrun") != ""
+
+	var since time.Time
+	if str := r.PostFormValue("since"); str != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", str); err != nil {
+			s.adminResponse(w, r, http.StatusBadRequest, util.ErrPublic("invalid date, expected YYYY-MM-DD"))
+			return
+		}
+	}
+
+	diff, err := s.back.Rerank(shortcode, since, dryRun)
+	if err != nil {
+		s.adminResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if !dryRun {
+		log.Printf("info: league %s reranked from the website", shortcode)
+	}
+
+	changed := make([]back.RankingDiff, 0, len(diff))
+	for _, v := range diff {
+		if v.Changed() {
+			changed = append(changed, v)
+		}
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	s.response(w, r, http.StatusOK, "admin_rerank.html", struct {
+		ShortCode string
+		DryRun    bool
+		Diff      []back.RankingDiff
+		CSRF      string
+	}{shortcode, dryRun, changed, s.csrfToken(player)})
+}
+
+// adminSessions lists the sessions around the current date with the full
+// results of their races.
+func (s *Server) adminSessions(w http.ResponseWriter, r *http.Request) {
+	s.adminSessionsResponse(w, r, http.StatusOK, nil)
+}
+
+// adminSession is a session as shown on the admin sessions page.
+type adminSession struct {
+	back.MatchSession
+	League     back.League
+	Recap      string
+	Editable   bool // date and players can be changed
+	Reseedable bool
+}
+
+func (s *Server) adminSessionsResponse(w http.ResponseWriter, r *http.Request, code int, err error) {
+	public, err := publicError(err)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	sessions, leagues, err := s.back.GetMatchSessions(
+		now.AddDate(0, 0, -adminSessionsDays),
+		now.AddDate(0, 0, adminSessionsDays),
+		[]back.MatchSessionStatus{
+			back.MatchSessionStatusWaiting,
+			back.MatchSessionStatusJoinable,
+			back.MatchSessionStatusPreparing,
+			back.MatchSessionStatusInProgress,
+			back.MatchSessionStatusClosed,
+		},
+		"StartDate DESC",
+	)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := make([]adminSession, 0, len(sessions))
+	for _, v := range sessions {
+		recap, err := s.back.GetMatchSessionRecap(v.ID, back.RecapScopeAdmin)
+		if err != nil {
+			s.error(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		payload = append(payload, adminSession{
+			MatchSession: v,
+			League:       leagues[v.LeagueID],
+			Recap:        recap,
+			Editable:     v.CanAdminEdit() == nil,
+			Reseedable: v.Status == back.MatchSessionStatusPreparing ||
+				v.Status == back.MatchSessionStatusInProgress,
+		})
+	}
+
+	allLeagues, err := s.back.GetLeagues()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	player := r.Context().Value(ctxKeyPlayer).(back.Player)
+	s.response(w, r, code, "admin_sessions.html", struct {
+		Sessions []adminSession
+		Leagues  []back.League
+		Error    string
+		Message  string
+		CSRF     string
+	}{
+		Sessions: payload,
+		Leagues:  allLeagues,
+		Error:    public,
+		Message:  adminActionMessages[r.URL.Query().Get("ok")],
+		CSRF:     s.csrfToken(player),
+	})
+}
+
+func (s *Server) postAdminSession(w http.ResponseWriter, r *http.Request) {
+	start, err := parseDatetimeForm(r)
+	if err != nil {
+		s.adminSessionsResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := s.back.CreateMatchSession(r.PostFormValue("shortcode"), start); err != nil {
+		s.adminSessionsResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	s.redirectAdminSessions(w, r, "race")
+}
+
+// adminSessionAction wraps an action on the session given in the URL.
+func (s *Server) adminSessionAction(
+	ok string,
+	action func(*http.Request, util.UUIDAsBlob) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := urlID(r, "id")
+		if err != nil {
+			s.error(w, r, err, http.StatusNotFound)
+			return
+		}
+
+		if err := action(r, id); err != nil {
+			s.adminSessionsResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		s.redirectAdminSessions(w, r, ok)
+	}
+}
+
+func (s *Server) adminSessionPostpone(r *http.Request, id util.UUIDAsBlob) error {
+	start, err := parseDatetimeForm(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.back.PostponeMatchSession(id, start)
+	return err
+}
+
+func (s *Server) adminSessionCancel(_ *http.Request, id util.UUIDAsBlob) error {
+	_, err := s.back.CancelMatchSession(id)
+	return err
+}
+
+func (s *Server) adminSessionAdd(r *http.Request, id util.UUIDAsBlob) error {
+	_, err := s.back.AddMatchSessionPlayer(id, strings.TrimSpace(r.PostFormValue("name")))
+	return err
+}
+
+func (s *Server) adminSessionKick(r *http.Request, id util.UUIDAsBlob) error {
+	_, err := s.back.KickMatchSessionPlayer(id, strings.TrimSpace(r.PostFormValue("name")))
+	return err
+}
+
+func (s *Server) adminSessionReseed(_ *http.Request, id util.UUIDAsBlob) error {
+	_, err := s.back.RegenerateMatchSessionSeeds(id)
+	return err
+}
+
+// parseDatetimeForm reads the "date", "time", and "timezone" fields of a form.
+func parseDatetimeForm(r *http.Request) (time.Time, error) {
+	return util.ParseDatetime(
+		r.PostFormValue("date"),
+		r.PostFormValue("time"),
+		strings.TrimSpace(r.PostFormValue("timezone")),
+	)
+}
+
+// redirectAdmin redirects to the admin index after a successful action with
+// the given adminActionMessages key.
+func (s *Server) redirectAdmin(w http.ResponseWriter, r *http.Request, ok string) {
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	http.Redirect(w, r, "/"+locale+"/admin?ok="+ok, http.StatusSeeOther)
+}
+
+// redirectAdminSessions redirects to the admin sessions page after a
+// successful action with the given adminActionMessages key.
+func (s *Server) redirectAdminSessions(w http.ResponseWriter, r *http.Request, ok string) {
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	http.Redirect(w, r, "/"+locale+"/admin/sessions?ok="+ok, http.StatusSeeOther)
+}
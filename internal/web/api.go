@@ -0,0 +1,191 @@
+package web
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kaepora/internal/util"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	apiDefaultPerPage = 50
+	apiMaxPerPage     = 100
+)
+
+// setupAPIRouter registers the read-only JSON API, responses are wrapped in
+// an apiEnvelope and errors are returned as an apiError.
+func (s *Server) setupAPIRouter(r chi.Router) {
+	r.Use(apiCORS)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		s.apiError(w, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		s.apiError(w, errors.New(http.StatusText(http.StatusMethodNotAllowed)), http.StatusMethodNotAllowed)
+	})
+
+	// Server-Sent Events, never times out.
+	r.Get("/leagues/{shortcode}/live", s.apiLeagueLive)
+	r.Get("/sessions/{id}/live", s.apiSessionLive)
+	r.Get("/players/{name}/live", s.apiPlayerLive)
+
+	r.Group(func(r chi.Router) {
+		r.Use(writeTimeout)
+
+		r.Get("/leagues", s.apiLeagues)
+		r.Get("/leagues/{shortcode}", s.apiLeague)
+		r.Get("/leagues/{shortcode}/leaderboard", s.apiLeaderboard)
+		r.Get("/leagues/{shortcode}/stats", s.apiStats)
+
+		r.Get("/sessions", s.apiSessions)
+		r.Get("/sessions/{id}", s.apiSession)
+
+		r.Get("/players", s.apiPlayers)
+		r.Get("/players/{name}", s.apiPlayer)
+		r.Get("/players/{name}/ratings/{shortcode}", s.apiPlayerRatings)
+	})
+}
+
+// apiCORS allows any website to read the API, it's public and read-only.
+func apiCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type apiEnvelope struct {
+	Data       interface{}    `json:"data"`
+	Pagination *apiPagination `json:"pagination,omitempty"`
+}
+
+type apiPagination struct {
+	Page    int `json:"page"` // starts at 1
+	PerPage int `json:"per_page"`
+	Total   int `json:"total"`
+}
+
+type apiErrorBody struct {
+	Error string `json:"error"`
+}
+
+// apiPage returns the pagination requested with the "page" and "per_page"
+// query parameters.
+func apiPage(r *http.Request) (apiPagination, error) {
+	ret := apiPagination{Page: 1, PerPage: apiDefaultPerPage}
+	parse := func(name string, dst *int, max int) error {
+		str := r.URL.Query().Get(name)
+		if str == "" {
+			return nil
+		}
+
+		v, err := strconv.Atoi(str)
+		if err != nil || v < 1 || (max > 0 && v > max) {
+			return util.ErrPublic(fmt.Sprintf("invalid %s parameter: %q", name, str))
+		}
+		*dst = v
+
+		return nil
+	}
+
+	if err := parse("page", &ret.Page, 0); err != nil {
+		return apiPagination{}, err
+	}
+	if err := parse("per_page", &ret.PerPage, apiMaxPerPage); err != nil {
+		return apiPagination{}, err
+	}
+
+	return ret, nil
+}
+
+// Offset returns the number of items before the page.
+func (p apiPagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// apiResponse writes data as JSON with an ETag computed from its contents,
+// nothing is sent if the client already has it.
+func (s *Server) apiResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	data interface{},
+	pagination *apiPagination,
+) {
+	body, err := json.Marshal(apiEnvelope{Data: data, Pagination: pagination})
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	s.cache(w, "public", 1*time.Minute)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Printf("warning: %s", err)
+	}
+}
+
+// etagMatches returns true if the If-None-Match header contains the etag.
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiError writes an error as JSON, only public errors are shown as is.
+func (s *Server) apiError(w http.ResponseWriter, err error, code int) {
+	if errors.Is(err, sql.ErrNoRows) {
+		code = http.StatusNotFound
+	}
+	log.Printf("error: API HTTP %d: %v", code, err)
+
+	msg := http.StatusText(code)
+	var public util.ErrPublic
+	if errors.As(err, &public) {
+		msg = public.Error()
+	}
+
+	body, err := json.Marshal(apiErrorBody{msg})
+	if err != nil {
+		log.Printf("error: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("warning: %s", err)
+	}
+}
@@ -0,0 +1,604 @@
+package web
+
+import (
+	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// This file contains the handlers of the /api/v1 JSON API and the types they
+// return. Types are decoupled from the back so internal fields never leak and
+// the API stays stable when the DB changes.
+
+type apiLeague struct {
+	ID           string              `json:"id"`
+	Name         string              `json:"name"`
+	ShortCode    string              `json:"shortcode"`
+	Generator    string              `json:"generator"`
+	RatingSystem string              `json:"rating_system"`
+	RatingPeriod string              `json:"rating_period"`
+	Schedule     map[string][]string `json:"schedule"` // day => "15:04 Location", and "rules", "dates", "blackouts"
+
+	NextSession          *apiSession `json:"next_session"`
+	NextScheduledSession *time.Time  `json:"next_scheduled_session"` // in the next 7 days
+}
+
+type apiSession struct {
+	ID           string     `json:"id"`
+	League       string     `json:"league"` // shortcode
+	StartDate    time.Time  `json:"start_date"`
+	Status       string     `json:"status"`
+	PlayersCount int        `json:"players_count"`
+	Matches      []apiMatch `json:"matches,omitempty"` // only on ended sessions
+}
+
+type apiMatch struct {
+	ID            string          `json:"id"`
+	StartedAt     *time.Time      `json:"started_at"`
+	EndedAt       *time.Time      `json:"ended_at"`
+	Generator     string          `json:"generator"`
+	Seed          string          `json:"seed"`
+	SpoilerLogURL string          `json:"spoiler_log_url"`
+	Entries       []apiMatchEntry `json:"entries"`
+}
+
+type apiMatchEntry struct {
+	Player          string     `json:"player"`
+	Status          string     `json:"status"`
+	Outcome         string     `json:"outcome"`
+	StartedAt       *time.Time `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds,omitempty"` // finished entries only
+}
+
+type apiPlayer struct {
+	Name      string    `json:"name"`
+	StreamURL string    `json:"stream_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiPlayerProfile struct {
+	apiPlayer
+	Leagues []apiPlayerLeague `json:"leagues"`
+}
+
+type apiPlayerLeague struct {
+	League             string  `json:"league"` // shortcode
+	Rating             float64 `json:"rating"`
+	Deviation          float64 `json:"deviation"`
+	Provisional        bool    `json:"provisional"`
+	Wins               int     `json:"wins"`
+	Losses             int     `json:"losses"`
+	Draws              int     `json:"draws"`
+	Forfeits           int     `json:"forfeits"`
+	Finishes           int     `json:"finishes"`
+	AverageTimeSeconds int64   `json:"average_time_seconds,omitempty"`
+	BestTimeSeconds    int64   `json:"best_time_seconds,omitempty"`
+}
+
+type apiRating struct {
+	PeriodStart time.Time `json:"period_start"`
+	Rating      float64   `json:"rating"`
+	Deviation   float64   `json:"deviation"`
+}
+
+type apiLeaderboard struct {
+	Season      *apiSeason            `json:"season"`
+	Period      *apiPeriod            `json:"period"` // only for past leaderboards
+	Entries     []apiLeaderboardEntry `json:"entries"`
+	Provisional []apiLeaderboardEntry `json:"provisional"`
+}
+
+type apiSeason struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type apiLeaderboardEntry struct {
+	Rank         int     `json:"rank,omitempty"`
+	Player       string  `json:"player"`
+	StreamURL    string  `json:"stream_url,omitempty"`
+	Rating       float64 `json:"rating"`
+	Deviation    float64 `json:"deviation"`
+	Division     string  `json:"division,omitempty"`
+	DivisionRank int     `json:"division_rank,omitempty"`
+	Wins         int     `json:"wins"`
+	Losses       int     `json:"losses"`
+	Draws        int     `json:"draws"`
+	Forfeits     int     `json:"forfeits"`
+	PreviousRank int     `json:"previous_rank,omitempty"` // zero if new on the leaderboard
+	RatingDelta  float64 `json:"rating_delta"`
+}
+
+type apiStats struct {
+	RegisteredPlayers     int       `json:"registered_players"`
+	RankedPlayers         int       `json:"ranked_players"`
+	PlayersOnLeaderboard  int       `json:"players_on_leaderboard"`
+	SeedsPlayed           int       `json:"seeds_played"`
+	Forfeits              int       `json:"forfeits"`
+	DoubleForfeits        int       `json:"double_forfeits"`
+	FirstLadderRace       time.Time `json:"first_ladder_race"`
+	AveragePlayersPerRace int       `json:"average_players_per_race"`
+	MostPlayersInARace    int       `json:"most_players_in_a_race"`
+
+	Records       []apiFinishTime   `json:"records"`
+	WeeklyMedians []apiWeeklyMedian `json:"weekly_medians"`
+}
+
+type apiFinishTime struct {
+	Player          string    `json:"player"`
+	MatchID         string    `json:"match_id"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+type apiWeeklyMedian struct {
+	Week          time.Time `json:"week"`
+	MedianSeconds int64     `json:"median_seconds"`
+	Count         int       `json:"count"`
+}
+
+func (s *Server) apiLeagues(w http.ResponseWriter, r *http.Request) {
+	leagues, err := s.back.GetLeagues()
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	next, err := s.back.GetNextMatchSessions()
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := make([]apiLeague, 0, len(leagues))
+	for _, v := range leagues {
+		ret = append(ret, newAPILeague(v, next))
+	}
+
+	s.apiResponse(w, r, ret, nil)
+}
+
+func (s *Server) apiLeague(w http.ResponseWriter, r *http.Request) {
+	league, err := s.back.GetLeagueByShortcode(chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	next, err := s.back.GetNextMatchSessions()
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.apiResponse(w, r, newAPILeague(league, next), nil)
+}
+
+// apiLeaderboard returns the current leaderboard, the frozen leaderboard of
+// the season given in the "season" parameter, or the leaderboard at the end
+// of the rating period given in the "period" parameter.
+func (s *Server) apiLeaderboard(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	season, _, err := s.requestSeason(r, shortcode, true)
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	var (
+		ret                      apiLeaderboard
+		leaderboard, provisional []back.LeaderboardEntry
+	)
+	switch v := r.URL.Query().Get("period"); {
+	case !season.ID.IsZero():
+		ret.Season = &apiSeason{ID: season.ID.String(), Name: season.Name}
+		leaderboard, err = s.back.GetSeasonLeaderboard(season.ID)
+	case v != "":
+		at, err := parsePeriodParam(v)
+		if err != nil {
+			s.apiError(w, util.ErrPublic("invalid period parameter"), http.StatusBadRequest)
+			return
+		}
+
+		var period back.LeaderboardPeriod
+		period, err = s.back.GetLeaderboardAtForShortcode(shortcode, at)
+		if err != nil {
+			s.apiError(w, err, http.StatusInternalServerError)
+			return
+		}
+		ret.Period = &apiPeriod{Start: period.Start, End: period.End}
+		leaderboard = period.Entries
+	default:
+		leaderboard, err = s.back.GetLeaderboardForShortcode(shortcode)
+		if err == nil {
+			provisional, err = s.back.GetProvisionalPlayersForShortcode(shortcode)
+		}
+	}
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret.Entries = newAPILeaderboardEntries(leaderboard)
+	ret.Provisional = newAPILeaderboardEntries(provisional)
+	s.apiResponse(w, r, ret, nil)
+}
+
+func (s *Server) apiStats(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	season, _, err := s.requestSeason(r, shortcode, false)
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	misc, err := s.back.GetMiscStats(shortcode, season)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	times, err := s.back.GetFinishTimes(shortcode, season)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := apiStats{
+		RegisteredPlayers:     misc.RegisteredPlayers,
+		RankedPlayers:         misc.RankedPlayers,
+		PlayersOnLeaderboard:  misc.PlayersOnLeaderboard,
+		SeedsPlayed:           misc.SeedsPlayed,
+		Forfeits:              misc.Forfeits,
+		DoubleForfeits:        misc.DoubleForfeits,
+		FirstLadderRace:       misc.FirstLadderRace.Time(),
+		AveragePlayersPerRace: misc.AveragePlayersPerRace,
+		MostPlayersInARace:    misc.MostPlayersInARace,
+		Records:               []apiFinishTime{},
+		WeeklyMedians:         []apiWeeklyMedian{},
+	}
+	for _, v := range times.Records(leagueRecordsCount) {
+		ret.Records = append(ret.Records, apiFinishTime{
+			Player:          v.PlayerName,
+			MatchID:         v.MatchID.String(),
+			StartedAt:       v.StartedAt.Time(),
+			DurationSeconds: seconds(v.Duration()),
+		})
+	}
+	for _, v := range times.WeeklyMedians() {
+		ret.WeeklyMedians = append(ret.WeeklyMedians, apiWeeklyMedian{
+			Week:          v.Week,
+			MedianSeconds: seconds(v.Median),
+			Count:         v.Count,
+		})
+	}
+
+	s.apiResponse(w, r, ret, nil)
+}
+
+// apiSessions lists every session, latest first, use the "league" parameter
+// to filter on a single league. Matches are only given by apiSession.
+func (s *Server) apiSessions(w http.ResponseWriter, r *http.Request) {
+	page, err := apiPage(r)
+	if err != nil {
+		s.apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	sessions, total, err := s.back.GetMatchSessionsPage(r.URL.Query().Get("league"), page.Offset(), page.PerPage)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	leagues, err := s.back.GetLeagues()
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+	shortcodes := make(map[util.UUIDAsBlob]string, len(leagues))
+	for _, v := range leagues {
+		shortcodes[v.ID] = v.ShortCode
+	}
+
+	ret := make([]apiSession, 0, len(sessions))
+	for _, v := range sessions {
+		ret = append(ret, newAPISession(v, shortcodes[v.LeagueID]))
+	}
+
+	page.Total = total
+	s.apiResponse(w, r, ret, &page)
+}
+
+// apiSession returns a session and its matches, like getOneMatchSession the
+// matches are hidden until they all ended.
+func (s *Server) apiSession(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	session, matches, players, err := s.back.GetMatchSession(id)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	for k := range matches {
+		if !matches[k].HasEnded() {
+			s.apiError(w, util.ErrPublic("this session is still in progress"), http.StatusForbidden)
+			return
+		}
+	}
+
+	league, err := s.back.GetLeague(session.LeagueID)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := newAPISession(session, league.ShortCode)
+	ret.Matches = make([]apiMatch, 0, len(matches))
+	for _, v := range matches {
+		ret.Matches = append(ret.Matches, newAPIMatch(v, players))
+	}
+
+	s.apiResponse(w, r, ret, nil)
+}
+
+func (s *Server) apiPlayers(w http.ResponseWriter, r *http.Request) {
+	page, err := apiPage(r)
+	if err != nil {
+		s.apiError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	players, total, err := s.back.GetPlayersPage(page.Offset(), page.PerPage)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := make([]apiPlayer, 0, len(players))
+	for _, v := range players {
+		ret = append(ret, newAPIPlayer(v))
+	}
+
+	page.Total = total
+	s.apiResponse(w, r, ret, &page)
+}
+
+func (s *Server) apiPlayer(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	profile, err := s.back.GetPlayerProfile(name)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := apiPlayerProfile{
+		apiPlayer: newAPIPlayer(profile.Player),
+		Leagues:   make([]apiPlayerLeague, 0, len(profile.Leagues)),
+	}
+	for _, v := range profile.Leagues {
+		ret.Leagues = append(ret.Leagues, apiPlayerLeague{
+			League:             v.League.ShortCode,
+			Rating:             v.Rating.Rating,
+			Deviation:          v.Rating.Deviation,
+			Provisional:        v.Provisional,
+			Wins:               v.Wins,
+			Losses:             v.Losses,
+			Draws:              v.Draws,
+			Forfeits:           v.Forfeits,
+			Finishes:           v.Finishes,
+			AverageTimeSeconds: seconds(v.AverageTime),
+			BestTimeSeconds:    seconds(v.BestTime),
+		})
+	}
+
+	s.apiResponse(w, r, ret, nil)
+}
+
+func (s *Server) apiPlayerRatings(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	history, err := s.back.GetPlayerRatingHistory(name, chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	ret := make([]apiRating, 0, len(history))
+	for _, v := range history {
+		ret = append(ret, apiRating{
+			PeriodStart: v.RatingPeriodStartedAt.Time(),
+			Rating:      v.Rating,
+			Deviation:   v.Deviation,
+		})
+	}
+
+	s.apiResponse(w, r, ret, nil)
+}
+
+func newAPILeague(league back.League, next map[util.UUIDAsBlob]back.MatchSession) apiLeague {
+	ret := apiLeague{
+		ID:           league.ID.String(),
+		Name:         league.Name,
+		ShortCode:    league.ShortCode,
+		Generator:    league.Generator,
+		RatingSystem: league.RatingSystem,
+		RatingPeriod: string(league.RatingPeriod),
+		Schedule: map[string][]string{
+			"mon": league.Schedule.Mon,
+			"tue": league.Schedule.Tue,
+			"wed": league.Schedule.Wed,
+			"thu": league.Schedule.Thu,
+			"fri": league.Schedule.Fri,
+			"sat": league.Schedule.Sat,
+			"sun": league.Schedule.Sun,
+		},
+	}
+
+	if session, ok := next[league.ID]; ok {
+		v := newAPISession(session, league.ShortCode)
+		ret.NextSession = &v
+	}
+	for k, v := range map[string][]string{
+		"rules":     league.Schedule.Rules,
+		"dates":     league.Schedule.Dates,
+		"blackouts": league.Schedule.Blackouts,
+	} {
+		if len(v) > 0 {
+			ret.Schedule[k] = v
+		}
+	}
+
+	if t, err := league.Schedule.Next(); err == nil && !t.IsZero() {
+		ret.NextScheduledSession = &t
+	}
+
+	return ret
+}
+
+func newAPISession(session back.MatchSession, shortcode string) apiSession {
+	return apiSession{
+		ID:           session.ID.String(),
+		League:       shortcode,
+		StartDate:    session.StartDate.Time(),
+		Status:       apiSessionStatus(session.Status),
+		PlayersCount: len(session.PlayerIDs),
+	}
+}
+
+func newAPIMatch(match back.Match, players map[util.UUIDAsBlob]back.Player) apiMatch {
+	ret := apiMatch{
+		ID:            match.ID.String(),
+		StartedAt:     nullTime(match.StartedAt),
+		EndedAt:       nullTime(match.EndedAt),
+		Generator:     match.Generator,
+		Seed:          match.Seed,
+		SpoilerLogURL: "/en/matches/" + match.ID.String() + "/spoilers?raw=1",
+		Entries:       make([]apiMatchEntry, 0, len(match.Entries)),
+	}
+
+	for _, v := range match.Entries {
+		ret.Entries = append(ret.Entries, apiMatchEntry{
+			Player:          players[v.PlayerID].Name,
+			Status:          apiMatchEntryStatus(v.Status),
+			Outcome:         apiMatchEntryOutcome(v.Outcome),
+			StartedAt:       nullTime(v.StartedAt),
+			EndedAt:         nullTime(v.EndedAt),
+			DurationSeconds: seconds(v.Duration()),
+		})
+	}
+
+	return ret
+}
+
+func newAPIPlayer(player back.Player) apiPlayer {
+	return apiPlayer{
+		Name:      player.Name,
+		StreamURL: player.StreamURL,
+		CreatedAt: player.CreatedAt.Time(),
+	}
+}
+
+func newAPILeaderboardEntries(entries []back.LeaderboardEntry) []apiLeaderboardEntry {
+	ret := make([]apiLeaderboardEntry, 0, len(entries))
+	for _, v := range entries {
+		ret = append(ret, apiLeaderboardEntry{
+			Rank:         v.Rank,
+			Player:       v.PlayerName,
+			StreamURL:    v.PlayerStreamURL,
+			Rating:       v.Rating,
+			Deviation:    v.Deviation,
+			Division:     v.DivisionName,
+			DivisionRank: v.DivisionRank,
+			Wins:         v.Wins,
+			Losses:       v.Losses,
+			Draws:        v.Draws,
+			Forfeits:     v.Forfeits,
+			PreviousRank: v.PreviousRank,
+			RatingDelta:  v.RatingDelta,
+		})
+	}
+
+	return ret
+}
+
+func apiSessionStatus(status back.MatchSessionStatus) string {
+	switch status {
+	case back.MatchSessionStatusWaiting:
+		return "waiting"
+	case back.MatchSessionStatusJoinable:
+		return "joinable"
+	case back.MatchSessionStatusPreparing:
+		return "preparing"
+	case back.MatchSessionStatusInProgress:
+		return "in_progress"
+	case back.MatchSessionStatusClosed:
+		return "closed"
+	default:
+		return "invalid"
+	}
+}
+
+func apiMatchEntryStatus(status back.MatchEntryStatus) string {
+	switch status {
+	case back.MatchEntryStatusWaiting:
+		return "waiting"
+	case back.MatchEntryStatusInProgress:
+		return "in_progress"
+	case back.MatchEntryStatusFinished:
+		return "finished"
+	case back.MatchEntryStatusForfeit:
+		return "forfeit"
+	default:
+		return "invalid"
+	}
+}
+
+func apiMatchEntryOutcome(outcome back.MatchEntryOutcome) string {
+	switch outcome {
+	case back.MatchEntryOutcomeWin:
+		return "win"
+	case back.MatchEntryOutcomeLoss:
+		return "loss"
+	case back.MatchEntryOutcomeDraw:
+		return "draw"
+	default:
+		return "invalid"
+	}
+}
+
+func nullTime(t util.NullTimeAsTimestamp) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	ret := t.Time.Time()
+	return &ret
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
@@ -0,0 +1,366 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	calendarDomain = "ootrladder.com" // used in UIDs, must never change
+
+	// Sessions of the past calendarPastDays stay in the feed so cancellations
+	// are seen by the clients.
+	calendarPastDays  = 7
+	calendarAheadDays = 14
+
+	// calendarEventDuration is a rough race duration, it only serves to fill
+	// the calendars.
+	calendarEventDuration = 2 * time.Hour
+
+	// iCalendar dates without the trailing Z for UTC.
+	calendarLocalTimeFormat = "20060102T150405"
+)
+
+// calendarEvent is a single race, scheduled or already created as a session.
+type calendarEvent struct {
+	UID         string
+	Start       time.Time // in the timezone of the schedule, if known
+	Summary     string
+	Description string
+	Cancelled   bool
+}
+
+// calendarAll serves the races of every league as iCalendar.
+func (s *Server) calendarAll(w http.ResponseWriter, r *http.Request) {
+	leagues, err := s.back.GetLeagues()
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.calendar(w, r, "OoT Randomizer Ladder", leagues)
+}
+
+// calendarLeague serves the races of a single league as iCalendar.
+func (s *Server) calendarLeague(w http.ResponseWriter, r *http.Request) {
+	league, err := s.back.GetLeagueByShortcode(chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	s.calendar(w, r, s.locales[locale].Get("%s league", league.Name), []back.League{league})
+}
+
+func (s *Server) calendar(w http.ResponseWriter, r *http.Request, name string, leagues []back.League) {
+	locale := r.Context().Value(ctxKeyLocale).(string)
+	events, err := s.getCalendarEvents(locale, leagues, time.Now())
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.cache(w, "public", 5*time.Minute)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := writeCalendar(w, name, events, time.Now()); err != nil {
+		log.Printf("warning: %s", err)
+	}
+}
+
+// getCalendarEvents returns the sessions of the given leagues around now
+// and the scheduled races that have no session yet.
+func (s *Server) getCalendarEvents(locale string, leagues []back.League, now time.Time) ([]calendarEvent, error) {
+	from := now.AddDate(0, 0, -calendarPastDays)
+	to := now.AddDate(0, 0, calendarAheadDays)
+
+	sessions, _, err := s.back.GetMatchSessions(from, to, []back.MatchSessionStatus{
+		back.MatchSessionStatusWaiting,
+		back.MatchSessionStatusJoinable,
+		back.MatchSessionStatusPreparing,
+		back.MatchSessionStatusInProgress,
+		back.MatchSessionStatusClosed,
+	}, `DATETIME(StartDate) ASC`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []calendarEvent
+	for _, league := range leagues {
+		// Every division races in its own session at the same date.
+		divisions, err := s.back.GetDivisions(league.ShortCode)
+		if err != nil {
+			return nil, err
+		}
+		if len(divisions) == 0 {
+			divisions = []back.Division{{}}
+		}
+		divisionsByID := make(map[util.UUIDAsBlob]back.Division, len(divisions))
+		for _, v := range divisions {
+			divisionsByID[v.ID] = v
+		}
+
+		// Sessions are created from the schedule, use it to find the
+		// timezone of the sessions and the races without a session yet.
+		scheduled := map[int64]time.Time{}
+		dates, err := league.Schedule.Between(from, to)
+		if err != nil {
+			log.Printf("error: ignored schedule of league %s: %v", league.ShortCode, err)
+		}
+		for _, v := range dates {
+			scheduled[v.Unix()] = v
+		}
+
+		type createdKey struct {
+			divisionID util.UUIDAsBlob
+			start      int64
+		}
+		created := map[createdKey]struct{}{}
+
+		for _, session := range sessions {
+			if session.LeagueID != league.ID {
+				continue
+			}
+
+			start := session.StartDate.Time().UTC()
+			if v, ok := scheduled[start.Unix()]; ok {
+				start = v
+			}
+
+			// Sessions without division are shared by every division.
+			if session.DivisionID.Valid {
+				created[createdKey{session.DivisionID.UUID, start.Unix()}] = struct{}{}
+			} else {
+				for _, v := range divisions {
+					created[createdKey{v.ID, start.Unix()}] = struct{}{}
+				}
+			}
+			division := divisionsByID[session.DivisionID.UUID]
+			ret = append(ret, s.newCalendarEvent(locale, league, division, start, &session))
+		}
+
+		for _, v := range scheduled {
+			if !v.After(now) {
+				continue
+			}
+
+			for _, division := range divisions {
+				if _, ok := created[createdKey{division.ID, v.Unix()}]; !ok {
+					ret = append(ret, s.newCalendarEvent(locale, league, division, v, nil))
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+
+	return ret, nil
+}
+
+// newCalendarEvent returns the event of a race, session is nil if the race
+// has no session yet. division is zero if the league has no divisions.
+func (s *Server) newCalendarEvent(
+	locale string,
+	league back.League,
+	division back.Division,
+	start time.Time,
+	session *back.MatchSession,
+) calendarEvent {
+	ret := calendarEvent{
+		// Scheduled races and their session must share the same UID.
+		UID:     fmt.Sprintf("%s-%d@%s", league.ID, start.Unix(), calendarDomain),
+		Start:   start,
+		Summary: fmt.Sprintf(s.locales[locale].Get("%s league race"), league.Name),
+	}
+	if !division.ID.IsZero() {
+		ret.UID = fmt.Sprintf("%s-%s-%d@%s", league.ID, division.ID, start.Unix(), calendarDomain)
+		ret.Summary += " (" + division.Name + ")"
+	}
+
+	join := fmt.Sprintf(
+		s.locales[locale].Get("Join with !join %s on Discord between %d and %d minutes before the race."),
+		league.ShortCode,
+		-back.MatchSessionJoinableAfterOffset/time.Minute,
+		-back.MatchSessionPreparationOffset/time.Minute,
+	)
+	if session == nil {
+		ret.Description = join
+		return ret
+	}
+
+	players := fmt.Sprintf(s.locales[locale].Get("Players: %d"), len(session.PlayerIDs))
+	switch {
+	case session.Status == back.MatchSessionStatusWaiting,
+		session.Status == back.MatchSessionStatusJoinable:
+		ret.Description = join + "\n" + players
+	case session.Status == back.MatchSessionStatusClosed && len(session.PlayerIDs) == 0:
+		ret.Cancelled = true
+		ret.Description = s.locales[locale].Get("Cancelled, nobody joined this race.")
+	case session.Status == back.MatchSessionStatusClosed:
+		ret.Description = players + "\n" + publicURL + "/" + locale + "/sessions/" + session.ID.String()
+	default:
+		ret.Description = players
+	}
+
+	return ret
+}
+
+// writeCalendar writes the events as an iCalendar (RFC 5545) feed.
+func writeCalendar(w http.ResponseWriter, name string, events []calendarEvent, now time.Time) error {
+	buf := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeCalendarLine(buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//OoT Randomizer Ladder//kaepora//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escapeCalendarText(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	line("X-PUBLISHED-TTL", "PT1H")
+
+	locations := map[string]*time.Location{}
+	for _, v := range events {
+		if loc := v.Start.Location(); loc != time.UTC {
+			locations[loc.String()] = loc
+		}
+	}
+	names := make([]string, 0, len(locations))
+	for k := range locations {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		writeCalendarTimezone(buf, locations[k],
+			now.AddDate(0, 0, -calendarPastDays-1),
+			now.AddDate(0, 0, calendarAheadDays+1),
+		)
+	}
+
+	for _, v := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", v.UID)
+		line("DTSTAMP", now.UTC().Format(calendarLocalTimeFormat+"Z"))
+		writeCalendarDate(buf, "DTSTART", v.Start)
+		writeCalendarDate(buf, "DTEND", v.Start.Add(calendarEventDuration))
+		line("SUMMARY", escapeCalendarText(v.Summary))
+		line("DESCRIPTION", escapeCalendarText(v.Description))
+		if v.Cancelled {
+			line("STATUS", "CANCELLED")
+			line("SEQUENCE", "1")
+		} else {
+			line("STATUS", "CONFIRMED")
+			line("SEQUENCE", "0")
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+
+	return buf.Flush()
+}
+
+// writeCalendarDate writes a date in its own timezone, UTC dates are written
+// as such and do not require a VTIMEZONE.
+func writeCalendarDate(w *bufio.Writer, name string, t time.Time) {
+	if t.Location() == time.UTC {
+		writeCalendarLine(w, name+":"+t.Format(calendarLocalTimeFormat+"Z"))
+		return
+	}
+
+	writeCalendarLine(w, name+";TZID="+t.Location().String()+":"+t.Format(calendarLocalTimeFormat))
+}
+
+// writeCalendarTimezone writes the VTIMEZONE of a location with every
+// offset change in the [from, to] range.
+func writeCalendarTimezone(w *bufio.Writer, loc *time.Location, from, to time.Time) {
+	// The standard time has the lowest offset of the year.
+	_, winter := time.Date(from.Year(), time.January, 1, 0, 0, 0, 0, loc).Zone()
+	_, summer := time.Date(from.Year(), time.July, 1, 0, 0, 0, 0, loc).Zone()
+	standard := winter
+	if summer < winter {
+		standard = summer
+	}
+
+	observance := func(t time.Time, offsetFrom int) {
+		abbr, offset := t.Zone()
+		kind := "STANDARD"
+		if offset > standard {
+			kind = "DAYLIGHT"
+		}
+
+		writeCalendarLine(w, "BEGIN:"+kind)
+		// DTSTART is the local time before the change.
+		writeCalendarLine(w, "DTSTART:"+t.In(time.FixedZone("", offsetFrom)).Format(calendarLocalTimeFormat))
+		writeCalendarLine(w, "TZOFFSETFROM:"+formatCalendarOffset(offsetFrom))
+		writeCalendarLine(w, "TZOFFSETTO:"+formatCalendarOffset(offset))
+		writeCalendarLine(w, "TZNAME:"+abbr)
+		writeCalendarLine(w, "END:"+kind)
+	}
+
+	writeCalendarLine(w, "BEGIN:VTIMEZONE")
+	writeCalendarLine(w, "TZID:"+loc.String())
+
+	// Offsets change on the quarter hour, at worst.
+	t := from.UTC().Truncate(time.Hour).In(loc)
+	_, previous := t.Zone()
+	observance(t, previous)
+	for ; t.Before(to); t = t.Add(15 * time.Minute) {
+		if _, offset := t.Zone(); offset != previous {
+			observance(t, previous)
+			previous = offset
+		}
+	}
+
+	writeCalendarLine(w, "END:VTIMEZONE")
+}
+
+func formatCalendarOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, (offset%3600)/60)
+}
+
+// escapeCalendarText escapes a TEXT value.
+func escapeCalendarText(str string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(str)
+}
+
+// writeCalendarLine writes a content line folded at 75 octets without
+// splitting UTF-8 sequences.
+func writeCalendarLine(w *bufio.Writer, str string) {
+	max := 75
+	for len(str) > max {
+		cut := max
+		for cut > 0 && str[cut]&0xC0 == 0x80 { // continuation byte
+			cut--
+		}
+
+		w.WriteString(str[:cut] + "\r\n ") // nolint:errcheck, checked on Flush
+		str = str[cut:]
+		max = 74 // the leading space counts
+
+	}
+
+	w.WriteString(str + "\r\n") // nolint:errcheck, checked on Flush
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"kaepora/internal/back"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Atom (RFC 4287) elements, only what we need.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feed serves the latest results of a league as an Atom feed.
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	data, err := s.back.GetLeagueFeed(chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	feed, err := s.newAtomFeed(r.Context().Value(ctxKeyLocale).(string), data)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.cache(w, "public", 10*time.Minute)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		log.Printf("warning: %s", err)
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("warning: %s", err)
+	}
+}
+
+func (s *Server) newAtomFeed(locale string, data back.LeagueFeed) (atomFeed, error) {
+	self := fmt.Sprintf("%s/%s/feed/%s.atom", publicURL, locale, data.League.ShortCode)
+	leaderboard := fmt.Sprintf("%s/%s/leaderboard/%s", publicURL, locale, data.League.ShortCode)
+	ret := atomFeed{
+		ID:      self,
+		Title:   fmt.Sprintf(s.locales[locale].Get("%s league results"), data.League.Name),
+		Updated: formatAtomDate(data.Updated()),
+		Author:  atomAuthor{"OoT Randomizer Ladder"},
+		Links: []atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: leaderboard, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	var entries []atomEntry
+	for _, v := range data.Sessions {
+		url := fmt.Sprintf("%s/%s/sessions/%s", publicURL, locale, v.Session.ID)
+		content, err := s.renderFeedEntry(locale, "feed_session", struct {
+			Session back.FeedSession
+			Base    string
+		}{v, publicURL + "/" + locale})
+		if err != nil {
+			return atomFeed{}, err
+		}
+
+		entries = append(entries, atomEntry{
+			ID: url,
+			Title: fmt.Sprintf(
+				s.locales[locale].Get("%s league race of %s"),
+				data.League.Name, v.Session.StartDate.Time().UTC().Format("2006-01-02 15:04 MST"),
+			),
+			Updated: formatAtomDate(v.EndedAt),
+			Links:   []atomLink{{Href: url, Rel: "alternate", Type: "text/html"}},
+			Content: atomContent{Type: "html", Body: content},
+		})
+	}
+
+	for _, v := range data.Periods {
+		url := leaderboard + "?period=" + v.Start.UTC().Format(time.RFC3339)
+		content, err := s.renderFeedEntry(locale, "feed_period", v)
+		if err != nil {
+			return atomFeed{}, err
+		}
+
+		entries = append(entries, atomEntry{
+			ID: url,
+			Title: fmt.Sprintf(
+				s.locales[locale].Get("%s league leaderboard movements of %s"),
+				data.League.Name, v.Start.UTC().Format("2006-01-02"),
+			),
+			Updated: formatAtomDate(v.End),
+			Links:   []atomLink{{Href: url, Rel: "alternate", Type: "text/html"}},
+			Content: atomContent{Type: "html", Body: content},
+		})
+	}
+
+	// RFC 3339 UTC dates sort like strings.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Updated > entries[j].Updated })
+	ret.Entries = entries
+
+	return ret, nil
+}
+
+// renderFeedEntry renders the HTML contents of an entry using one of the
+// templates of feed.html.
+func (s *Server) renderFeedEntry(locale, name string, payload interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := s.tpl["feed.html"].ExecuteTemplate(&buf, name, struct {
+		Locale  string
+		Payload interface{}
+	}{locale, payload}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func formatAtomDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
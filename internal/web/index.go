@@ -67,10 +67,7 @@ func (s *Server) getIndexTemplateData() (nextRacesTemplateData, error) {
 
 // getStdTop3 returns the Top 3 leaderboard.
 func (s *Server) getStdTop3(shortcode string) ([]back.LeaderboardEntry, error) {
-	leaderboard, err := s.back.GetLeaderboardForShortcode(
-		shortcode,
-		back.DeviationThreshold,
-	)
+	leaderboard, err := s.back.GetLeaderboardForShortcode(shortcode)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kaepora/internal/back"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	// liveHeartbeatInterval is the delay between two keep-alive comments, it
+	// prevents proxies from closing idle streams.
+	liveHeartbeatInterval = 30 * time.Second
+
+	// liveRetryDelay is the delay clients should wait before reconnecting.
+	liveRetryDelay = 5 * time.Second
+)
+
+// apiLiveEvent is the payload of a Server-Sent Event, it contains no spoiler:
+// race times are only given by the regular API once the session has ended.
+type apiLiveEvent struct {
+	Session apiSession `json:"session"`
+	Ended   int        `json:"ended"` // number of players who ended their race, -1 if unknown
+
+	// entry_end only
+	Player string `json:"player,omitempty"`
+	Status string `json:"status,omitempty"` // finished or forfeit
+}
+
+func apiLiveEventName(typ back.LiveEventType) string {
+	switch typ {
+	case back.LiveEventSessionStatus:
+		return "session_status"
+	case back.LiveEventCountdown:
+		return "countdown"
+	case back.LiveEventEntryEnd:
+		return "entry_end"
+	default:
+		return "invalid"
+	}
+}
+
+// apiLeagueLive streams the events of every session of a league.
+func (s *Server) apiLeagueLive(w http.ResponseWriter, r *http.Request) {
+	league, err := s.back.GetLeagueByShortcode(chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	next, err := s.back.GetNextMatchSessions()
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var initial []apiLiveEvent
+	if session, ok := next[league.ID]; ok {
+		initial = append(initial, apiLiveEvent{
+			Session: newAPISession(session, league.ShortCode),
+			Ended:   -1,
+		})
+	}
+
+	s.liveStream(w, r, initial, func(ev back.LiveEvent) bool {
+		return ev.League.ID == league.ID
+	})
+}
+
+// apiSessionLive streams the events of a single session.
+func (s *Server) apiSessionLive(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	session, league, ended, err := s.back.GetLiveMatchSession(id)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.liveStream(w, r, []apiLiveEvent{{
+		Session: newAPISession(session, league.ShortCode),
+		Ended:   ended,
+	}}, func(ev back.LiveEvent) bool {
+		return ev.Session.ID == session.ID
+	})
+}
+
+// apiPlayerLive streams the events of every session a player joined.
+func (s *Server) apiPlayerLive(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.apiError(w, err, http.StatusNotFound)
+		return
+	}
+
+	player, err := s.back.GetPlayerByName(name)
+	if err != nil {
+		s.apiError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.liveStream(w, r, nil, func(ev back.LiveEvent) bool {
+		return ev.Session.HasPlayerID(player.ID.UUID())
+	})
+}
+
+// liveStream sends the initial events as session_status then every
+// LiveEvent accepted by the filter until the client goes away.
+func (s *Server) liveStream(
+	w http.ResponseWriter,
+	r *http.Request,
+	initial []apiLiveEvent,
+	filter func(back.LiveEvent) bool,
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.apiError(w, errors.New("streaming unsupported"), http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before sending anything to not miss an event.
+	events, unsubscribe := s.back.SubscribeLiveEvents()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", liveRetryDelay/time.Millisecond); err != nil {
+		return
+	}
+	for _, v := range initial {
+		if err := writeLiveEvent(w, "session_status", v); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// Only known when streaming a single session.
+	ended := -1
+	if len(initial) == 1 {
+		ended = initial[0].Ended
+	}
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter(ev) {
+				continue
+			}
+
+			if ev.Type == back.LiveEventEntryEnd && ended >= 0 {
+				ended++
+			}
+			payload := apiLiveEvent{
+				Session: newAPISession(ev.Session, ev.League.ShortCode),
+				Ended:   ended,
+			}
+			if ev.Type == back.LiveEventEntryEnd {
+				payload.Player = ev.PlayerName
+				payload.Status = apiMatchEntryStatus(ev.Entry.Status)
+			}
+
+			err = writeLiveEvent(w, apiLiveEventName(ev.Type), payload)
+		}
+
+		if err != nil {
+			log.Printf("debug: live stream closed: %s", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeLiveEvent(w http.ResponseWriter, name string, payload apiLiveEvent) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// overlayOptions are the query string options of an overlay.
+type overlayOptions struct {
+	Layout     string // horizontal, vertical, or timer
+	Ratings    bool
+	Background template.CSS
+	Text       template.CSS
+	Accent     template.CSS
+}
+
+var overlayColorRegexp = regexp.MustCompile(`^#?([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// parseOverlayOptions reads the overlay options from the "layout",
+// "ratings", "bg", "fg", and "accent" query parameters. Colors are given as
+// hexadecimal RGB(A), the leading # is optional.
+func parseOverlayOptions(r *http.Request) (overlayOptions, error) {
+	query := r.URL.Query()
+	ret := overlayOptions{
+		Layout:     "horizontal",
+		Ratings:    query.Get("ratings") != "0",
+		Background: "transparent",
+		Text:       "#ffffff",
+		Accent:     "#f5c518",
+	}
+
+	switch layout := query.Get("layout"); layout {
+	case "":
+	case "horizontal", "vertical", "timer":
+		ret.Layout = layout
+	default:
+		return overlayOptions{}, util.ErrPublic(fmt.Sprintf("invalid layout: %q", layout))
+	}
+
+	for name, dst := range map[string]*template.CSS{
+		"bg":     &ret.Background,
+		"fg":     &ret.Text,
+		"accent": &ret.Accent,
+	} {
+		str := query.Get(name)
+		if str == "" {
+			continue
+		}
+
+		match := overlayColorRegexp.FindStringSubmatch(str)
+		if match == nil {
+			return overlayOptions{}, util.ErrPublic(fmt.Sprintf("invalid %s color: %q", name, str))
+		}
+		*dst = template.CSS("#" + match[1]) // nolint:gosec, validated above
+	}
+
+	return ret, nil
+}
+
+// overlayMatch shows a single match.
+func (s *Server) overlayMatch(w http.ResponseWriter, r *http.Request) {
+	id, err := urlID(r, "id")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	overlay, err := s.back.GetOverlayForMatch(id)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.overlay(w, r, overlay, "/api/v1/sessions/"+overlay.Session.ID.String()+"/live")
+}
+
+// overlayPlayer shows the latest match of a player.
+func (s *Server) overlayPlayer(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	overlay, err := s.back.GetOverlayForPlayer(name)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Follow every session of the player to switch to their next match,
+	// whatever its league.
+	s.overlay(w, r, overlay, "/api/v1/players/"+url.PathEscape(overlay.Runners[0].Player.Name)+"/live")
+}
+
+// overlay renders an Overlay that reloads itself on every event of the given
+// live events URL.
+func (s *Server) overlay(w http.ResponseWriter, r *http.Request, overlay back.Overlay, live string) {
+	options, err := parseOverlayOptions(r)
+	if err != nil {
+		s.error(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	// Counts down to the session before the match starts.
+	start := overlay.Session.StartDate.Time()
+	if overlay.Match.StartedAt.Valid {
+		start = overlay.Match.StartedAt.Time.Time()
+	}
+
+	// The page reloads itself on every live event, it must be fresh.
+	w.Header().Set("Cache-Control", "no-cache")
+	s.response(w, r, http.StatusOK, "overlay.html", struct {
+		Overlay         back.Overlay
+		Options         overlayOptions
+		LiveURL         string
+		Now, Start, End time.Time
+	}{
+		Overlay: overlay,
+		Options: options,
+		LiveURL: live,
+		Now:     time.Now(),
+		Start:   start,
+		End:     overlay.TimerEnd(),
+	})
+}
+
+// overlayLocale chooses the locale from the "lang" query parameter, overlays
+// are not under a locale as they are not part of the website.
+func (s *Server) overlayLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := chooseLocale(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+		ctx := context.WithValue(r.Context(), ctxKeyLocale, locale)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tplOverlayURI returns the URI of the overlay of a player.
+func tplOverlayURI(locale, name string) string {
+	return "/overlay/players/" + url.PathEscape(name) + "?lang=" + locale
+}
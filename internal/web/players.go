@@ -0,0 +1,140 @@
+package web
+
+import (
+	"errors"
+	"kaepora/internal/back"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// playerProfile shows the ratings and match history of a single player.
+func (s *Server) playerProfile(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	profile, err := s.back.GetPlayerProfile(name)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.cache(w, "public", 5*time.Minute)
+	s.response(w, r, http.StatusOK, "player.html", profile)
+}
+
+// playerRatings draws the rating of a player over time in a league.
+func (s *Server) playerRatings(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	history, err := s.back.GetPlayerRatingHistory(name, chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	// go-chart can't draw a range out of a single value.
+	if len(history) < 2 {
+		s.error(w, r, errors.New("not enough rating history"), http.StatusNotFound)
+		return
+	}
+
+	series := chart.TimeSeries{
+		Style: chart.Style{
+			StrokeColor: drawing.ColorFromHex("4c7899"),
+			StrokeWidth: 2,
+		},
+		XValues: make([]time.Time, 0, len(history)),
+		YValues: make([]float64, 0, len(history)),
+	}
+	for _, v := range history {
+		series.XValues = append(series.XValues, v.RatingPeriodStartedAt.Time())
+		series.YValues = append(series.YValues, v.Rating)
+	}
+
+	graph := chart.Chart{
+		Height:     300,
+		Width:      600,
+		Canvas:     chart.Style{FillColor: chart.ColorTransparent},
+		Background: chart.Style{FillColor: chart.ColorTransparent},
+		XAxis: chart.XAxis{
+			ValueFormatter: chart.TimeDateValueFormatter,
+		},
+		Series: []chart.Series{series},
+	}
+
+	s.cache(w, "public", 1*time.Hour)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := graph.Render(chart.SVG, w); err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// headToHead shows the record between two players.
+func (s *Server) headToHead(w http.ResponseWriter, r *http.Request) {
+	a, err := urlPlayerName(r, "a")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+	b, err := urlPlayerName(r, "b")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+	if a == b {
+		s.error(w, r, errors.New("a player cannot face themselves"), http.StatusNotFound)
+		return
+	}
+
+	h2h, err := s.back.GetHeadToHead(a, b)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	s.cache(w, "public", 5*time.Minute)
+	s.response(w, r, http.StatusOK, "h2h.html", struct {
+		back.HeadToHead
+		TrendDays int
+	}{h2h, back.RatingTrendDays})
+}
+
+// urlPlayerName returns the player name given in the URL parameter of the
+// given name, see tplPlayerURI.
+func urlPlayerName(r *http.Request, param string) (string, error) {
+	name := chi.URLParam(r, param)
+	if r.URL.RawPath != "" { // chi routes on the raw path if the name needed escaping
+		var err error
+		if name, err = url.PathUnescape(name); err != nil {
+			return "", err
+		}
+	}
+
+	if name == "" {
+		return "", errors.New("empty player name in URL")
+	}
+
+	return name, nil
+}
+
+// tplPlayerURI returns the URI of the profile of the given player.
+func tplPlayerURI(locale, name string) string {
+	return "/" + locale + "/players/" + url.PathEscape(name)
+}
+
+// tplHeadToHeadURI returns the URI of the record between two players.
+func tplHeadToHeadURI(locale, a, b string) string {
+	return "/" + locale + "/h2h/" + url.PathEscape(a) + "/" + url.PathEscape(b)
+}
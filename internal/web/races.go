@@ -10,7 +10,6 @@ import (
 	"kaepora/internal/util"
 	"log"
 	"net/http"
-	"reflect"
 	"sort"
 	"time"
 
@@ -114,6 +113,7 @@ func (s *Server) getSpoilerLog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.URL.Query().Get("raw") == "1" {
+		s.cache(w, "public", 1*time.Hour)
 		s.sendRawSpoilerLog(w, league, match, raw)
 		return
 	}
@@ -140,7 +140,6 @@ func (s *Server) getSpoilerLog(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) sendRawSpoilerLog(w http.ResponseWriter, league back.League, match back.Match, raw []byte) {
-	s.cache(w, "public", 1*time.Hour)
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set(
 		"Content-Disposition",
@@ -200,7 +199,33 @@ func (s *Server) leaderboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	leaderboard, err := s.back.GetLeaderboardForShortcode(shortcode, back.DeviationThreshold)
+	season, tabs, err := s.requestSeason(r, shortcode, true)
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	var (
+		leaderboard, provisional []back.LeaderboardEntry
+		period                   back.LeaderboardPeriod
+	)
+	if v := r.URL.Query().Get("period"); v != "" && season.ID.IsZero() {
+		at, err := parsePeriodParam(v)
+		if err != nil {
+			s.error(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		period, err = s.back.GetLeaderboardAtForShortcode(shortcode, at)
+		leaderboard = period.Entries
+	} else if season.ID.IsZero() {
+		leaderboard, err = s.back.GetLeaderboardForShortcode(shortcode)
+		if err == nil {
+			provisional, err = s.back.GetProvisionalPlayersForShortcode(shortcode)
+		}
+	} else {
+		leaderboard, err = s.back.GetSeasonLeaderboard(season.ID)
+	}
 	if err != nil {
 		s.error(w, r, err, http.StatusInternalServerError)
 		return
@@ -209,8 +234,43 @@ func (s *Server) leaderboard(w http.ResponseWriter, r *http.Request) {
 	s.cache(w, "public", 5*time.Minute)
 	s.response(w, r, http.StatusOK, "leaderboard.html", struct {
 		League      back.League
-		Leaderboard []back.LeaderboardEntry
-	}{league, leaderboard})
+		Season      back.Season
+		SeasonTabs  []seasonTab
+		Divisions   []leaderboardDivision
+		Provisional []back.LeaderboardEntry
+		Period      back.LeaderboardPeriod // zero unless looking at a past leaderboard
+	}{league, season, tabs, groupLeaderboardByDivision(leaderboard), provisional, period})
+}
+
+// parsePeriodParam parses the date of the rating period to display, a day is
+// enough for fixed-length periods but session periods need the exact time.
+func parsePeriodParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", v)
+}
+
+// leaderboardDivision is the part of a leaderboard for a single division,
+// leagues without divisions have a single unnamed one.
+type leaderboardDivision struct {
+	Name    string
+	Entries []back.LeaderboardEntry
+}
+
+// groupLeaderboardByDivision splits a leaderboard sorted by division.
+func groupLeaderboardByDivision(entries []back.LeaderboardEntry) []leaderboardDivision {
+	var ret []leaderboardDivision
+	for k := range entries {
+		if k == 0 || entries[k].DivisionName != entries[k-1].DivisionName {
+			ret = append(ret, leaderboardDivision{Name: entries[k].DivisionName})
+		}
+
+		ret[len(ret)-1].Entries = append(ret[len(ret)-1].Entries, entries[k])
+	}
+
+	return ret
 }
 
 func (s *Server) schedule(w http.ResponseWriter, r *http.Request) {
@@ -251,48 +311,25 @@ func (s *Server) getSchedulesBetween(start, end time.Time) ([]scheduleEntry, err
 		return nil, err
 	}
 
-	lastFoundStart := map[util.UUIDAsBlob]time.Time{}
 	var ret []scheduleEntry
+	for _, league := range leagues {
+		dates, err := league.Schedule.Between(start, end)
+		if err != nil {
+			log.Printf("error: ignored schedule of league %s: %v", league.ShortCode, err)
+			continue
+		}
 
-	for i := start; i.Before(end); {
-		least := end
-
-		for k := range leagues {
-			next := leagues[k].Schedule.NextBetween(i, end)
-			if next.IsZero() || next == lastFoundStart[leagues[k].ID] {
-				continue
-			}
-			lastFoundStart[leagues[k].ID] = next
-
-			if next.Before(least) {
-				least = next
-			}
-
+		for _, v := range dates {
 			ret = append(ret, scheduleEntry{
-				LeagueName: leagues[k].Name,
-				StartDate:  next,
+				LeagueName: league.Name,
+				StartDate:  v,
 			})
 		}
-
-		i = least
 	}
 
 	sort.Sort(sortByDate(ret))
 
-	// HACK: There's definitely something wrong in the schedule generation as
-	// duplicates are not supposed to happen. TODO
-	deduped := make([]scheduleEntry, 0, len(ret))
-	for _, v := range ret {
-		if len(deduped) > 0 {
-			if reflect.DeepEqual(deduped[len(deduped)-1], v) {
-				continue
-			}
-		}
-
-		deduped = append(deduped, v)
-	}
-
-	return deduped, nil
+	return ret, nil
 }
 
 type scheduleEntry struct {
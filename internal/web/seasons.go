@@ -0,0 +1,79 @@
+package web
+
+import (
+	"fmt"
+	"kaepora/internal/back"
+	"kaepora/internal/util"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// seasonTab is a link of the season selector, the tab without ID links to
+// the current or all time data.
+type seasonTab struct {
+	ID      string
+	Name    string
+	AllTime bool
+	Active  bool
+}
+
+// requestSeason returns the season selected with the "season" query
+// parameter, or the zero Season if none is, and the tabs of the season
+// selector. closedOnly restricts the selector to seasons with a frozen
+// leaderboard.
+func (s *Server) requestSeason(r *http.Request, shortcode string, closedOnly bool) (
+	back.Season, []seasonTab, error,
+) {
+	var selected back.Season
+	if str := r.URL.Query().Get("season"); str != "" {
+		uid, err := uuid.Parse(str)
+		if err != nil {
+			return back.Season{}, nil, err
+		}
+
+		selected, err = s.back.GetSeason(util.UUIDAsBlob(uid))
+		if err != nil {
+			return back.Season{}, nil, err
+		}
+	}
+
+	seasons, err := s.back.GetSeasons(shortcode)
+	if err != nil {
+		return back.Season{}, nil, err
+	}
+
+	tabs := []seasonTab{{AllTime: !closedOnly, Active: selected.ID.IsZero()}}
+	found := selected.ID.IsZero()
+	for _, v := range seasons {
+		if v.StartDate.Time().After(time.Now()) || (closedOnly && !v.IsClosed()) {
+			continue
+		}
+
+		active := v.ID == selected.ID
+		found = found || active
+		tabs = append(tabs, seasonTab{ID: v.ID.String(), Name: v.Name, Active: active})
+	}
+
+	if !found {
+		return back.Season{}, nil, fmt.Errorf("season %s not found in league %s", selected.ID, shortcode)
+	}
+
+	// Don't bother showing a selector with a single choice.
+	if len(tabs) < 2 {
+		tabs = nil
+	}
+
+	return selected, tabs, nil
+}
+
+// seasonQueryID returns the value of the "season" query parameter selecting
+// the given season, empty for the zero Season.
+func seasonQueryID(season back.Season) string {
+	if season.ID.IsZero() {
+		return ""
+	}
+
+	return season.ID.String()
+}
@@ -8,6 +8,7 @@ import (
 	"html/template"
 	"io/ioutil"
 	"kaepora/internal/back"
+	"kaepora/internal/config"
 	"kaepora/internal/util"
 	"log"
 	"net/http"
@@ -38,27 +39,90 @@ func (s *Server) setupRouter(baseDir string) *chi.Mux {
 	fs := http.StripPrefix("/_/", http.FileServer(http.Dir(
 		filepath.Join(baseDir, "static"),
 	)))
-	r.HandleFunc("/_/*", func(w http.ResponseWriter, r *http.Request) {
+	r.With(writeTimeout).HandleFunc("/_/*", func(w http.ResponseWriter, r *http.Request) {
 		s.cache(w, "public", 1*time.Hour)
 		fs.ServeHTTP(w, r)
 	})
 
-	r.Get("/favicon.ico", s.favicon(fs))
+	r.With(writeTimeout).Get("/favicon.ico", s.favicon(fs))
 
-	r.Get("/dev/settings-relations.svg", s.devSettingsRelations)
+	r.With(writeTimeout).Get("/dev/settings-relations.svg", s.devSettingsRelations)
 
-	r.With(s.langDetect).Route("/{locale}", func(r chi.Router) {
+	r.Route("/api/v1", s.setupAPIRouter)
+
+	r.With(writeTimeout, s.overlayLocale).Route("/overlay", func(r chi.Router) {
+		r.Get("/players/{name}", s.overlayPlayer)
+		r.Get("/matches/{id}", s.overlayMatch)
+	})
+
+	// Reranking a whole league takes longer than writeTimeout allows, the
+	// admin would get an error page while the rerank still goes through.
+	r.With(s.langDetect, s.authenticate, s.requirePlayer, s.requireAdmin, s.checkCSRF).
+		Post("/{locale}/admin/leagues/{shortcode}/rerank", s.postAdminRerank)
+
+	r.With(writeTimeout, s.langDetect).Route("/{locale}", func(r chi.Router) {
 		r.Get("/rules", s.markdownContent(baseDir, "rules.md"))
 		r.Get("/documentation", s.markdownContent(baseDir, "documentation.md"))
 
 		r.Get("/leaderboard/{shortcode}", s.leaderboard)
+		r.Get("/feed/{shortcode}.atom", s.feed)
+		r.Get("/players/{name}", s.playerProfile)
+		r.Get("/players/{name}/{shortcode}/ratings.svg", s.playerRatings)
+		r.Get("/players/{name}/{shortcode}/times.svg", s.playerTimes)
+		r.Get("/h2h/{a}/{b}", s.headToHead)
 
 		r.Get("/sessions", s.getAllMatchSession)
 		r.Get("/sessions/{id}", s.getOneMatchSession)
 		r.Get("/matches/{id}/spoilers", s.getSpoilerLog)
 
+		r.With(s.authenticate).Get("/login", s.login)
+		r.Post("/login", s.postLogin)
+		r.With(s.authenticate, s.requirePlayer, s.checkCSRF).Post("/logout", s.logout)
+		r.With(s.authenticate, s.requirePlayer).Route("/account", func(r chi.Router) {
+			r.Get("/", s.account)
+			r.Get("/settings", s.accountSettings)
+			r.Get("/matches/{id}/seed", s.accountSeed)
+			r.Get("/matches/{id}/spoilers", s.accountSpoilerLog)
+
+			r.Group(func(r chi.Router) {
+				r.Use(s.checkCSRF)
+				r.Post("/settings", s.postAccountSettings)
+				r.Post("/join", s.accountAction("joined", s.accountJoin))
+				r.Post("/cancel", s.accountAction("cancelled", s.accountCancel))
+				r.Post("/done", s.accountAction("completed", s.accountComplete))
+				r.Post("/forfeit", s.accountAction("forfeited", s.accountForfeit))
+				r.Post("/polls/vote", s.accountAction("voted", s.accountVote))
+			})
+		})
+		r.With(s.authenticate, s.requirePlayer, s.requireAdmin).Route("/admin", func(r chi.Router) {
+			r.Get("/", s.admin)
+			r.Get("/sessions", s.adminSessions)
+			r.Get("/leagues/new", s.adminNewLeague)
+			r.Get("/leagues/{shortcode}", s.adminLeague)
+
+			r.Group(func(r chi.Router) {
+				r.Use(s.checkCSRF)
+				r.Post("/games", s.postAdminGame)
+				r.Post("/games/{id}", s.postAdminGameRename)
+				r.Post("/leagues/new", s.postAdminNewLeague)
+				r.Post("/leagues/{shortcode}", s.postAdminLeague)
+				r.Post("/sessions", s.postAdminSession)
+				r.Route("/sessions/{id}", func(r chi.Router) {
+					r.Post("/postpone", s.adminSessionAction("race", s.adminSessionPostpone))
+					r.Post("/cancel", s.adminSessionAction("race", s.adminSessionCancel))
+					r.Post("/add", s.adminSessionAction("race", s.adminSessionAdd))
+					r.Post("/kick", s.adminSessionAction("race", s.adminSessionKick))
+					r.Post("/reseed", s.adminSessionAction("reseed", s.adminSessionReseed))
+				})
+			})
+		})
+
 		r.Get("/schedule", s.schedule)
+		r.Get("/schedule.ics", s.calendarAll)
+		r.Get("/schedule/{shortcode}.ics", s.calendarLeague)
 		r.Get("/stats/{shortcode}/ratings.svg", s.statsRatings)
+		r.Get("/stats/{shortcode}/times.svg", s.statsTimes)
+		r.Get("/stats/{shortcode}/median-times.svg", s.statsMedianTimes)
 		r.Get("/stats/{shortcode}", s.stats)
 
 		// TODO Remove this at some point.
@@ -71,14 +135,26 @@ func (s *Server) setupRouter(baseDir string) *chi.Mux {
 		r.NotFound(s.notFound)
 	})
 
-	r.Get("/", s.redirectToLocale)
+	r.With(writeTimeout).Get("/", s.redirectToLocale)
 
 	return r
 }
 
+// writeTimeout replaces the http.Server WriteTimeout that would otherwise
+// cut the live streams, every non-streaming route must use it.
+func writeTimeout(next http.Handler) http.Handler {
+	return http.TimeoutHandler(next, 5*time.Second, "")
+}
+
+// publicURL is the address of the website where absolute URLs are required.
+const publicURL = "https://ootrladder.com"
+
 type ctxKey int
 
-const ctxKeyLocale ctxKey = iota
+const (
+	ctxKeyLocale ctxKey = iota
+	ctxKeyPlayer        // back.Player, see authenticate
+)
 
 func chooseLocale(candidates ...string) string {
 	matcher := language.NewMatcher([]language.Tag{
@@ -126,8 +202,11 @@ type Server struct {
 	tpl     map[string]*template.Template // Indexed by file name (eg. "index.html")
 	locales map[string]*gotext.Locale     // Indexed by lowercase ISO 639-2 (eg. "fr")
 
-	// Secret key for HMAC token verification
-	tokenKey string
+	// Secret key for HMAC token verification, shared with the bot.
+	tokenKey []byte
+
+	// Read once on startup, only used for the list of admins.
+	config *config.Config
 }
 
 func NewServer(back *back.Back, tokenKey string) (*Server, error) {
@@ -136,15 +215,22 @@ func NewServer(back *back.Back, tokenKey string) (*Server, error) {
 		return nil, err
 	}
 
+	conf, err := config.NewFromUserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Server{
-		tokenKey: tokenKey,
+		config:   conf,
+		tokenKey: []byte(tokenKey),
 		back:     back,
 		locales:  map[string]*gotext.Locale{},
 		http: &http.Server{
-			Addr:         "127.0.0.1:3001",
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
-			IdleTimeout:  10 * time.Second,
+			Addr:        "127.0.0.1:3001",
+			ReadTimeout: 5 * time.Second,
+			IdleTimeout: 10 * time.Second,
+			// No WriteTimeout, see writeTimeout. Slow clients are handled by
+			// the reverse proxy.
 		},
 	}
 
@@ -218,13 +304,30 @@ func (s *Server) response(
 		return
 	}
 
+	maintenance, err := s.back.GetMaintenance()
+	if err != nil {
+		log.Printf("error: %s", err)
+		return
+	}
+
+	var paused []back.League
+	for _, v := range leagues {
+		if v.Paused {
+			paused = append(paused, v)
+		}
+	}
+
 	wrapped := struct {
-		Locale  string
-		Leagues []back.League
-		Payload interface{}
+		Locale        string
+		Leagues       []back.League
+		Maintenance   back.Maintenance
+		PausedLeagues []back.League
+		Payload       interface{}
 	}{
 		r.Context().Value(ctxKeyLocale).(string),
 		leagues,
+		maintenance,
+		paused,
 		payload,
 	}
 
@@ -14,20 +14,32 @@ import (
 func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	shortcode := chi.URLParam(r, "shortcode")
-	misc, err := s.back.GetMiscStats(shortcode)
+	season, tabs, err := s.requestSeason(r, shortcode, false)
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	misc, err := s.back.GetMiscStats(shortcode, season)
 	if err != nil {
 		s.error(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	log.Printf("info: computed misc stats in %s", time.Since(start))
 
-	seed, err := s.getSeedStats(shortcode)
+	seed, err := s.getSeedStats(shortcode, season)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	attendance, err := s.getAttendanceStats(shortcode, season)
 	if err != nil {
 		s.error(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	attendance, err := s.getAttendanceStats(shortcode)
+	times, err := s.getTimesStats(shortcode, season)
 	if err != nil {
 		s.error(w, r, err, http.StatusInternalServerError)
 		return
@@ -38,9 +50,12 @@ func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
 		Misc          back.StatsMisc
 		Attendance    []attendanceEntry
 		Seed          statsSeed
+		Times         statsTimesData
 		ShortCode     string
+		SeasonID      string
+		SeasonTabs    []seasonTab
 		ExtendedStats bool
-	}{misc, attendance, seed, shortcode, shortcode == "shu"})
+	}{misc, attendance, seed, times, shortcode, seasonQueryID(season), tabs, shortcode == "shu"})
 }
 
 type attendanceEntry struct {
@@ -51,7 +66,7 @@ type attendanceEntry struct {
 	Counted     [7]int    // sessions counted in this slot
 }
 
-func (s *Server) getAttendanceStats(shortcode string) ([]attendanceEntry, error) {
+func (s *Server) getAttendanceStats(shortcode string, season back.Season) ([]attendanceEntry, error) {
 	start := time.Now()
 	defer func() { log.Printf("info: computed attendance in %s", time.Since(start)) }()
 
@@ -64,7 +79,7 @@ func (s *Server) getAttendanceStats(shortcode string) ([]attendanceEntry, error)
 	}
 
 	max := math.MinInt64
-	if err := s.back.MapMatchSessions(shortcode, func(m back.MatchSession) error {
+	if err := s.back.MapMatchSessions(shortcode, season, func(m back.MatchSession) error {
 		players := len(m.PlayerIDs)
 		if players > max {
 			max = players
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"kaepora/internal/back"
 	"log"
 	"math"
 	"net/http"
@@ -17,7 +18,13 @@ func (s *Server) statsRatings(w http.ResponseWriter, r *http.Request) {
 	shortcode := chi.URLParam(r, "shortcode")
 	defer func() { log.Printf("info: computed ratings stats in %s", time.Since(start)) }()
 
-	bars, maxValue, err := s.getRatingsStats(shortcode, chart.Style{
+	season, _, err := s.requestSeason(r, shortcode, false)
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	bars, maxValue, err := s.getRatingsStats(shortcode, season, chart.Style{
 		FontColor:   drawing.ColorBlack,
 		FillColor:   drawing.ColorFromHex("285577"),
 		StrokeColor: drawing.ColorFromHex("4c7899"),
@@ -56,11 +63,12 @@ func (s *Server) statsRatings(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) getRatingsStats(
 	shortcode string,
+	season back.Season,
 	barStyle chart.Style,
 ) (
 	[]chart.Value, float64, error,
 ) {
-	ratings, err := s.back.GetPlayerRatings(shortcode)
+	ratings, err := s.back.GetPlayerRatings(shortcode, season)
 	if err != nil {
 		return nil, 0, err
 	}
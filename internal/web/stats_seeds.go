@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"kaepora/internal/back"
 	"kaepora/internal/generator/oot"
 	"log"
 	"sort"
@@ -59,7 +60,7 @@ func (a byName) Swap(i, j int) {
 
 // TODO: fix funlen.
 // nolint:funlen
-func (s *Server) getSeedStats(shortcode string) (statsSeed, error) {
+func (s *Server) getSeedStats(shortcode string, season back.Season) (statsSeed, error) {
 	start := time.Now()
 	seedTotal := 0
 
@@ -69,7 +70,7 @@ func (s *Server) getSeedStats(shortcode string) (statsSeed, error) {
 	settings := map[string]map[string]int{} // name => value => count
 	locationsAcc := map[string]map[oot.SpoilerLogItemCategory]int{}
 
-	if err := s.back.MapSpoilerLogs(shortcode, func(raw io.Reader) error {
+	if err := s.back.MapSpoilerLogs(shortcode, season, func(raw io.Reader) error {
 		seedTotal++
 
 		var l oot.SpoilerLog
@@ -0,0 +1,247 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"kaepora/internal/back"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+const (
+	timesBinWidth      = 10 * time.Minute
+	leagueRecordsCount = 10
+	personalBestsCount = 50
+)
+
+// statsTimes draws the distribution of finish times in a league.
+func (s *Server) statsTimes(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	shortcode := chi.URLParam(r, "shortcode")
+	defer func() { log.Printf("info: computed finish times stats in %s", time.Since(start)) }()
+
+	season, _, err := s.requestSeason(r, shortcode, false)
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	times, err := s.back.GetFinishTimes(shortcode, season)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(times) == 0 {
+		s.error(w, r, errors.New("no finish time"), http.StatusNotFound)
+		return
+	}
+
+	bars, maxValue := getFinishTimesBars(times, chart.Style{
+		FontColor:   drawing.ColorBlack,
+		FillColor:   drawing.ColorFromHex("285577"),
+		StrokeColor: drawing.ColorFromHex("4c7899"),
+		StrokeWidth: 1,
+	})
+
+	graph := chart.BarChart{
+		Height: 300,
+		Width:  600,
+		Canvas: chart.Style{FillColor: chart.ColorTransparent},
+		Background: chart.Style{
+			FillColor: chart.ColorTransparent,
+		},
+		YAxis: chart.YAxis{
+			Ticks: []chart.Tick{
+				{Value: 0},
+				{Value: maxValue},
+			},
+		},
+		Bars: bars,
+	}
+	graph.BarWidth = (graph.Width - (len(bars) * graph.BarSpacing)) / len(bars)
+
+	s.cache(w, "public", 1*time.Hour)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := graph.Render(chart.SVG, w); err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// getFinishTimesBars bins the given finish times in timesBinWidth wide bars,
+// each bar being the ratio of times in its bin.
+func getFinishTimesBars(times back.FinishTimes, barStyle chart.Style) ([]chart.Value, float64) {
+	bins := map[int]int{}
+	minBin, maxBin := -1, -1
+	maxValue := 0
+
+	for _, v := range times {
+		bin := int(v.Duration() / timesBinWidth)
+		bins[bin]++
+		if minBin < 0 || bin < minBin {
+			minBin = bin
+		}
+		if bin > maxBin {
+			maxBin = bin
+		}
+		if bins[bin] > maxValue {
+			maxValue = bins[bin]
+		}
+	}
+
+	bars := make([]chart.Value, 0, maxBin-minBin+1)
+	for i := minBin; i <= maxBin; i++ {
+		bars = append(bars, chart.Value{
+			Value: float64(bins[i]) / float64(len(times)),
+			Label: formatHoursMinutes(time.Duration(i) * timesBinWidth),
+			Style: barStyle,
+		})
+	}
+
+	return bars, float64(maxValue) / float64(len(times))
+}
+
+// statsMedianTimes draws the weekly median finish time of a league.
+func (s *Server) statsMedianTimes(w http.ResponseWriter, r *http.Request) {
+	shortcode := chi.URLParam(r, "shortcode")
+	season, _, err := s.requestSeason(r, shortcode, false)
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	times, err := s.back.GetFinishTimes(shortcode, season)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	medians := times.WeeklyMedians()
+	series := chart.TimeSeries{
+		Name:    "median",
+		Style:   chart.Style{StrokeColor: drawing.ColorFromHex("4c7899"), StrokeWidth: 2},
+		XValues: make([]time.Time, 0, len(medians)),
+		YValues: make([]float64, 0, len(medians)),
+	}
+	for _, v := range medians {
+		series.XValues = append(series.XValues, v.Week)
+		series.YValues = append(series.YValues, float64(v.Median))
+	}
+
+	s.renderTimesChart(w, r, series)
+}
+
+// playerTimes draws the finish times of a player in a league alongside their
+// personal best at the time.
+func (s *Server) playerTimes(w http.ResponseWriter, r *http.Request) {
+	name, err := urlPlayerName(r, "name")
+	if err != nil {
+		s.error(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	times, err := s.back.GetPlayerFinishTimes(name, chi.URLParam(r, "shortcode"))
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	finishes := chart.TimeSeries{
+		Name: "times",
+		Style: chart.Style{
+			StrokeColor: drawing.ColorFromHex("4c7899").WithAlpha(128),
+			StrokeWidth: 1,
+			DotColor:    drawing.ColorFromHex("4c7899"),
+			DotWidth:    3,
+		},
+	}
+	bests := chart.TimeSeries{
+		Name:  "best",
+		Style: chart.Style{StrokeColor: drawing.ColorFromHex("285577"), StrokeWidth: 2},
+	}
+
+	var best time.Duration
+	for _, v := range times {
+		if d := v.Duration(); best == 0 || d < best {
+			best = d
+		}
+
+		finishes.XValues = append(finishes.XValues, v.StartedAt.Time())
+		finishes.YValues = append(finishes.YValues, float64(v.Duration()))
+		bests.XValues = append(bests.XValues, v.StartedAt.Time())
+		bests.YValues = append(bests.YValues, float64(best))
+	}
+
+	s.renderTimesChart(w, r, finishes, bests)
+}
+
+// renderTimesChart draws durations over time, go-chart can't draw a range out
+// of a single value so series need at least two values.
+func (s *Server) renderTimesChart(w http.ResponseWriter, r *http.Request, series ...chart.TimeSeries) {
+	if len(series) == 0 || len(series[0].XValues) < 2 {
+		s.error(w, r, errors.New("not enough finish times"), http.StatusNotFound)
+		return
+	}
+
+	graph := chart.Chart{
+		Height:     300,
+		Width:      600,
+		Canvas:     chart.Style{FillColor: chart.ColorTransparent},
+		Background: chart.Style{FillColor: chart.ColorTransparent},
+		XAxis: chart.XAxis{
+			ValueFormatter: chart.TimeDateValueFormatter,
+		},
+		YAxis: chart.YAxis{
+			ValueFormatter: func(v interface{}) string {
+				if f, ok := v.(float64); ok {
+					return formatHoursMinutes(time.Duration(f))
+				}
+				return ""
+			},
+		},
+	}
+	for _, v := range series {
+		graph.Series = append(graph.Series, v)
+	}
+
+	s.cache(w, "public", 1*time.Hour)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := graph.Render(chart.SVG, w); err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// formatHoursMinutes formats a duration as "1h05", seconds are dropped.
+func formatHoursMinutes(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	return fmt.Sprintf("%dh%02d", d/time.Hour, (d%time.Hour)/time.Minute)
+}
+
+// statsTimesData is the finish times part of the stats page.
+type statsTimesData struct {
+	Records       []back.FinishTime
+	PersonalBests []back.PersonalBest
+}
+
+func (s *Server) getTimesStats(shortcode string, season back.Season) (statsTimesData, error) {
+	times, err := s.back.GetFinishTimes(shortcode, season)
+	if err != nil {
+		return statsTimesData{}, err
+	}
+
+	bests := times.PersonalBests()
+	if len(bests) > personalBestsCount {
+		bests = bests[:personalBestsCount]
+	}
+
+	return statsTimesData{
+		Records:       times.Records(leagueRecordsCount),
+		PersonalBests: bests,
+	}, nil
+}
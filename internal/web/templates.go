@@ -90,12 +90,27 @@ func (s *Server) getTemplateFuncMap(baseDir string) template.FuncMap {
 		"datetime":       util.Datetime,
 		"future":         tplFuture,
 		"percentage":     tplPercentage,
+		"probability":    tplProbability,
+		"signedDuration": tplSignedDuration,
+		"playerURI":      tplPlayerURI,
+		"h2hURI":         tplHeadToHeadURI,
+		"overlayURI":     tplOverlayURI,
 		"ranking":        tplRanking,
 		"until":          tplUntil,
 
+		"apiSessionStatus": apiSessionStatus,
+
 		"add": func(a, b int) int {
 			return a + b
 		},
+
+		"abs": func(a int) int {
+			if a < 0 {
+				return -a
+			}
+
+			return a
+		},
 	}
 }
 
@@ -112,6 +127,18 @@ func tplPercentage(x int, parts ...int) string {
 	return fmt.Sprintf("%d %%", int(math.Round(float64(x)/float64(total)*100.0)))
 }
 
+func tplProbability(p float64) string {
+	return fmt.Sprintf("%d %%", int(math.Round(p*100.0)))
+}
+
+func tplSignedDuration(d time.Duration) string {
+	if d > 0 {
+		return "+" + d.String()
+	}
+
+	return d.String()
+}
+
 func (s *Server) tplMatchSessionStatusTag(locale string, status back.MatchSessionStatus) template.HTML {
 	var str, class string
 	switch status {
@@ -10,8 +10,10 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -48,7 +50,18 @@ func main() {
 			log.Fatal(err)
 		}
 	case "rerank":
-		if err := back.Rerank(flag.Arg(1)); err != nil {
+		if err := rerank(back, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "maintenance":
+		if err := maintenance(back, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "pause", "resume":
+		if flag.NArg() != 2 {
+			log.Fatalf("usage: %s %s SHORTCODE", os.Args[0], flag.Arg(0))
+		}
+		if err := back.SetLeaguePaused(flag.Arg(1), flag.Arg(0) == "pause"); err != nil {
 			log.Fatal(err)
 		}
 	default:
@@ -70,18 +83,95 @@ COMMANDS
     serve       start the Discord bot
     version     display the current version
 
-    rerank SHORTCODE  recompute all rankings in a league
+    maintenance [on [MESSAGE]|off]
+                show or toggle the maintenance mode, while enabled no race
+                is created or run and players cannot join races, MESSAGE is
+                shown to the players
+
+    pause SHORTCODE
+    resume SHORTCODE
+                stop or restart creating and running the races of a league,
+                players cannot join its races while paused
+
+    rerank [-dry-run] [-since YYYY-MM-DD] SHORTCODE
+                recompute the rankings of a league in a single transaction
+                and print the rating and rank changes, -since only recomputes
+                the rating periods from the given date onward, -dry-run
+                prints the changes without saving them
 `,
 		os.Args[0],
 	)
 }
 
+func rerank(b *back.Back, args []string) error {
+	flags := flag.NewFlagSet("rerank", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the changes without saving them")
+	sinceStr := flags.String("since", "", "only recompute rating periods from this date (YYYY-MM-DD) onward")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s rerank [-dry-run] [-since YYYY-MM-DD] SHORTCODE", os.Args[0])
+	}
+
+	var since time.Time
+	if *sinceStr != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", *sinceStr); err != nil {
+			return err
+		}
+	}
+
+	diff, err := b.Rerank(flags.Arg(0), since, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range diff {
+		if v.Changed() {
+			fmt.Fprintln(os.Stdout, v.String())
+		}
+	}
+
+	return nil
+}
+
+func maintenance(b *back.Back, args []string) error {
+	if len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "on":
+			err = b.SetMaintenance(true, strings.Join(args[1:], " "))
+		case "off":
+			err = b.SetMaintenance(false, "")
+		default:
+			err = fmt.Errorf("usage: %s maintenance [on [MESSAGE]|off]", os.Args[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	m, err := b.GetMaintenance()
+	if err != nil {
+		return err
+	}
+
+	if m.Enabled {
+		fmt.Fprintf(os.Stdout, "maintenance mode enabled since %s: %q\n", m.UpdatedAt.Time(), m.Message)
+	} else {
+		fmt.Fprintln(os.Stdout, "maintenance mode disabled")
+	}
+
+	return nil
+}
+
 func serve(b *back.Back) error {
 	done := make(chan struct{})
 	signaled := make(chan os.Signal, 1)
 	signal.Notify(signaled, syscall.SIGINT, syscall.SIGTERM)
 
-	bot, err := bot.New(b, os.Getenv("KAEPORA_DISCORD_TOKEN"))
+	bot, err := bot.New(b, os.Getenv("KAEPORA_DISCORD_TOKEN"), os.Getenv("KAEPORA_WEB_TOKEN_KEY"))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,39 @@
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE "backup_MatchSession" (
+  "ID" blob NOT NULL,
+  "LeagueID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "StartDate" text NOT NULL,
+  "Status" integer NOT NULL,
+  "PlayerIDs" text NOT NULL,
+  PRIMARY KEY ("ID"),
+  FOREIGN KEY ("LeagueID") REFERENCES "League" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_MatchSession" ("ID", "LeagueID", "CreatedAt", "StartDate", "Status", "PlayerIDs") SELECT "ID", "LeagueID", "CreatedAt", "StartDate", "Status", "PlayerIDs" FROM "MatchSession";
+DROP TABLE "MatchSession";
+ALTER TABLE "backup_MatchSession" RENAME TO "MatchSession";
+CREATE INDEX "idx_Status" ON "MatchSession" ("Status");
+
+CREATE TABLE "backup_League" (
+  "ID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "Name" text NOT NULL,
+  "ShortCode" text NOT NULL,
+  "GameID" blob NOT NULL,
+  "Settings" text NOT NULL,
+  "Schedule" text NOT NULL,
+  "AnnounceDiscordChannelID" text NULL,
+  "Generator" text NOT NULL DEFAULT '',
+  PRIMARY KEY ("ID"),
+  FOREIGN KEY ("GameID") REFERENCES "Game" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_League" ("ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator") SELECT "ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator" FROM "League";
+DROP TABLE "League";
+ALTER TABLE "backup_League" RENAME TO "League";
+CREATE UNIQUE INDEX "idx_unique_ShortCode" ON "League" ("ShortCode");
+
+DROP TABLE "PlayerDivision";
+DROP TABLE "Division";
+
+PRAGMA foreign_keys = ON;
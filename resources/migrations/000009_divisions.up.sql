@@ -0,0 +1,38 @@
+CREATE TABLE "Division" (
+    "ID"        blob(16) NOT NULL,
+    "LeagueID"  blob(16) NOT NULL,
+    "CreatedAt" INT      NOT NULL,
+    "Name"      TEXT     NOT NULL,
+
+    -- 0 is the top division, each lower division has a higher tier.
+    "Tier" INT NOT NULL,
+
+    -- How many players leave the division at the end of each rating period.
+    -- Promoted players go one tier up, relegated players one tier down.
+    "PromotionCount"  INT NOT NULL DEFAULT 0,
+    "RelegationCount" INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY ("ID"),
+    FOREIGN KEY(LeagueID) REFERENCES League(ID) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+CREATE UNIQUE INDEX idx_unique_LeagueTier ON Division (LeagueID, Tier);
+
+CREATE TABLE "PlayerDivision" (
+    "PlayerID"   blob(16) NOT NULL,
+    "LeagueID"   blob(16) NOT NULL,
+    "DivisionID" blob(16) NOT NULL,
+    "CreatedAt"  INT      NOT NULL,
+
+    PRIMARY KEY ("PlayerID", "LeagueID"),
+    FOREIGN KEY(PlayerID)   REFERENCES Player(ID)   ON UPDATE CASCADE ON DELETE RESTRICT,
+    FOREIGN KEY(LeagueID)   REFERENCES League(ID)   ON UPDATE CASCADE ON DELETE RESTRICT,
+    FOREIGN KEY(DivisionID) REFERENCES Division(ID) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+-- NULL for leagues without divisions.
+ALTER TABLE "MatchSession" ADD "DivisionID" blob(16) NULL REFERENCES Division(ID) ON UPDATE CASCADE ON DELETE RESTRICT;
+
+-- Start of the rating period that was running at the last periodic check,
+-- used to detect the end of a period.
+ALTER TABLE "League" ADD "RatingPeriodStartedAt" INT NULL;
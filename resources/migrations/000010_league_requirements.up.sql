@@ -0,0 +1,6 @@
+-- JSON, see LeagueRequirements, an empty object lets everyone join.
+ALTER TABLE "League" ADD "Requirements" TEXT NOT NULL DEFAULT '{}';
+
+-- JSON array of Player.ID that tried to join a full session, sorted by join
+-- date. They take the spot of players who cancel.
+ALTER TABLE "MatchSession" ADD "WaitlistPlayerIDs" TEXT NOT NULL DEFAULT '[]';
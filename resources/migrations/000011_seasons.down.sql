@@ -0,0 +1,2 @@
+DROP TABLE "SeasonLeaderboard";
+DROP TABLE "Season";
@@ -0,0 +1,45 @@
+CREATE TABLE "Season" (
+    "ID"        blob(16) NOT NULL,
+    "LeagueID"  blob(16) NOT NULL,
+    "CreatedAt" INT      NOT NULL,
+    "Name"      TEXT     NOT NULL,
+
+    -- Both dates are the start of a rating period, EndDate is excluded.
+    "StartDate" INT NOT NULL,
+    "EndDate"   INT NOT NULL,
+
+    -- Applied to ratings when the season starts.
+    -- 0: SeasonResetNone, 1: SeasonResetHard, 2: SeasonResetSoft
+    "ResetMode" INT NOT NULL DEFAULT 0,
+    -- Soft reset only, fraction of the distance to the base rating kept by
+    -- players and deviation added to theirs.
+    "ResetFactor"        REAL NOT NULL DEFAULT 0,
+    "ResetDeviationBump" REAL NOT NULL DEFAULT 0,
+
+    -- Set once the final leaderboard has been written to SeasonLeaderboard.
+    "ClosedAt" INT NULL,
+
+    PRIMARY KEY ("ID"),
+    FOREIGN KEY(LeagueID) REFERENCES League(ID) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_Season_LeagueID ON Season (LeagueID);
+
+-- Frozen final leaderboard of a closed Season.
+CREATE TABLE "SeasonLeaderboard" (
+    "SeasonID" blob(16) NOT NULL,
+    "PlayerID" blob(16) NOT NULL,
+
+    "DivisionName" TEXT NOT NULL DEFAULT '',
+    "DivisionRank" INT  NOT NULL,
+    "Rating"       REAL NOT NULL,
+    "Deviation"    REAL NOT NULL,
+    "Wins"         INT  NOT NULL,
+    "Losses"       INT  NOT NULL,
+    "Draws"        INT  NOT NULL,
+    "Forfeits"     INT  NOT NULL,
+
+    PRIMARY KEY ("SeasonID", "PlayerID"),
+    FOREIGN KEY(SeasonID) REFERENCES Season(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+    FOREIGN KEY(PlayerID) REFERENCES Player(ID) ON UPDATE CASCADE ON DELETE RESTRICT
+);
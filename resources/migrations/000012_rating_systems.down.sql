@@ -0,0 +1,54 @@
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE "backup_League" (
+  "ID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "Name" text NOT NULL,
+  "ShortCode" text NOT NULL,
+  "GameID" blob NOT NULL,
+  "Settings" text NOT NULL,
+  "Schedule" text NOT NULL,
+  "AnnounceDiscordChannelID" text NULL,
+  "Generator" text NOT NULL DEFAULT '',
+  "RatingPeriodStartedAt" integer NULL,
+  "Requirements" text NOT NULL DEFAULT '{}',
+  PRIMARY KEY ("ID"),
+  FOREIGN KEY ("GameID") REFERENCES "Game" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_League" ("ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator", "RatingPeriodStartedAt", "Requirements") SELECT "ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator", "RatingPeriodStartedAt", "Requirements" FROM "League";
+DROP TABLE "League";
+ALTER TABLE "backup_League" RENAME TO "League";
+CREATE UNIQUE INDEX "idx_unique_ShortCode" ON "League" ("ShortCode");
+
+CREATE TABLE "backup_PlayerRating" (
+  "PlayerID" blob NOT NULL,
+  "LeagueID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "Rating" real NOT NULL,
+  "Deviation" real NOT NULL,
+  "Volatility" real NOT NULL,
+  PRIMARY KEY ("PlayerID", "LeagueID"),
+  FOREIGN KEY ("PlayerID") REFERENCES "Player" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE,
+  FOREIGN KEY ("LeagueID") REFERENCES "League" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_PlayerRating" ("PlayerID", "LeagueID", "CreatedAt", "Rating", "Deviation", "Volatility") SELECT "PlayerID", "LeagueID", "CreatedAt", "Rating", "Deviation", "Volatility" FROM "PlayerRating";
+DROP TABLE "PlayerRating";
+ALTER TABLE "backup_PlayerRating" RENAME TO "PlayerRating";
+
+CREATE TABLE "backup_PlayerRatingHistory" (
+  "PlayerID" blob NOT NULL,
+  "LeagueID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "RatingPeriodStartedAt" integer NOT NULL,
+  "Rating" real NOT NULL,
+  "Deviation" real NOT NULL,
+  "Volatility" real NOT NULL,
+  PRIMARY KEY ("PlayerID", "LeagueID", "RatingPeriodStartedAt"),
+  FOREIGN KEY ("PlayerID") REFERENCES "Player" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE,
+  FOREIGN KEY ("LeagueID") REFERENCES "League" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_PlayerRatingHistory" ("PlayerID", "LeagueID", "CreatedAt", "RatingPeriodStartedAt", "Rating", "Deviation", "Volatility") SELECT "PlayerID", "LeagueID", "CreatedAt", "RatingPeriodStartedAt", "Rating", "Deviation", "Volatility" FROM "PlayerRatingHistory";
+DROP TABLE "PlayerRatingHistory";
+ALTER TABLE "backup_PlayerRatingHistory" RENAME TO "PlayerRatingHistory";
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,6 @@
+-- See rating.SystemIDs.
+ALTER TABLE "League" ADD "RatingSystem" TEXT NOT NULL DEFAULT 'glicko2';
+
+-- System-specific state, Glicko-2 only uses Volatility and leaves it NULL.
+ALTER TABLE "PlayerRating" ADD "State" BLOB NULL;
+ALTER TABLE "PlayerRatingHistory" ADD "State" BLOB NULL;
@@ -0,0 +1,2 @@
+-- See back.RatingPeriods.
+ALTER TABLE "League" ADD "RatingPeriod" TEXT NOT NULL DEFAULT 'weekly';
@@ -0,0 +1,2 @@
+-- See back.LeagueLeaderboard.
+ALTER TABLE "League" ADD "Leaderboard" TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+DROP TABLE "WebSessionVersion";
+DROP TABLE "WebLogin";
@@ -0,0 +1,19 @@
+-- See back.CreateWebLogin, each !weblogin link can only be used once.
+CREATE TABLE "WebLogin" (
+    "Nonce"     TEXT     NOT NULL,
+    "PlayerID"  blob(16) NOT NULL,
+    "ExpiresAt" INT      NOT NULL,
+
+    PRIMARY KEY ("Nonce"),
+    FOREIGN KEY(PlayerID) REFERENCES Player(ID) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- See back.RevokeWebSessions, website sessions signed with an older version
+-- are refused. Players without a row are at version 0.
+CREATE TABLE "WebSessionVersion" (
+    "PlayerID" blob(16) NOT NULL,
+    "Version"  INT      NOT NULL DEFAULT 0,
+
+    PRIMARY KEY ("PlayerID"),
+    FOREIGN KEY(PlayerID) REFERENCES Player(ID) ON UPDATE CASCADE ON DELETE CASCADE
+);
@@ -0,0 +1,28 @@
+DROP TABLE "Maintenance";
+
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE "backup_League" (
+  "ID" blob NOT NULL,
+  "CreatedAt" integer NOT NULL,
+  "Name" text NOT NULL,
+  "ShortCode" text NOT NULL,
+  "GameID" blob NOT NULL,
+  "Settings" text NOT NULL,
+  "Schedule" text NOT NULL,
+  "AnnounceDiscordChannelID" text NULL,
+  "Generator" text NOT NULL DEFAULT '',
+  "RatingPeriodStartedAt" integer NULL,
+  "Requirements" text NOT NULL DEFAULT '{}',
+  "RatingSystem" text NOT NULL DEFAULT 'glicko2',
+  "RatingPeriod" text NOT NULL DEFAULT 'weekly',
+  "Leaderboard" text NOT NULL DEFAULT '{}',
+  PRIMARY KEY ("ID"),
+  FOREIGN KEY ("GameID") REFERENCES "Game" ("ID") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+INSERT INTO "backup_League" ("ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator", "RatingPeriodStartedAt", "Requirements", "RatingSystem", "RatingPeriod", "Leaderboard") SELECT "ID", "CreatedAt", "Name", "ShortCode", "GameID", "Settings", "Schedule", "AnnounceDiscordChannelID", "Generator", "RatingPeriodStartedAt", "Requirements", "RatingSystem", "RatingPeriod", "Leaderboard" FROM "League";
+DROP TABLE "League";
+ALTER TABLE "backup_League" RENAME TO "League";
+CREATE UNIQUE INDEX "idx_unique_ShortCode" ON "League" ("ShortCode");
+
+PRAGMA foreign_keys = ON;
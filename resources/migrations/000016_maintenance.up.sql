@@ -0,0 +1,13 @@
+-- See back.Maintenance, holds a single row.
+CREATE TABLE "Maintenance" (
+    "ID"        INT  NOT NULL CHECK ("ID" = 1),
+    "Enabled"   INT  NOT NULL DEFAULT 0,
+    "Message"   TEXT NOT NULL DEFAULT '',
+    "UpdatedAt" INT  NOT NULL DEFAULT 0,
+
+    PRIMARY KEY ("ID")
+);
+INSERT INTO "Maintenance" ("ID") VALUES (1);
+
+-- See League.Paused.
+ALTER TABLE "League" ADD "Paused" INT NOT NULL DEFAULT 0;
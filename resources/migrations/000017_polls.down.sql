@@ -0,0 +1,2 @@
+DROP TABLE "PollSlot";
+DROP TABLE "Poll";
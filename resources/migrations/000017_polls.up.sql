@@ -0,0 +1,28 @@
+-- See back.Poll, a vote on the date of an extra race of a league.
+CREATE TABLE "Poll" (
+    "ID"        blob(16) NOT NULL,
+    "LeagueID"  blob(16) NOT NULL,
+    "CreatedAt" INT      NOT NULL,
+    "ClosedAt"  INT      NULL,
+
+    -- Votes a slot needs for its race to be created.
+    "Quorum" INT NOT NULL,
+
+    PRIMARY KEY ("ID"),
+    FOREIGN KEY(LeagueID) REFERENCES League(ID) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+CREATE TABLE "PollSlot" (
+    "ID"        blob(16) NOT NULL,
+    "PollID"    blob(16) NOT NULL,
+    "StartDate" TEXT     NOT NULL,
+
+    -- JSON array of the Player.ID who voted for this slot.
+    "VoterIDs" TEXT NOT NULL DEFAULT '[]',
+
+    -- 1 for the slot that reached the quorum and closed the poll.
+    "Chosen" INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY ("ID"),
+    FOREIGN KEY(PollID) REFERENCES Poll(ID) ON UPDATE CASCADE ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+"use strict";
+
+(function (){
+    // Ask before submitting forms that can't be undone.
+    document.querySelectorAll("form[data-confirm]").forEach(form => {
+        form.addEventListener("submit", e => {
+            if (!window.confirm(form.dataset.confirm)) {
+                e.preventDefault();
+            }
+        });
+    });
+})();
@@ -0,0 +1,19 @@
+"use strict";
+
+(function (){
+    if (!window.EventSource) {
+        return;
+    }
+
+    // Reload the page when a displayed session changes status, the status
+    // tags and countdowns are rendered server-side.
+    document.querySelectorAll("[data-live]").forEach(box => {
+        const source = new EventSource(box.dataset.live);
+        source.addEventListener("session_status", e => {
+            const ev = JSON.parse(e.data);
+            if (ev.session.status !== box.dataset.liveStatus) {
+                window.location.reload();
+            }
+        });
+    });
+})();
@@ -0,0 +1,55 @@
+"use strict";
+
+(function (){
+    const overlay = document.querySelector(".js-overlay");
+    const timer = overlay.querySelector(".js-overlay-timer");
+
+    // Compensate the clock difference between the browser and the server.
+    const offset = Date.now() - (overlay.dataset.now * 1000);
+    const start = overlay.dataset.start * 1000;
+    const end = overlay.dataset.end ? overlay.dataset.end * 1000 : null;
+
+    function format(ms) {
+        const sign = ms < 0 ? "-" : "";
+        const total = Math.floor(Math.abs(ms) / 1000);
+        const pad = v => String(v).padStart(2, "0");
+
+        return sign + Math.floor(total / 3600) + ":" + pad(Math.floor(total / 60) % 60) + ":" + pad(total % 60);
+    }
+
+    function tick() {
+        const now = end !== null ? end : (Date.now() - offset);
+        timer.textContent = format(now - start);
+    }
+
+    if (timer) {
+        tick();
+        if (end === null) {
+            window.setInterval(tick, 200);
+        }
+    }
+
+    // Without a live feed, look for a new race from time to time.
+    if (!overlay.dataset.live || !window.EventSource) {
+        window.setTimeout(() => window.location.reload(), 60 * 1000);
+        return;
+    }
+
+    const players = Array.from(overlay.querySelectorAll("[data-player]")).map(e => e.dataset.player);
+    const source = new EventSource(overlay.dataset.live);
+    source.addEventListener("session_status", e => {
+        const session = JSON.parse(e.data).session;
+        const isOurs = session.id === overlay.dataset.session;
+
+        // Our session moved on or a new one started, it may hold a new match.
+        if ((isOurs && session.status !== overlay.dataset.status) ||
+            (!isOurs && session.status === "in_progress")) {
+            window.location.reload();
+        }
+    });
+    source.addEventListener("entry_end", e => {
+        if (players.includes(JSON.parse(e.data).player)) {
+            window.location.reload();
+        }
+    });
+})();